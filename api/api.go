@@ -0,0 +1,509 @@
+// Package api is the cats-owned gRPC service, CatsApi, for RPCs that are not
+// part of the opsee.Cats service in github.com/opsee/basic. Messages are
+// plain structs with protobuf struct tags, which the gRPC proto codec
+// marshals by reflection.
+package api
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/opsee/basic/schema"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+)
+
+// NotificationDelivery is an entry in a check's notification delivery log.
+type NotificationDelivery struct {
+	Id            int64                  `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	TransitionId  int64                  `protobuf:"varint,2,opt,name=transition_id" json:"transition_id,omitempty"`
+	CheckId       string                 `protobuf:"bytes,3,opt,name=check_id" json:"check_id,omitempty"`
+	Type          string                 `protobuf:"bytes,4,opt,name=type" json:"type,omitempty"`
+	Value         string                 `protobuf:"bytes,5,opt,name=value" json:"value,omitempty"`
+	Status        string                 `protobuf:"bytes,6,opt,name=status" json:"status,omitempty"`
+	Attempts      int32                  `protobuf:"varint,7,opt,name=attempts" json:"attempts,omitempty"`
+	LastError     string                 `protobuf:"bytes,8,opt,name=last_error" json:"last_error,omitempty"`
+	CreatedAt     *opsee_types.Timestamp `protobuf:"bytes,9,opt,name=created_at" json:"created_at,omitempty"`
+	UpdatedAt     *opsee_types.Timestamp `protobuf:"bytes,10,opt,name=updated_at" json:"updated_at,omitempty"`
+	NextAttemptAt *opsee_types.Timestamp `protobuf:"bytes,11,opt,name=next_attempt_at" json:"next_attempt_at,omitempty"`
+}
+
+func (m *NotificationDelivery) Reset()         { *m = NotificationDelivery{} }
+func (m *NotificationDelivery) String() string { return proto.CompactTextString(m) }
+func (*NotificationDelivery) ProtoMessage()    {}
+
+type ListNotificationDeliveriesRequest struct {
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	CheckId    string `protobuf:"bytes,2,opt,name=check_id" json:"check_id,omitempty"`
+	// Type, if set, only returns deliveries of that notification type.
+	Type  string `protobuf:"bytes,3,opt,name=type" json:"type,omitempty"`
+	Limit int32  `protobuf:"varint,4,opt,name=limit" json:"limit,omitempty"`
+}
+
+func (m *ListNotificationDeliveriesRequest) Reset()         { *m = ListNotificationDeliveriesRequest{} }
+func (m *ListNotificationDeliveriesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListNotificationDeliveriesRequest) ProtoMessage()    {}
+
+type ListNotificationDeliveriesResponse struct {
+	Deliveries []*NotificationDelivery `protobuf:"bytes,1,rep,name=deliveries" json:"deliveries,omitempty"`
+}
+
+func (m *ListNotificationDeliveriesResponse) Reset()         { *m = ListNotificationDeliveriesResponse{} }
+func (m *ListNotificationDeliveriesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListNotificationDeliveriesResponse) ProtoMessage()    {}
+
+type GetWebhookSecretRequest struct {
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	// Rotate replaces the customer's secret with a new one.
+	Rotate bool `protobuf:"varint,2,opt,name=rotate" json:"rotate,omitempty"`
+}
+
+func (m *GetWebhookSecretRequest) Reset()         { *m = GetWebhookSecretRequest{} }
+func (m *GetWebhookSecretRequest) String() string { return proto.CompactTextString(m) }
+func (*GetWebhookSecretRequest) ProtoMessage()    {}
+
+type GetWebhookSecretResponse struct {
+	Secret string `protobuf:"bytes,1,opt,name=secret" json:"secret,omitempty"`
+}
+
+func (m *GetWebhookSecretResponse) Reset()         { *m = GetWebhookSecretResponse{} }
+func (m *GetWebhookSecretResponse) String() string { return proto.CompactTextString(m) }
+func (*GetWebhookSecretResponse) ProtoMessage()    {}
+
+// CriticalChecksRequest lists a customer's critical checks, whose alerts are
+// never held for notification digests. If CheckId is set, it is first marked
+// critical, or not.
+type CriticalChecksRequest struct {
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	CheckId    string `protobuf:"bytes,2,opt,name=check_id" json:"check_id,omitempty"`
+	Critical   bool   `protobuf:"varint,3,opt,name=critical" json:"critical,omitempty"`
+}
+
+func (m *CriticalChecksRequest) Reset()         { *m = CriticalChecksRequest{} }
+func (m *CriticalChecksRequest) String() string { return proto.CompactTextString(m) }
+func (*CriticalChecksRequest) ProtoMessage()    {}
+
+type CriticalChecksResponse struct {
+	CheckIds []string `protobuf:"bytes,1,rep,name=check_ids" json:"check_ids,omitempty"`
+}
+
+func (m *CriticalChecksResponse) Reset()         { *m = CriticalChecksResponse{} }
+func (m *CriticalChecksResponse) String() string { return proto.CompactTextString(m) }
+func (*CriticalChecksResponse) ProtoMessage()    {}
+
+// CategoryPreference is the channels a user wants a notification category
+// on. An empty list of channels opts out of the category.
+type CategoryPreference struct {
+	Category string   `protobuf:"bytes,1,opt,name=category" json:"category,omitempty"`
+	Channels []string `protobuf:"bytes,2,rep,name=channels" json:"channels"`
+}
+
+func (m *CategoryPreference) Reset()         { *m = CategoryPreference{} }
+func (m *CategoryPreference) String() string { return proto.CompactTextString(m) }
+func (*CategoryPreference) ProtoMessage()    {}
+
+// NotificationPreferences are a user's notification preferences. Categories
+// that aren't listed are sent on every channel. Quiet hours are "15:04"
+// times in Timezone, and are unset if both are empty.
+type NotificationPreferences struct {
+	Timezone        string                `protobuf:"bytes,1,opt,name=timezone" json:"timezone,omitempty"`
+	QuietHoursStart string                `protobuf:"bytes,2,opt,name=quiet_hours_start" json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   string                `protobuf:"bytes,3,opt,name=quiet_hours_end" json:"quiet_hours_end,omitempty"`
+	Categories      []*CategoryPreference `protobuf:"bytes,4,rep,name=categories" json:"categories,omitempty"`
+}
+
+func (m *NotificationPreferences) Reset()         { *m = NotificationPreferences{} }
+func (m *NotificationPreferences) String() string { return proto.CompactTextString(m) }
+func (*NotificationPreferences) ProtoMessage()    {}
+
+type GetNotificationPreferencesRequest struct {
+	UserId int32 `protobuf:"varint,1,opt,name=user_id" json:"user_id,omitempty"`
+}
+
+func (m *GetNotificationPreferencesRequest) Reset()         { *m = GetNotificationPreferencesRequest{} }
+func (m *GetNotificationPreferencesRequest) String() string { return proto.CompactTextString(m) }
+func (*GetNotificationPreferencesRequest) ProtoMessage()    {}
+
+// UpdateNotificationPreferencesRequest replaces a user's preferences.
+type UpdateNotificationPreferencesRequest struct {
+	UserId      int32                    `protobuf:"varint,1,opt,name=user_id" json:"user_id,omitempty"`
+	Preferences *NotificationPreferences `protobuf:"bytes,2,opt,name=preferences" json:"preferences,omitempty"`
+}
+
+func (m *UpdateNotificationPreferencesRequest) Reset()         { *m = UpdateNotificationPreferencesRequest{} }
+func (m *UpdateNotificationPreferencesRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateNotificationPreferencesRequest) ProtoMessage()    {}
+
+type NotificationPreferencesResponse struct {
+	Preferences *NotificationPreferences `protobuf:"bytes,1,opt,name=preferences" json:"preferences,omitempty"`
+}
+
+func (m *NotificationPreferencesResponse) Reset()         { *m = NotificationPreferencesResponse{} }
+func (m *NotificationPreferencesResponse) String() string { return proto.CompactTextString(m) }
+func (*NotificationPreferencesResponse) ProtoMessage()    {}
+
+// WatchCheckStatesRequest watches the states of a customer's checks.
+type WatchCheckStatesRequest struct {
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+}
+
+func (m *WatchCheckStatesRequest) Reset()         { *m = WatchCheckStatesRequest{} }
+func (m *WatchCheckStatesRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchCheckStatesRequest) ProtoMessage()    {}
+
+// CheckStateEvent is a check's state. Snapshot events are the states of
+// every check when the watch started, and are sent first; the rest are
+// transitions, in the order they were committed. A transition may repeat
+// one already reflected in the snapshot, and can be recognized by its
+// TransitionId.
+type CheckStateEvent struct {
+	CheckId       string                 `protobuf:"bytes,1,opt,name=check_id" json:"check_id,omitempty"`
+	State         string                 `protobuf:"bytes,2,opt,name=state" json:"state,omitempty"`
+	FromState     string                 `protobuf:"bytes,3,opt,name=from_state" json:"from_state,omitempty"`
+	TransitionId  int64                  `protobuf:"varint,4,opt,name=transition_id" json:"transition_id,omitempty"`
+	FailingCount  int32                  `protobuf:"varint,5,opt,name=failing_count" json:"failing_count,omitempty"`
+	ResponseCount int32                  `protobuf:"varint,6,opt,name=response_count" json:"response_count,omitempty"`
+	Timestamp     *opsee_types.Timestamp `protobuf:"bytes,7,opt,name=timestamp" json:"timestamp,omitempty"`
+	Snapshot      bool                   `protobuf:"varint,8,opt,name=snapshot" json:"snapshot,omitempty"`
+}
+
+func (m *CheckStateEvent) Reset()         { *m = CheckStateEvent{} }
+func (m *CheckStateEvent) String() string { return proto.CompactTextString(m) }
+func (*CheckStateEvent) ProtoMessage()    {}
+
+// ListChecksRequest filters, sorts and pages a customer's checks. Fields
+// that aren't set don't filter. SortBy is "name", the default, "state" or
+// "last_transition". Cursor is the NextCursor of the previous page.
+type ListChecksRequest struct {
+	CustomerId       string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	State            string `protobuf:"bytes,2,opt,name=state" json:"state,omitempty"`
+	TargetType       string `protobuf:"bytes,3,opt,name=target_type" json:"target_type,omitempty"`
+	TargetId         string `protobuf:"bytes,4,opt,name=target_id" json:"target_id,omitempty"`
+	Name             string `protobuf:"bytes,5,opt,name=name" json:"name,omitempty"`
+	ExecutionGroupId string `protobuf:"bytes,6,opt,name=execution_group_id" json:"execution_group_id,omitempty"`
+	MinFailingCount  int32  `protobuf:"varint,7,opt,name=min_failing_count" json:"min_failing_count,omitempty"`
+	// MaxFailingCount, if positive, is the most failing responses a check
+	// may have.
+	MaxFailingCount int32  `protobuf:"varint,8,opt,name=max_failing_count" json:"max_failing_count,omitempty"`
+	SortBy          string `protobuf:"bytes,9,opt,name=sort_by" json:"sort_by,omitempty"`
+	Descending      bool   `protobuf:"varint,10,opt,name=descending" json:"descending,omitempty"`
+	Cursor          string `protobuf:"bytes,11,opt,name=cursor" json:"cursor,omitempty"`
+	Limit           int32  `protobuf:"varint,12,opt,name=limit" json:"limit,omitempty"`
+	// Selector is a label selector, e.g. "env=prod,team!=infra".
+	Selector string `protobuf:"bytes,13,opt,name=selector" json:"selector,omitempty"`
+}
+
+func (m *ListChecksRequest) Reset()         { *m = ListChecksRequest{} }
+func (m *ListChecksRequest) String() string { return proto.CompactTextString(m) }
+func (*ListChecksRequest) ProtoMessage()    {}
+
+// ListChecksResponse is a page of checks. Total is the number of checks
+// matching the request's filters, on all pages. NextCursor is empty on the
+// last page. Labels are the labels of the checks that have any.
+type ListChecksResponse struct {
+	Checks     []*schema.Check `protobuf:"bytes,1,rep,name=checks" json:"checks,omitempty"`
+	Total      int32           `protobuf:"varint,2,opt,name=total" json:"total,omitempty"`
+	NextCursor string          `protobuf:"bytes,3,opt,name=next_cursor" json:"next_cursor,omitempty"`
+	Labels     []*CheckLabels  `protobuf:"bytes,4,rep,name=labels" json:"labels,omitempty"`
+}
+
+func (m *ListChecksResponse) Reset()         { *m = ListChecksResponse{} }
+func (m *ListChecksResponse) String() string { return proto.CompactTextString(m) }
+func (*ListChecksResponse) ProtoMessage()    {}
+
+// CreateCheckRequest creates a check for a customer. The check is given an
+// id if it doesn't have one.
+type CreateCheckRequest struct {
+	CustomerId string        `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	Check      *schema.Check `protobuf:"bytes,2,opt,name=check" json:"check,omitempty"`
+}
+
+func (m *CreateCheckRequest) Reset()         { *m = CreateCheckRequest{} }
+func (m *CreateCheckRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateCheckRequest) ProtoMessage()    {}
+
+// UpdateCheckRequest replaces a customer's check and its assertions and
+// notifications. Changing its failing thresholds resets its state to OK.
+type UpdateCheckRequest struct {
+	CustomerId string        `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	Check      *schema.Check `protobuf:"bytes,2,opt,name=check" json:"check,omitempty"`
+}
+
+func (m *UpdateCheckRequest) Reset()         { *m = UpdateCheckRequest{} }
+func (m *UpdateCheckRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateCheckRequest) ProtoMessage()    {}
+
+// DeleteCheckRequest deletes a check, or with Selector instead of CheckId,
+// every check whose labels match it.
+type DeleteCheckRequest struct {
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	CheckId    string `protobuf:"bytes,2,opt,name=check_id" json:"check_id,omitempty"`
+	Selector   string `protobuf:"bytes,3,opt,name=selector" json:"selector,omitempty"`
+}
+
+func (m *DeleteCheckRequest) Reset()         { *m = DeleteCheckRequest{} }
+func (m *DeleteCheckRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteCheckRequest) ProtoMessage()    {}
+
+// CheckResponse is a check as it was written.
+type CheckResponse struct {
+	Check *schema.Check `protobuf:"bytes,1,opt,name=check" json:"check,omitempty"`
+}
+
+func (m *CheckResponse) Reset()         { *m = CheckResponse{} }
+func (m *CheckResponse) String() string { return proto.CompactTextString(m) }
+func (*CheckResponse) ProtoMessage()    {}
+
+type DeleteCheckResponse struct {
+	CheckIds []string `protobuf:"bytes,1,rep,name=check_ids" json:"check_ids,omitempty"`
+}
+
+func (m *DeleteCheckResponse) Reset()         { *m = DeleteCheckResponse{} }
+func (m *DeleteCheckResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteCheckResponse) ProtoMessage()    {}
+
+// ExportChecksRequest exports a customer's checks as a bundle document.
+// Format is "yaml", the default, or "json".
+type ExportChecksRequest struct {
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	Format     string `protobuf:"bytes,2,opt,name=format" json:"format,omitempty"`
+}
+
+func (m *ExportChecksRequest) Reset()         { *m = ExportChecksRequest{} }
+func (m *ExportChecksRequest) String() string { return proto.CompactTextString(m) }
+func (*ExportChecksRequest) ProtoMessage()    {}
+
+type ExportChecksResponse struct {
+	Document []byte `protobuf:"bytes,1,opt,name=document" json:"document,omitempty"`
+}
+
+func (m *ExportChecksResponse) Reset()         { *m = ExportChecksResponse{} }
+func (m *ExportChecksResponse) String() string { return proto.CompactTextString(m) }
+func (*ExportChecksResponse) ProtoMessage()    {}
+
+// ImportChecksRequest makes a customer's checks match a YAML or JSON bundle
+// document, creating, updating and deleting checks. With DryRun, the
+// changes are planned but not made.
+type ImportChecksRequest struct {
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	Document   []byte `protobuf:"bytes,2,opt,name=document" json:"document,omitempty"`
+	DryRun     bool   `protobuf:"varint,3,opt,name=dry_run" json:"dry_run,omitempty"`
+}
+
+func (m *ImportChecksRequest) Reset()         { *m = ImportChecksRequest{} }
+func (m *ImportChecksRequest) String() string { return proto.CompactTextString(m) }
+func (*ImportChecksRequest) ProtoMessage()    {}
+
+// CheckChange is a change an import makes to a check. Action is "create",
+// "update" or "delete".
+type CheckChange struct {
+	Action  string `protobuf:"bytes,1,opt,name=action" json:"action,omitempty"`
+	CheckId string `protobuf:"bytes,2,opt,name=check_id" json:"check_id,omitempty"`
+	Name    string `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *CheckChange) Reset()         { *m = CheckChange{} }
+func (m *CheckChange) String() string { return proto.CompactTextString(m) }
+func (*CheckChange) ProtoMessage()    {}
+
+// ImportChecksResponse is the changes an import made, or would make if it
+// was a dry run. Created checks are given ids unless it was.
+type ImportChecksResponse struct {
+	Changes   []*CheckChange `protobuf:"bytes,1,rep,name=changes" json:"changes,omitempty"`
+	Unchanged int32          `protobuf:"varint,2,opt,name=unchanged" json:"unchanged,omitempty"`
+	DryRun    bool           `protobuf:"varint,3,opt,name=dry_run" json:"dry_run,omitempty"`
+}
+
+func (m *ImportChecksResponse) Reset()         { *m = ImportChecksResponse{} }
+func (m *ImportChecksResponse) String() string { return proto.CompactTextString(m) }
+func (*ImportChecksResponse) ProtoMessage()    {}
+
+// CheckLabels are a check's labels.
+type CheckLabels struct {
+	CheckId string            `protobuf:"bytes,1,opt,name=check_id" json:"check_id,omitempty"`
+	Labels  map[string]string `protobuf:"bytes,2,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *CheckLabels) Reset()         { *m = CheckLabels{} }
+func (m *CheckLabels) String() string { return proto.CompactTextString(m) }
+func (*CheckLabels) ProtoMessage()    {}
+
+// UpdateCheckLabelsRequest sets and removes labels on a check, or with
+// Selector instead of CheckId, on every check whose labels match it. Other
+// labels are left alone.
+type UpdateCheckLabelsRequest struct {
+	CustomerId string            `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	CheckId    string            `protobuf:"bytes,2,opt,name=check_id" json:"check_id,omitempty"`
+	Selector   string            `protobuf:"bytes,3,opt,name=selector" json:"selector,omitempty"`
+	Set        map[string]string `protobuf:"bytes,4,rep,name=set" json:"set,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Remove     []string          `protobuf:"bytes,5,rep,name=remove" json:"remove,omitempty"`
+}
+
+func (m *UpdateCheckLabelsRequest) Reset()         { *m = UpdateCheckLabelsRequest{} }
+func (m *UpdateCheckLabelsRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateCheckLabelsRequest) ProtoMessage()    {}
+
+// UpdateCheckLabelsResponse is the checks that were labeled, and their
+// labels.
+type UpdateCheckLabelsResponse struct {
+	Labels []*CheckLabels `protobuf:"bytes,1,rep,name=labels" json:"labels,omitempty"`
+}
+
+func (m *UpdateCheckLabelsResponse) Reset()         { *m = UpdateCheckLabelsResponse{} }
+func (m *UpdateCheckLabelsResponse) String() string { return proto.CompactTextString(m) }
+func (*UpdateCheckLabelsResponse) ProtoMessage()    {}
+
+// NotificationRoute sends the alerts of checks whose labels match Selector
+// to a notification, in addition to the check's own notifications. Selector
+// may not be empty, so a route can't catch every alert.
+type NotificationRoute struct {
+	Id       int64  `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Selector string `protobuf:"bytes,2,opt,name=selector" json:"selector,omitempty"`
+	Type     string `protobuf:"bytes,3,opt,name=type" json:"type,omitempty"`
+	Value    string `protobuf:"bytes,4,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *NotificationRoute) Reset()         { *m = NotificationRoute{} }
+func (m *NotificationRoute) String() string { return proto.CompactTextString(m) }
+func (*NotificationRoute) ProtoMessage()    {}
+
+// NotificationRoutesRequest lists a customer's notification routes. If Put
+// is set, it is first created, or updated if it has an id, and if DeleteId
+// is set, that route is first deleted.
+type NotificationRoutesRequest struct {
+	CustomerId string             `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	Put        *NotificationRoute `protobuf:"bytes,2,opt,name=put" json:"put,omitempty"`
+	DeleteId   int64              `protobuf:"varint,3,opt,name=delete_id" json:"delete_id,omitempty"`
+}
+
+func (m *NotificationRoutesRequest) Reset()         { *m = NotificationRoutesRequest{} }
+func (m *NotificationRoutesRequest) String() string { return proto.CompactTextString(m) }
+func (*NotificationRoutesRequest) ProtoMessage()    {}
+
+type NotificationRoutesResponse struct {
+	Routes []*NotificationRoute `protobuf:"bytes,1,rep,name=routes" json:"routes,omitempty"`
+}
+
+func (m *NotificationRoutesResponse) Reset()         { *m = NotificationRoutesResponse{} }
+func (m *NotificationRoutesResponse) String() string { return proto.CompactTextString(m) }
+func (*NotificationRoutesResponse) ProtoMessage()    {}
+
+// CheckTemplate is a check made once for every target it selects: those of
+// TargetType with one of TargetIds, or without TargetIds, every known target
+// of TargetType. The check's name, HTTP path, body and header values, and
+// assertion values may use the target, e.g. "{{.Target.Name}} health".
+// CheckIds are the checks made from the template.
+type CheckTemplate struct {
+	Id         string        `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Name       string        `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Check      *schema.Check `protobuf:"bytes,3,opt,name=check" json:"check,omitempty"`
+	TargetType string        `protobuf:"bytes,4,opt,name=target_type" json:"target_type,omitempty"`
+	TargetIds  []string      `protobuf:"bytes,5,rep,name=target_ids" json:"target_ids,omitempty"`
+	CheckIds   []string      `protobuf:"bytes,6,rep,name=check_ids" json:"check_ids,omitempty"`
+}
+
+func (m *CheckTemplate) Reset()         { *m = CheckTemplate{} }
+func (m *CheckTemplate) String() string { return proto.CompactTextString(m) }
+func (*CheckTemplate) ProtoMessage()    {}
+
+// PutCheckTemplateRequest creates a template, or replaces it if it has an
+// id, and makes its checks match it. Targets are known targets in addition
+// to those of the customer's checks that weren't made from a template, which
+// cats has no other inventory of, and when replacing a template, those of
+// the checks made from it. A template's checks are only made to match it
+// when it is put, so put it again, with Targets, to add checks for new
+// targets. With Prune, Targets are all of the targets that aren't known
+// from other checks, so the checks of targets that are gone are deleted.
+type PutCheckTemplateRequest struct {
+	CustomerId string           `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	Template   *CheckTemplate   `protobuf:"bytes,2,opt,name=template" json:"template,omitempty"`
+	Targets    []*schema.Target `protobuf:"bytes,3,rep,name=targets" json:"targets,omitempty"`
+	DryRun     bool             `protobuf:"varint,4,opt,name=dry_run" json:"dry_run,omitempty"`
+	Prune      bool             `protobuf:"varint,5,opt,name=prune" json:"prune,omitempty"`
+}
+
+func (m *PutCheckTemplateRequest) Reset()         { *m = PutCheckTemplateRequest{} }
+func (m *PutCheckTemplateRequest) String() string { return proto.CompactTextString(m) }
+func (*PutCheckTemplateRequest) ProtoMessage()    {}
+
+// PutCheckTemplateResponse is the template and the changes made to its
+// checks, or with DryRun, the changes that would be made.
+type PutCheckTemplateResponse struct {
+	Template  *CheckTemplate `protobuf:"bytes,1,opt,name=template" json:"template,omitempty"`
+	Changes   []*CheckChange `protobuf:"bytes,2,rep,name=changes" json:"changes,omitempty"`
+	Unchanged int32          `protobuf:"varint,3,opt,name=unchanged" json:"unchanged,omitempty"`
+	DryRun    bool           `protobuf:"varint,4,opt,name=dry_run" json:"dry_run,omitempty"`
+}
+
+func (m *PutCheckTemplateResponse) Reset()         { *m = PutCheckTemplateResponse{} }
+func (m *PutCheckTemplateResponse) String() string { return proto.CompactTextString(m) }
+func (*PutCheckTemplateResponse) ProtoMessage()    {}
+
+type ListCheckTemplatesRequest struct {
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+}
+
+func (m *ListCheckTemplatesRequest) Reset()         { *m = ListCheckTemplatesRequest{} }
+func (m *ListCheckTemplatesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListCheckTemplatesRequest) ProtoMessage()    {}
+
+type ListCheckTemplatesResponse struct {
+	Templates []*CheckTemplate `protobuf:"bytes,1,rep,name=templates" json:"templates,omitempty"`
+}
+
+func (m *ListCheckTemplatesResponse) Reset()         { *m = ListCheckTemplatesResponse{} }
+func (m *ListCheckTemplatesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListCheckTemplatesResponse) ProtoMessage()    {}
+
+// DeleteCheckTemplateRequest deletes a template and the checks made from
+// it, or with KeepChecks, leaves the checks as ordinary checks.
+type DeleteCheckTemplateRequest struct {
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	TemplateId string `protobuf:"bytes,2,opt,name=template_id" json:"template_id,omitempty"`
+	KeepChecks bool   `protobuf:"varint,3,opt,name=keep_checks" json:"keep_checks,omitempty"`
+}
+
+func (m *DeleteCheckTemplateRequest) Reset()         { *m = DeleteCheckTemplateRequest{} }
+func (m *DeleteCheckTemplateRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteCheckTemplateRequest) ProtoMessage()    {}
+
+// DeleteCheckTemplateResponse is the ids of the checks that were deleted.
+type DeleteCheckTemplateResponse struct {
+	CheckIds []string `protobuf:"bytes,1,rep,name=check_ids" json:"check_ids,omitempty"`
+}
+
+func (m *DeleteCheckTemplateResponse) Reset()         { *m = DeleteCheckTemplateResponse{} }
+func (m *DeleteCheckTemplateResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteCheckTemplateResponse) ProtoMessage()    {}
+
+// ListCheckResultsRequest gets a check's latest result from each of its
+// bastions, like GetCheckResults. With Partial, the results that could be
+// got are returned along with an error for each bastion whose result
+// couldn't, instead of failing the request. TimeoutMs limits how long to
+// wait for results; bastions that haven't answered by then have timed out.
+type ListCheckResultsRequest struct {
+	CustomerId string `protobuf:"bytes,1,opt,name=customer_id" json:"customer_id,omitempty"`
+	CheckId    string `protobuf:"bytes,2,opt,name=check_id" json:"check_id,omitempty"`
+	Partial    bool   `protobuf:"varint,3,opt,name=partial" json:"partial,omitempty"`
+	TimeoutMs  int32  `protobuf:"varint,4,opt,name=timeout_ms" json:"timeout_ms,omitempty"`
+}
+
+func (m *ListCheckResultsRequest) Reset()         { *m = ListCheckResultsRequest{} }
+func (m *ListCheckResultsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListCheckResultsRequest) ProtoMessage()    {}
+
+type ListCheckResultsResponse struct {
+	Results []*schema.CheckResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+	Errors  []*BastionError       `protobuf:"bytes,2,rep,name=errors" json:"errors,omitempty"`
+}
+
+func (m *ListCheckResultsResponse) Reset()         { *m = ListCheckResultsResponse{} }
+func (m *ListCheckResultsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListCheckResultsResponse) ProtoMessage()    {}
+
+// BastionError is why a bastion's result couldn't be got.
+type BastionError struct {
+	BastionId string `protobuf:"bytes,1,opt,name=bastion_id" json:"bastion_id,omitempty"`
+	Error     string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *BastionError) Reset()         { *m = BastionError{} }
+func (m *BastionError) String() string { return proto.CompactTextString(m) }
+func (*BastionError) ProtoMessage()    {}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/opsee/basic/schema"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessagesRoundTrip(t *testing.T) {
+	createdAt := &opsee_types.Timestamp{}
+	createdAt.Scan(time.Unix(1460000000, 0))
+
+	resp := &ListNotificationDeliveriesResponse{
+		Deliveries: []*NotificationDelivery{
+			{
+				Id:        1,
+				CheckId:   "check-id",
+				Type:      "webhook",
+				Status:    "delivered",
+				Attempts:  2,
+				CreatedAt: createdAt,
+			},
+		},
+	}
+
+	b, err := proto.Marshal(resp)
+	assert.Nil(t, err)
+
+	decoded := &ListNotificationDeliveriesResponse{}
+	assert.Nil(t, proto.Unmarshal(b, decoded))
+	assert.Len(t, decoded.Deliveries, 1)
+	assert.Equal(t, "webhook", decoded.Deliveries[0].Type)
+	assert.Equal(t, int32(2), decoded.Deliveries[0].Attempts)
+	assert.Equal(t, int64(1460000000), decoded.Deliveries[0].CreatedAt.Seconds)
+}
+
+func TestListChecksResponseRoundTrip(t *testing.T) {
+	resp := &ListChecksResponse{
+		Checks: []*schema.Check{
+			{
+				Id:   "check-id",
+				Name: "check",
+				Spec: &schema.Check_HttpCheck{HttpCheck: &schema.HttpCheck{Path: "/health", Port: 80}},
+			},
+		},
+		Total:      12,
+		NextCursor: "cursor",
+	}
+
+	b, err := proto.Marshal(resp)
+	assert.Nil(t, err)
+
+	decoded := &ListChecksResponse{}
+	assert.Nil(t, proto.Unmarshal(b, decoded))
+	assert.Len(t, decoded.Checks, 1)
+	assert.Equal(t, "check", decoded.Checks[0].Name)
+	assert.Equal(t, "/health", decoded.Checks[0].GetHttpCheck().Path)
+	assert.Equal(t, int32(12), decoded.Total)
+	assert.Equal(t, "cursor", decoded.NextCursor)
+}
+
+func TestCheckLabelsRoundTrip(t *testing.T) {
+	req := &UpdateCheckLabelsRequest{
+		CustomerId: "11111111-1111-1111-1111-111111111111",
+		Selector:   "env=prod",
+		Set:        map[string]string{"team": "payments", "tier": "web"},
+		Remove:     []string{"owner"},
+	}
+
+	b, err := proto.Marshal(req)
+	assert.Nil(t, err)
+
+	decoded := &UpdateCheckLabelsRequest{}
+	assert.Nil(t, proto.Unmarshal(b, decoded))
+	assert.Equal(t, req, decoded)
+}
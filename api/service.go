@@ -0,0 +1,656 @@
+package api
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// CatsApiClient is the client API for the CatsApi service.
+type CatsApiClient interface {
+	ListNotificationDeliveries(ctx context.Context, in *ListNotificationDeliveriesRequest, opts ...grpc.CallOption) (*ListNotificationDeliveriesResponse, error)
+	GetWebhookSecret(ctx context.Context, in *GetWebhookSecretRequest, opts ...grpc.CallOption) (*GetWebhookSecretResponse, error)
+	CriticalChecks(ctx context.Context, in *CriticalChecksRequest, opts ...grpc.CallOption) (*CriticalChecksResponse, error)
+	GetNotificationPreferences(ctx context.Context, in *GetNotificationPreferencesRequest, opts ...grpc.CallOption) (*NotificationPreferencesResponse, error)
+	UpdateNotificationPreferences(ctx context.Context, in *UpdateNotificationPreferencesRequest, opts ...grpc.CallOption) (*NotificationPreferencesResponse, error)
+	WatchCheckStates(ctx context.Context, in *WatchCheckStatesRequest, opts ...grpc.CallOption) (CatsApi_WatchCheckStatesClient, error)
+	ListChecks(ctx context.Context, in *ListChecksRequest, opts ...grpc.CallOption) (*ListChecksResponse, error)
+	CreateCheck(ctx context.Context, in *CreateCheckRequest, opts ...grpc.CallOption) (*CheckResponse, error)
+	UpdateCheck(ctx context.Context, in *UpdateCheckRequest, opts ...grpc.CallOption) (*CheckResponse, error)
+	DeleteCheck(ctx context.Context, in *DeleteCheckRequest, opts ...grpc.CallOption) (*DeleteCheckResponse, error)
+	ExportChecks(ctx context.Context, in *ExportChecksRequest, opts ...grpc.CallOption) (*ExportChecksResponse, error)
+	ImportChecks(ctx context.Context, in *ImportChecksRequest, opts ...grpc.CallOption) (*ImportChecksResponse, error)
+	UpdateCheckLabels(ctx context.Context, in *UpdateCheckLabelsRequest, opts ...grpc.CallOption) (*UpdateCheckLabelsResponse, error)
+	NotificationRoutes(ctx context.Context, in *NotificationRoutesRequest, opts ...grpc.CallOption) (*NotificationRoutesResponse, error)
+	PutCheckTemplate(ctx context.Context, in *PutCheckTemplateRequest, opts ...grpc.CallOption) (*PutCheckTemplateResponse, error)
+	ListCheckTemplates(ctx context.Context, in *ListCheckTemplatesRequest, opts ...grpc.CallOption) (*ListCheckTemplatesResponse, error)
+	DeleteCheckTemplate(ctx context.Context, in *DeleteCheckTemplateRequest, opts ...grpc.CallOption) (*DeleteCheckTemplateResponse, error)
+	ListCheckResults(ctx context.Context, in *ListCheckResultsRequest, opts ...grpc.CallOption) (*ListCheckResultsResponse, error)
+}
+
+type catsApiClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewCatsApiClient(cc *grpc.ClientConn) CatsApiClient {
+	return &catsApiClient{cc}
+}
+
+func (c *catsApiClient) ListNotificationDeliveries(ctx context.Context, in *ListNotificationDeliveriesRequest, opts ...grpc.CallOption) (*ListNotificationDeliveriesResponse, error) {
+	out := new(ListNotificationDeliveriesResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/ListNotificationDeliveries", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) GetWebhookSecret(ctx context.Context, in *GetWebhookSecretRequest, opts ...grpc.CallOption) (*GetWebhookSecretResponse, error) {
+	out := new(GetWebhookSecretResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/GetWebhookSecret", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) CriticalChecks(ctx context.Context, in *CriticalChecksRequest, opts ...grpc.CallOption) (*CriticalChecksResponse, error) {
+	out := new(CriticalChecksResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/CriticalChecks", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) GetNotificationPreferences(ctx context.Context, in *GetNotificationPreferencesRequest, opts ...grpc.CallOption) (*NotificationPreferencesResponse, error) {
+	out := new(NotificationPreferencesResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/GetNotificationPreferences", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) UpdateNotificationPreferences(ctx context.Context, in *UpdateNotificationPreferencesRequest, opts ...grpc.CallOption) (*NotificationPreferencesResponse, error) {
+	out := new(NotificationPreferencesResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/UpdateNotificationPreferences", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) ListChecks(ctx context.Context, in *ListChecksRequest, opts ...grpc.CallOption) (*ListChecksResponse, error) {
+	out := new(ListChecksResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/ListChecks", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) CreateCheck(ctx context.Context, in *CreateCheckRequest, opts ...grpc.CallOption) (*CheckResponse, error) {
+	out := new(CheckResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/CreateCheck", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) UpdateCheck(ctx context.Context, in *UpdateCheckRequest, opts ...grpc.CallOption) (*CheckResponse, error) {
+	out := new(CheckResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/UpdateCheck", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) DeleteCheck(ctx context.Context, in *DeleteCheckRequest, opts ...grpc.CallOption) (*DeleteCheckResponse, error) {
+	out := new(DeleteCheckResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/DeleteCheck", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) ExportChecks(ctx context.Context, in *ExportChecksRequest, opts ...grpc.CallOption) (*ExportChecksResponse, error) {
+	out := new(ExportChecksResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/ExportChecks", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) ImportChecks(ctx context.Context, in *ImportChecksRequest, opts ...grpc.CallOption) (*ImportChecksResponse, error) {
+	out := new(ImportChecksResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/ImportChecks", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) UpdateCheckLabels(ctx context.Context, in *UpdateCheckLabelsRequest, opts ...grpc.CallOption) (*UpdateCheckLabelsResponse, error) {
+	out := new(UpdateCheckLabelsResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/UpdateCheckLabels", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) NotificationRoutes(ctx context.Context, in *NotificationRoutesRequest, opts ...grpc.CallOption) (*NotificationRoutesResponse, error) {
+	out := new(NotificationRoutesResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/NotificationRoutes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) PutCheckTemplate(ctx context.Context, in *PutCheckTemplateRequest, opts ...grpc.CallOption) (*PutCheckTemplateResponse, error) {
+	out := new(PutCheckTemplateResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/PutCheckTemplate", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) ListCheckTemplates(ctx context.Context, in *ListCheckTemplatesRequest, opts ...grpc.CallOption) (*ListCheckTemplatesResponse, error) {
+	out := new(ListCheckTemplatesResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/ListCheckTemplates", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) DeleteCheckTemplate(ctx context.Context, in *DeleteCheckTemplateRequest, opts ...grpc.CallOption) (*DeleteCheckTemplateResponse, error) {
+	out := new(DeleteCheckTemplateResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/DeleteCheckTemplate", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) ListCheckResults(ctx context.Context, in *ListCheckResultsRequest, opts ...grpc.CallOption) (*ListCheckResultsResponse, error) {
+	out := new(ListCheckResultsResponse)
+	err := grpc.Invoke(ctx, "/cats.CatsApi/ListCheckResults", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catsApiClient) WatchCheckStates(ctx context.Context, in *WatchCheckStatesRequest, opts ...grpc.CallOption) (CatsApi_WatchCheckStatesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_CatsApi_serviceDesc.Streams[0], c.cc, "/cats.CatsApi/WatchCheckStates", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &catsApiWatchCheckStatesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CatsApi_WatchCheckStatesClient interface {
+	Recv() (*CheckStateEvent, error)
+	grpc.ClientStream
+}
+
+type catsApiWatchCheckStatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *catsApiWatchCheckStatesClient) Recv() (*CheckStateEvent, error) {
+	m := new(CheckStateEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CatsApiServer is the server API for the CatsApi service.
+type CatsApiServer interface {
+	ListNotificationDeliveries(context.Context, *ListNotificationDeliveriesRequest) (*ListNotificationDeliveriesResponse, error)
+	GetWebhookSecret(context.Context, *GetWebhookSecretRequest) (*GetWebhookSecretResponse, error)
+	CriticalChecks(context.Context, *CriticalChecksRequest) (*CriticalChecksResponse, error)
+	GetNotificationPreferences(context.Context, *GetNotificationPreferencesRequest) (*NotificationPreferencesResponse, error)
+	UpdateNotificationPreferences(context.Context, *UpdateNotificationPreferencesRequest) (*NotificationPreferencesResponse, error)
+	WatchCheckStates(*WatchCheckStatesRequest, CatsApi_WatchCheckStatesServer) error
+	ListChecks(context.Context, *ListChecksRequest) (*ListChecksResponse, error)
+	CreateCheck(context.Context, *CreateCheckRequest) (*CheckResponse, error)
+	UpdateCheck(context.Context, *UpdateCheckRequest) (*CheckResponse, error)
+	DeleteCheck(context.Context, *DeleteCheckRequest) (*DeleteCheckResponse, error)
+	ExportChecks(context.Context, *ExportChecksRequest) (*ExportChecksResponse, error)
+	ImportChecks(context.Context, *ImportChecksRequest) (*ImportChecksResponse, error)
+	UpdateCheckLabels(context.Context, *UpdateCheckLabelsRequest) (*UpdateCheckLabelsResponse, error)
+	NotificationRoutes(context.Context, *NotificationRoutesRequest) (*NotificationRoutesResponse, error)
+	PutCheckTemplate(context.Context, *PutCheckTemplateRequest) (*PutCheckTemplateResponse, error)
+	ListCheckTemplates(context.Context, *ListCheckTemplatesRequest) (*ListCheckTemplatesResponse, error)
+	DeleteCheckTemplate(context.Context, *DeleteCheckTemplateRequest) (*DeleteCheckTemplateResponse, error)
+	ListCheckResults(context.Context, *ListCheckResultsRequest) (*ListCheckResultsResponse, error)
+}
+
+func RegisterCatsApiServer(s *grpc.Server, srv CatsApiServer) {
+	s.RegisterService(&_CatsApi_serviceDesc, srv)
+}
+
+func _CatsApi_ListNotificationDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNotificationDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).ListNotificationDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/ListNotificationDeliveries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).ListNotificationDeliveries(ctx, req.(*ListNotificationDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_GetWebhookSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWebhookSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).GetWebhookSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/GetWebhookSecret",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).GetWebhookSecret(ctx, req.(*GetWebhookSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_CriticalChecks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CriticalChecksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).CriticalChecks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/CriticalChecks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).CriticalChecks(ctx, req.(*CriticalChecksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_GetNotificationPreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNotificationPreferencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).GetNotificationPreferences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/GetNotificationPreferences",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).GetNotificationPreferences(ctx, req.(*GetNotificationPreferencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_UpdateNotificationPreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateNotificationPreferencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).UpdateNotificationPreferences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/UpdateNotificationPreferences",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).UpdateNotificationPreferences(ctx, req.(*UpdateNotificationPreferencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_ListChecks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListChecksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).ListChecks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/ListChecks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).ListChecks(ctx, req.(*ListChecksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_CreateCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).CreateCheck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/CreateCheck",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).CreateCheck(ctx, req.(*CreateCheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_UpdateCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).UpdateCheck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/UpdateCheck",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).UpdateCheck(ctx, req.(*UpdateCheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_DeleteCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).DeleteCheck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/DeleteCheck",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).DeleteCheck(ctx, req.(*DeleteCheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_ExportChecks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportChecksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).ExportChecks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/ExportChecks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).ExportChecks(ctx, req.(*ExportChecksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_ImportChecks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportChecksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).ImportChecks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/ImportChecks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).ImportChecks(ctx, req.(*ImportChecksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_UpdateCheckLabels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCheckLabelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).UpdateCheckLabels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/UpdateCheckLabels",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).UpdateCheckLabels(ctx, req.(*UpdateCheckLabelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_NotificationRoutes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NotificationRoutesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).NotificationRoutes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/NotificationRoutes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).NotificationRoutes(ctx, req.(*NotificationRoutesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_PutCheckTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutCheckTemplateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).PutCheckTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/PutCheckTemplate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).PutCheckTemplate(ctx, req.(*PutCheckTemplateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_ListCheckTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCheckTemplatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).ListCheckTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/ListCheckTemplates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).ListCheckTemplates(ctx, req.(*ListCheckTemplatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_DeleteCheckTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCheckTemplateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).DeleteCheckTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/DeleteCheckTemplate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).DeleteCheckTemplate(ctx, req.(*DeleteCheckTemplateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_ListCheckResults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCheckResultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatsApiServer).ListCheckResults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cats.CatsApi/ListCheckResults",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatsApiServer).ListCheckResults(ctx, req.(*ListCheckResultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatsApi_WatchCheckStates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchCheckStatesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CatsApiServer).WatchCheckStates(m, &catsApiWatchCheckStatesServer{stream})
+}
+
+type CatsApi_WatchCheckStatesServer interface {
+	Send(*CheckStateEvent) error
+	grpc.ServerStream
+}
+
+type catsApiWatchCheckStatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *catsApiWatchCheckStatesServer) Send(m *CheckStateEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _CatsApi_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "cats.CatsApi",
+	HandlerType: (*CatsApiServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListNotificationDeliveries",
+			Handler:    _CatsApi_ListNotificationDeliveries_Handler,
+		},
+		{
+			MethodName: "GetWebhookSecret",
+			Handler:    _CatsApi_GetWebhookSecret_Handler,
+		},
+		{
+			MethodName: "CriticalChecks",
+			Handler:    _CatsApi_CriticalChecks_Handler,
+		},
+		{
+			MethodName: "GetNotificationPreferences",
+			Handler:    _CatsApi_GetNotificationPreferences_Handler,
+		},
+		{
+			MethodName: "UpdateNotificationPreferences",
+			Handler:    _CatsApi_UpdateNotificationPreferences_Handler,
+		},
+		{
+			MethodName: "ListChecks",
+			Handler:    _CatsApi_ListChecks_Handler,
+		},
+		{
+			MethodName: "CreateCheck",
+			Handler:    _CatsApi_CreateCheck_Handler,
+		},
+		{
+			MethodName: "UpdateCheck",
+			Handler:    _CatsApi_UpdateCheck_Handler,
+		},
+		{
+			MethodName: "DeleteCheck",
+			Handler:    _CatsApi_DeleteCheck_Handler,
+		},
+		{
+			MethodName: "ExportChecks",
+			Handler:    _CatsApi_ExportChecks_Handler,
+		},
+		{
+			MethodName: "ImportChecks",
+			Handler:    _CatsApi_ImportChecks_Handler,
+		},
+		{
+			MethodName: "UpdateCheckLabels",
+			Handler:    _CatsApi_UpdateCheckLabels_Handler,
+		},
+		{
+			MethodName: "NotificationRoutes",
+			Handler:    _CatsApi_NotificationRoutes_Handler,
+		},
+		{
+			MethodName: "PutCheckTemplate",
+			Handler:    _CatsApi_PutCheckTemplate_Handler,
+		},
+		{
+			MethodName: "ListCheckTemplates",
+			Handler:    _CatsApi_ListCheckTemplates_Handler,
+		},
+		{
+			MethodName: "DeleteCheckTemplate",
+			Handler:    _CatsApi_DeleteCheckTemplate_Handler,
+		},
+		{
+			MethodName: "ListCheckResults",
+			Handler:    _CatsApi_ListCheckResults_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCheckStates",
+			Handler:       _CatsApi_WatchCheckStates_Handler,
+			ServerStreams: true,
+		},
+	},
+}
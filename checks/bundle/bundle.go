@@ -0,0 +1,330 @@
+// Package bundle converts a customer's checks to and from a document that
+// can be kept in version control, and plans the changes that reconcile the
+// database with one. Documents are YAML or JSON, with checks ordered by name
+// and their assertions and notifications sorted, so that exporting the same
+// checks always gives the same document.
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/opsee/basic/schema"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	Version = 1
+
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+
+	// defaultInterval is the interval of checks that don't have one.
+	defaultInterval = 30
+)
+
+type Bundle struct {
+	Version int      `json:"version" yaml:"version"`
+	Checks  []*Check `json:"checks" yaml:"checks"`
+}
+
+// Check is a check in a bundle. Checks without an id are created on
+// import.
+type Check struct {
+	Id               string          `json:"id,omitempty" yaml:"id,omitempty"`
+	Name             string          `json:"name" yaml:"name"`
+	Interval         int32           `json:"interval" yaml:"interval"`
+	ExecutionGroupId string          `json:"execution_group_id" yaml:"execution_group_id"`
+	MinFailingCount  int32           `json:"min_failing_count" yaml:"min_failing_count"`
+	MinFailingTime   int64           `json:"min_failing_time" yaml:"min_failing_time"`
+	Target           Target          `json:"target" yaml:"target"`
+	Http             *HttpSpec       `json:"http,omitempty" yaml:"http,omitempty"`
+	CloudWatch       *CloudWatchSpec `json:"cloudwatch,omitempty" yaml:"cloudwatch,omitempty"`
+	Assertions       []Assertion     `json:"assertions,omitempty" yaml:"assertions,omitempty"`
+	Notifications    []Notification  `json:"notifications,omitempty" yaml:"notifications,omitempty"`
+}
+
+type Target struct {
+	Type string `json:"type" yaml:"type"`
+	Id   string `json:"id" yaml:"id"`
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+type HttpSpec struct {
+	Name     string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Protocol string   `json:"protocol" yaml:"protocol"`
+	Verb     string   `json:"verb" yaml:"verb"`
+	Port     int32    `json:"port" yaml:"port"`
+	Path     string   `json:"path" yaml:"path"`
+	Headers  []Header `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body     string   `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+type Header struct {
+	Name   string   `json:"name" yaml:"name"`
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+type CloudWatchSpec struct {
+	Metrics []Metric `json:"metrics" yaml:"metrics"`
+}
+
+type Metric struct {
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+}
+
+type Assertion struct {
+	Key          string `json:"key" yaml:"key"`
+	Value        string `json:"value,omitempty" yaml:"value,omitempty"`
+	Relationship string `json:"relationship" yaml:"relationship"`
+	Operand      string `json:"operand,omitempty" yaml:"operand,omitempty"`
+}
+
+type Notification struct {
+	Type  string `json:"type" yaml:"type"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// Export returns a bundle of checks.
+func Export(checks []*schema.Check) *Bundle {
+	b := &Bundle{Version: Version, Checks: make([]*Check, len(checks))}
+	for i, check := range checks {
+		b.Checks[i] = FromCheck(check)
+	}
+
+	sort.Sort(byName(b.Checks))
+	return b
+}
+
+// FromCheck returns the bundle form of a check.
+func FromCheck(check *schema.Check) *Check {
+	c := &Check{
+		Id:               check.Id,
+		Name:             check.Name,
+		Interval:         check.Interval,
+		ExecutionGroupId: check.ExecutionGroupId,
+		MinFailingCount:  check.MinFailingCount,
+		MinFailingTime:   check.MinFailingTime,
+	}
+
+	if check.Target != nil {
+		c.Target = Target{Type: check.Target.Type, Id: check.Target.Id, Name: check.Target.Name}
+	}
+
+	switch t := check.Spec.(type) {
+	case *schema.Check_HttpCheck:
+		h := t.HttpCheck
+		c.Http = &HttpSpec{Name: h.Name, Protocol: h.Protocol, Verb: h.Verb, Port: h.Port, Path: h.Path, Body: h.Body}
+		for _, header := range h.Headers {
+			c.Http.Headers = append(c.Http.Headers, Header{Name: header.Name, Values: header.Values})
+		}
+	case *schema.Check_CloudwatchCheck:
+		c.CloudWatch = &CloudWatchSpec{}
+		for _, m := range t.CloudwatchCheck.Metrics {
+			c.CloudWatch.Metrics = append(c.CloudWatch.Metrics, Metric{Namespace: m.Namespace, Name: m.Name})
+		}
+	}
+
+	for _, a := range check.Assertions {
+		c.Assertions = append(c.Assertions, Assertion{Key: a.Key, Value: a.Value, Relationship: a.Relationship, Operand: a.Operand})
+	}
+
+	for _, n := range check.Notifications {
+		c.Notifications = append(c.Notifications, Notification{Type: n.Type, Value: n.Value})
+	}
+
+	c.Normalize()
+	return c
+}
+
+// Check returns the customer's check c describes.
+func (c *Check) Check(customerId string) *schema.Check {
+	check := &schema.Check{
+		Id:               c.Id,
+		CustomerId:       customerId,
+		Name:             c.Name,
+		Interval:         c.Interval,
+		ExecutionGroupId: c.ExecutionGroupId,
+		MinFailingCount:  c.MinFailingCount,
+		MinFailingTime:   c.MinFailingTime,
+		Target:           &schema.Target{Type: c.Target.Type, Id: c.Target.Id, Name: c.Target.Name},
+		Notifications:    []*schema.Notification{},
+	}
+
+	// a bundle check with both specs is invalid, and left without one
+	switch {
+	case c.Http != nil && c.CloudWatch == nil:
+		h := &schema.HttpCheck{Name: c.Http.Name, Protocol: c.Http.Protocol, Verb: c.Http.Verb, Port: c.Http.Port, Path: c.Http.Path, Body: c.Http.Body}
+		for _, header := range c.Http.Headers {
+			h.Headers = append(h.Headers, &schema.Header{Name: header.Name, Values: header.Values})
+		}
+		check.Spec = &schema.Check_HttpCheck{HttpCheck: h}
+	case c.CloudWatch != nil && c.Http == nil:
+		cw := &schema.CloudWatchCheck{}
+		for _, m := range c.CloudWatch.Metrics {
+			cw.Metrics = append(cw.Metrics, &schema.CloudWatchMetric{Namespace: m.Namespace, Name: m.Name})
+		}
+		check.Spec = &schema.Check_CloudwatchCheck{CloudwatchCheck: cw}
+	}
+
+	for _, a := range c.Assertions {
+		check.Assertions = append(check.Assertions, &schema.Assertion{Key: a.Key, Value: a.Value, Relationship: a.Relationship, Operand: a.Operand})
+	}
+
+	for _, n := range c.Notifications {
+		check.Notifications = append(check.Notifications, &schema.Notification{Type: n.Type, Value: n.Value})
+	}
+
+	return check
+}
+
+// Normalize defaults the interval and sorts assertions and notifications,
+// whose order isn't kept by the database.
+func (c *Check) Normalize() {
+	if c.Interval == 0 {
+		c.Interval = defaultInterval
+	}
+
+	sort.Sort(assertionSort(c.Assertions))
+	sort.Sort(notificationSort(c.Notifications))
+
+	if len(c.Assertions) == 0 {
+		c.Assertions = nil
+	}
+
+	if len(c.Notifications) == 0 {
+		c.Notifications = nil
+	}
+}
+
+// Marshal encodes a bundle as FormatYAML or FormatJSON.
+func (b *Bundle) Marshal(format string) ([]byte, error) {
+	switch format {
+	case FormatYAML, "":
+		return yaml.Marshal(b)
+	case FormatJSON:
+		return json.MarshalIndent(b, "", "  ")
+	}
+
+	return nil, fmt.Errorf("unknown bundle format: %s", format)
+}
+
+// Unmarshal decodes a YAML or JSON bundle.
+func Unmarshal(data []byte) (*Bundle, error) {
+	b := &Bundle{}
+	if err := yaml.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+
+	if b.Version != Version {
+		return nil, fmt.Errorf("unsupported bundle version: %d", b.Version)
+	}
+
+	for _, c := range b.Checks {
+		if c == nil {
+			return nil, fmt.Errorf("bundle has an empty check")
+		}
+
+		c.Normalize()
+	}
+
+	return b, nil
+}
+
+// Plan is the changes that make the database match a bundle.
+type Plan struct {
+	Create    []*Check
+	Update    []*Check
+	Delete    []*Check
+	Unchanged int
+}
+
+// NewPlan compares a bundle with a customer's existing checks. Bundle
+// checks without an id, or whose id doesn't exist, are created, those that
+// differ from the existing check are updated, and existing checks that
+// aren't in the bundle are deleted.
+func NewPlan(b *Bundle, existing []*schema.Check) (*Plan, error) {
+	current := make(map[string]*Check, len(existing))
+	for _, check := range existing {
+		current[check.Id] = FromCheck(check)
+	}
+
+	var (
+		plan = &Plan{}
+		seen = make(map[string]bool, len(b.Checks))
+	)
+
+	for _, c := range b.Checks {
+		if c.Id == "" {
+			plan.Create = append(plan.Create, c)
+			continue
+		}
+
+		if seen[c.Id] {
+			return nil, fmt.Errorf("check %s is in the bundle more than once", c.Id)
+		}
+		seen[c.Id] = true
+
+		old, ok := current[c.Id]
+		switch {
+		case !ok:
+			plan.Create = append(plan.Create, c)
+		case reflect.DeepEqual(old, c):
+			plan.Unchanged++
+		default:
+			plan.Update = append(plan.Update, c)
+		}
+	}
+
+	for _, check := range existing {
+		if !seen[check.Id] {
+			plan.Delete = append(plan.Delete, current[check.Id])
+		}
+	}
+
+	sort.Sort(byName(plan.Delete))
+	return plan, nil
+}
+
+type byName []*Check
+
+func (s byName) Len() int      { return len(s) }
+func (s byName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool {
+	if s[i].Name != s[j].Name {
+		return s[i].Name < s[j].Name
+	}
+	return s[i].Id < s[j].Id
+}
+
+type assertionSort []Assertion
+
+func (s assertionSort) Len() int      { return len(s) }
+func (s assertionSort) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s assertionSort) Less(i, j int) bool {
+	a, b := s[i], s[j]
+	if a.Key != b.Key {
+		return a.Key < b.Key
+	}
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	if a.Relationship != b.Relationship {
+		return a.Relationship < b.Relationship
+	}
+	return a.Operand < b.Operand
+}
+
+type notificationSort []Notification
+
+func (s notificationSort) Len() int      { return len(s) }
+func (s notificationSort) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s notificationSort) Less(i, j int) bool {
+	if s[i].Type != s[j].Type {
+		return s[i].Type < s[j].Type
+	}
+	return s[i].Value < s[j].Value
+}
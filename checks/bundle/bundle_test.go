@@ -0,0 +1,128 @@
+package bundle
+
+import (
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func testChecks() []*schema.Check {
+	return []*schema.Check{
+		{
+			Id:               "check-b",
+			CustomerId:       "11111111-1111-1111-1111-111111111111",
+			ExecutionGroupId: "11111111-1111-1111-1111-111111111111",
+			Name:             "web",
+			Interval:         30,
+			MinFailingCount:  2,
+			MinFailingTime:   90,
+			Target:           &schema.Target{Type: "sg", Id: "sg-123456", Name: "web"},
+			Spec: &schema.Check_HttpCheck{HttpCheck: &schema.HttpCheck{
+				Name:     "web health",
+				Protocol: "https",
+				Verb:     "GET",
+				Port:     443,
+				Path:     "/health",
+			}},
+			Assertions: []*schema.Assertion{
+				{Key: "header", Value: "Content-Type", Relationship: "equal", Operand: "application/json"},
+				{Key: "code", Relationship: "equal", Operand: "200"},
+			},
+			Notifications: []*schema.Notification{
+				{Type: "slack_bot", Value: "#ops"},
+				{Type: "email", Value: "ops@opsee.co"},
+			},
+		},
+		{
+			Id:               "check-a",
+			CustomerId:       "11111111-1111-1111-1111-111111111111",
+			ExecutionGroupId: "11111111-1111-1111-1111-111111111111",
+			Name:             "db",
+			MinFailingCount:  1,
+			MinFailingTime:   90,
+			Target:           &schema.Target{Type: "dbinstance", Id: "db-1"},
+			Spec: &schema.Check_CloudwatchCheck{CloudwatchCheck: &schema.CloudWatchCheck{
+				Metrics: []*schema.CloudWatchMetric{{Namespace: "AWS/RDS", Name: "CPUUtilization"}},
+			}},
+			Notifications: []*schema.Notification{},
+		},
+	}
+}
+
+func TestExportIsStable(t *testing.T) {
+	assert := assert.New(t)
+
+	checks := testChecks()
+	doc, err := Export(checks).Marshal(FormatYAML)
+	assert.NoError(err)
+
+	// the order checks, assertions and notifications are read in doesn't
+	// matter
+	checks[0], checks[1] = checks[1], checks[0]
+	checks[1].Assertions[0], checks[1].Assertions[1] = checks[1].Assertions[1], checks[1].Assertions[0]
+	checks[1].Notifications[0], checks[1].Notifications[1] = checks[1].Notifications[1], checks[1].Notifications[0]
+
+	again, err := Export(checks).Marshal(FormatYAML)
+	assert.NoError(err)
+	assert.Equal(string(doc), string(again))
+
+	b, err := Unmarshal(doc)
+	assert.NoError(err)
+	assert.Len(b.Checks, 2)
+	assert.Equal("db", b.Checks[0].Name)
+	assert.Equal(int32(30), b.Checks[0].Interval)
+	assert.Equal("code", b.Checks[1].Assertions[0].Key)
+
+	// JSON documents are YAML documents
+	doc, err = Export(checks).Marshal(FormatJSON)
+	assert.NoError(err)
+
+	fromJSON, err := Unmarshal(doc)
+	assert.NoError(err)
+	assert.Equal(b, fromJSON)
+
+	_, err = Export(checks).Marshal("xml")
+	assert.Error(err)
+
+	_, err = Unmarshal([]byte("version: 2\nchecks: []\n"))
+	assert.Error(err)
+}
+
+func TestPlan(t *testing.T) {
+	assert := assert.New(t)
+
+	existing := testChecks()
+	doc, err := Export(existing).Marshal(FormatYAML)
+	assert.NoError(err)
+
+	b, err := Unmarshal(doc)
+	assert.NoError(err)
+
+	plan, err := NewPlan(b, existing)
+	assert.NoError(err)
+	assert.Empty(plan.Create)
+	assert.Empty(plan.Update)
+	assert.Empty(plan.Delete)
+	assert.Equal(2, plan.Unchanged)
+
+	// round trips through the bundle form
+	assert.Equal(existing[0].GetHttpCheck(), b.Checks[1].Check(existing[0].CustomerId).GetHttpCheck())
+
+	b.Checks[1].MinFailingCount = 3
+	b.Checks = append(b.Checks[1:], &Check{Name: "new"})
+
+	plan, err = NewPlan(b, existing)
+	assert.NoError(err)
+	assert.Len(plan.Create, 1)
+	assert.Equal("new", plan.Create[0].Name)
+	assert.Len(plan.Update, 1)
+	assert.Equal("check-b", plan.Update[0].Id)
+	assert.Len(plan.Delete, 1)
+	assert.Equal("check-a", plan.Delete[0].Id)
+	assert.Equal(0, plan.Unchanged)
+
+	b.Checks = append(b.Checks, b.Checks[0])
+	_, err = NewPlan(b, existing)
+	assert.Error(err)
+}
@@ -0,0 +1,266 @@
+// Package labels is key/value labels on checks, e.g. team=payments, and
+// Kubernetes-style selectors that match them, e.g. "env=prod,team!=infra".
+//
+// Selectors filter check lists, pick the checks of bulk updates and deletes,
+// and route alerts, which carry their check's labels. Transitions carry the
+// check's labels when they were committed, and those are kept next to the
+// transition's snapshot, since schema.Check has no field for them.
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxLength is the longest a label name or value may be.
+	MaxLength = 63
+
+	Exists       = "exists"
+	DoesNotExist = "!"
+	Equals       = "="
+	NotEquals    = "!="
+	In           = "in"
+	NotIn        = "notin"
+)
+
+var (
+	namePattern   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	prefixPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+)
+
+// Labels are a check's labels.
+type Labels map[string]string
+
+// Validate checks that keys are a name with an optional DNS prefix, e.g.
+// "opsee.co/team", and values are empty or a name.
+func (l Labels) Validate() error {
+	for k, v := range l {
+		if err := ValidateKey(k); err != nil {
+			return err
+		}
+
+		if err := ValidateValue(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// String returns the labels as a selector that matches them, sorted by key.
+func (l Labels) String() string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + l[k]
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func ValidateKey(key string) error {
+	name := key
+	if i := strings.Index(key, "/"); i >= 0 {
+		prefix := key[:i]
+		name = key[i+1:]
+
+		if len(prefix) > 253 || !prefixPattern.MatchString(prefix) {
+			return fmt.Errorf("invalid label key prefix: %q", key)
+		}
+	}
+
+	if len(name) > MaxLength || !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid label key: %q", key)
+	}
+
+	return nil
+}
+
+func ValidateValue(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if len(value) > MaxLength || !namePattern.MatchString(value) {
+		return fmt.Errorf("invalid label value: %q", value)
+	}
+
+	return nil
+}
+
+// Requirement is one comma separated term of a selector.
+type Requirement struct {
+	Key      string
+	Operator string
+	// Values are the values of Equals, NotEquals, In and NotIn
+	// requirements.
+	Values []string
+}
+
+// Matches returns true if labels satisfy the requirement. As in Kubernetes,
+// NotEquals and NotIn match labels without the key.
+func (r *Requirement) Matches(l Labels) bool {
+	v, ok := l[r.Key]
+
+	switch r.Operator {
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	case Equals, In:
+		return ok && r.hasValue(v)
+	case NotEquals, NotIn:
+		return !ok || !r.hasValue(v)
+	}
+
+	return false
+}
+
+func (r *Requirement) hasValue(v string) bool {
+	for _, value := range r.Values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key
+	case DoesNotExist:
+		return "!" + r.Key
+	case In, NotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	}
+
+	return r.Key + r.Operator + r.Values[0]
+}
+
+// Selector matches labels that satisfy all of its requirements. The empty
+// selector matches everything.
+type Selector []*Requirement
+
+// Matches returns true if labels satisfy every requirement.
+func (s Selector) Matches(l Labels) bool {
+	for _, r := range s {
+		if !r.Matches(l) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s Selector) String() string {
+	terms := make([]string, len(s))
+	for i, r := range s {
+		terms[i] = r.String()
+	}
+
+	return strings.Join(terms, ",")
+}
+
+// Parse parses a selector of comma separated requirements: "key",
+// "!key", "key=value", "key==value", "key!=value", "key in (v1,v2)" and
+// "key notin (v1,v2)".
+func Parse(selector string) (Selector, error) {
+	var s Selector
+
+	for _, term := range splitTerms(selector) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			if strings.TrimSpace(selector) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("invalid selector, empty requirement: %q", selector)
+		}
+
+		r, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+
+		s = append(s, r)
+	}
+
+	return s, nil
+}
+
+// splitTerms splits a selector at the commas that aren't in a value list.
+func splitTerms(selector string) []string {
+	var (
+		terms []string
+		depth int
+		start int
+	)
+
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(terms, selector[start:])
+}
+
+func parseRequirement(term string) (*Requirement, error) {
+	r := &Requirement{}
+
+	switch {
+	case strings.HasPrefix(term, "!") && !strings.Contains(term, "="):
+		r.Key, r.Operator = strings.TrimSpace(term[1:]), DoesNotExist
+	case strings.Contains(term, "!="):
+		parts := strings.SplitN(term, "!=", 2)
+		r.Key, r.Operator, r.Values = strings.TrimSpace(parts[0]), NotEquals, []string{strings.TrimSpace(parts[1])}
+	case strings.Contains(term, "=="):
+		parts := strings.SplitN(term, "==", 2)
+		r.Key, r.Operator, r.Values = strings.TrimSpace(parts[0]), Equals, []string{strings.TrimSpace(parts[1])}
+	case strings.Contains(term, "="):
+		parts := strings.SplitN(term, "=", 2)
+		r.Key, r.Operator, r.Values = strings.TrimSpace(parts[0]), Equals, []string{strings.TrimSpace(parts[1])}
+	case strings.Contains(term, "("):
+		fields := strings.Fields(term[:strings.Index(term, "(")])
+		if len(fields) != 2 || (fields[1] != In && fields[1] != NotIn) || !strings.HasSuffix(term, ")") {
+			return nil, fmt.Errorf("invalid selector requirement: %q", term)
+		}
+
+		r.Key, r.Operator = fields[0], fields[1]
+		list := term[strings.Index(term, "(")+1 : len(term)-1]
+		for _, v := range strings.Split(list, ",") {
+			r.Values = append(r.Values, strings.TrimSpace(v))
+		}
+	default:
+		r.Key, r.Operator = term, Exists
+	}
+
+	if err := ValidateKey(r.Key); err != nil {
+		return nil, err
+	}
+
+	for _, v := range r.Values {
+		if err := ValidateValue(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,64 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := Parse("env=prod, team!=infra,tier in (web, api),!canary,owner,region notin (us-west-1)")
+	assert.NoError(err)
+	assert.Equal("env=prod,team!=infra,tier in (web,api),!canary,owner,region notin (us-west-1)", s.String())
+	assert.Equal(&Requirement{Key: "tier", Operator: In, Values: []string{"web", "api"}}, s[2])
+
+	s, err = Parse("env==prod")
+	assert.NoError(err)
+	assert.Equal("env=prod", s.String())
+
+	s, err = Parse("")
+	assert.NoError(err)
+	assert.Len(s, 0)
+
+	for _, bad := range []string{"env=prod,", "env=pr od", "-env", "tier in web", "tier between (a,b)", "a/b/c=d"} {
+		_, err := Parse(bad)
+		assert.Error(err, bad)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	l := Labels{"env": "prod", "team": "payments", "tier": "web"}
+
+	for selector, matches := range map[string]bool{
+		"":                          true,
+		"env=prod":                  true,
+		"env=prod,team!=infra":      true,
+		"env=staging":               false,
+		"team!=payments":            false,
+		"tier in (web,api)":         true,
+		"tier notin (web)":          false,
+		"owner":                     false,
+		"!owner":                    true,
+		"owner!=cliff":              true,
+		"owner notin (cliff)":       true,
+		"env=prod,tier in (worker)": false,
+	} {
+		s, err := Parse(selector)
+		assert.NoError(err, selector)
+		assert.Equal(matches, s.Matches(l), selector)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(Labels{"env": "prod", "opsee.co/team": "payments", "canary": ""}.Validate())
+	assert.Error(Labels{"env": "pr od"}.Validate())
+	assert.Error(Labels{"": "prod"}.Validate())
+	assert.Error(Labels{"Opsee.co/team": "payments"}.Validate())
+	assert.Equal("a=1,b=2", Labels{"b": "2", "a": "1"}.String())
+}
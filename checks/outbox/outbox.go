@@ -0,0 +1,79 @@
+// Package outbox delivers the side effects of check state transitions. The
+// worker writes outbox entries in the same transaction as the transition, and
+// a Relay delivers them at least once, retrying failures with backoff.
+package outbox
+
+import (
+	"encoding/json"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/jmoiron/sqlx"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/labels"
+	"github.com/opsee/cats/store"
+)
+
+const (
+	// KindSnapshot entries write the check snapshot for a transition.
+	KindSnapshot = "snapshot"
+	// KindAlert entries publish an alert for a transition.
+	KindAlert = "alert"
+)
+
+// TransitionEvent is the payload of an outbox entry. It carries everything
+// known about the transition when it was committed, so that deliverers don't
+// depend on the state of the check at delivery time.
+type TransitionEvent struct {
+	TransitionId  int64          `json:"transition_id"`
+	CheckId       string         `json:"check_id"`
+	CustomerId    string         `json:"customer_id"`
+	From          checks.StateId `json:"from_state"`
+	To            checks.StateId `json:"to_state"`
+	FailingCount  int32          `json:"failing_count"`
+	ResponseCount int32          `json:"response_count"`
+	Result        []byte         `json:"result"`
+	// Labels are the check's labels when the transition was committed.
+	Labels labels.Labels `json:"labels,omitempty"`
+}
+
+// NewTransitionEvent returns the event for a logged transition. state must
+// already have transitioned, and result is the result that caused it.
+func NewTransitionEvent(entry *checks.StateTransitionLogEntry, state *checks.State, result *schema.CheckResult) (*TransitionEvent, error) {
+	resultBytes, err := proto.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransitionEvent{
+		TransitionId:  entry.Id,
+		CheckId:       entry.CheckId,
+		CustomerId:    entry.CustomerId,
+		From:          entry.From,
+		To:            entry.To,
+		FailingCount:  state.FailingCount,
+		ResponseCount: state.ResponseCount,
+		Result:        resultBytes,
+	}, nil
+}
+
+// CheckResult decodes the result that caused the transition.
+func (e *TransitionEvent) CheckResult() (*schema.CheckResult, error) {
+	result := &schema.CheckResult{}
+	if err := proto.Unmarshal(e.Result, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Enqueue writes an outbox entry of the given kind for event. q should be the
+// transaction the transition is committed in.
+func Enqueue(q sqlx.Ext, kind string, event *TransitionEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return store.NewOutboxStore(q).Put(kind, payload)
+}
@@ -0,0 +1,229 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/opsee/cats/store"
+	log "github.com/opsee/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+var (
+	outboxDelivered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_delivered",
+		Help: "Total number of outbox entries delivered, by kind.",
+	}, []string{"kind"})
+
+	outboxDeliveryFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_delivery_failures",
+		Help: "Total number of failed outbox delivery attempts, by kind.",
+	}, []string{"kind"})
+)
+
+func init() {
+	prometheus.MustRegister(outboxDelivered)
+	prometheus.MustRegister(outboxDeliveryFailures)
+}
+
+// DeliveryFunc delivers a single outbox entry. Returning an error schedules
+// the entry to be retried, so deliverers must be idempotent.
+type DeliveryFunc func(ctx context.Context, event *TransitionEvent) error
+
+type RelayConfig struct {
+	DB           *sqlx.DB
+	BatchSize    int
+	PollInterval time.Duration
+	MaxBackoff   time.Duration
+	// Lease is how long a claimed batch is hidden from other relays while
+	// it is delivered. It should be longer than a batch takes to deliver.
+	Lease time.Duration
+}
+
+// Relay polls the outbox table and hands each entry to the DeliveryFunc
+// registered for its kind. Several relays may run against the same table;
+// each batch is leased by one relay while it is delivered, and no
+// transaction is held meanwhile.
+type Relay struct {
+	db           *sqlx.DB
+	batchSize    int
+	pollInterval time.Duration
+	maxBackoff   time.Duration
+	lease        time.Duration
+	deliverers   map[string]DeliveryFunc
+	ctx          context.Context
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
+}
+
+func NewRelay(cfg *RelayConfig) *Relay {
+	r := &Relay{
+		db:           cfg.DB,
+		batchSize:    cfg.BatchSize,
+		pollInterval: cfg.PollInterval,
+		maxBackoff:   cfg.MaxBackoff,
+		lease:        cfg.Lease,
+		deliverers:   make(map[string]DeliveryFunc),
+		stopChan:     make(chan struct{}),
+	}
+
+	if r.batchSize < 1 {
+		r.batchSize = 10
+	}
+
+	if r.pollInterval <= 0 {
+		r.pollInterval = time.Second
+	}
+
+	if r.maxBackoff <= 0 {
+		r.maxBackoff = 10 * time.Minute
+	}
+
+	if r.lease <= 0 {
+		r.lease = 10 * time.Minute
+	}
+
+	return r
+}
+
+// Handle registers the DeliveryFunc for entries of the given kind. It must be
+// called before Start.
+func (r *Relay) Handle(kind string, fn DeliveryFunc) {
+	r.deliverers[kind] = fn
+}
+
+// Start starts polling. Deliveries are made with ctx, and entries that haven't
+// been delivered when it is cancelled are left for the next relay.
+func (r *Relay) Start(ctx context.Context) {
+	r.ctx = ctx
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stopChan:
+				return
+			case <-r.ctx.Done():
+				return
+			case <-ticker.C:
+				// drain everything that is due before waiting again
+				for {
+					n, err := r.poll()
+					if err != nil {
+						log.WithError(err).Error("Error polling outbox.")
+					}
+					if err != nil || n < r.batchSize {
+						break
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops polling and waits for the batch in flight to finish. If ctx is
+// done first, Stop returns ctx.Err().
+func (r *Relay) Stop(ctx context.Context) error {
+	close(r.stopChan)
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns how long to wait before retrying an entry that has failed
+// attempts times.
+func (r *Relay) backoff(attempts int) time.Duration {
+	if attempts > 20 {
+		return r.maxBackoff
+	}
+
+	d := time.Second << uint(attempts)
+	if d > r.maxBackoff {
+		return r.maxBackoff
+	}
+
+	return d
+}
+
+// poll claims a batch of due entries and delivers them, returning how many
+// were delivered or rescheduled. Each entry is deleted or rescheduled on its
+// own once it has been attempted.
+func (r *Relay) poll() (int, error) {
+	outboxStore := store.NewOutboxStore(r.db)
+	entries, err := outboxStore.Claim(r.batchSize, r.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	handled := 0
+	for i, entry := range entries {
+		// release the rest of the batch for the next relay
+		if r.ctx.Err() != nil {
+			for _, e := range entries[i:] {
+				if err := outboxStore.Reschedule(e.Id, e.Attempts-1, time.Now(), e.LastError); err != nil {
+					return handled, err
+				}
+			}
+			break
+		}
+
+		logger := log.WithFields(log.Fields{
+			"outbox_id": entry.Id,
+			"kind":      entry.Kind,
+			"attempts":  entry.Attempts,
+		})
+
+		if derr := r.deliver(entry); derr != nil {
+			outboxDeliveryFailures.WithLabelValues(entry.Kind).Inc()
+			logger.WithError(derr).Error("Error delivering outbox entry.")
+
+			if err := outboxStore.Reschedule(entry.Id, entry.Attempts, time.Now().Add(r.backoff(entry.Attempts)), derr.Error()); err != nil {
+				return handled, err
+			}
+			handled++
+			continue
+		}
+
+		if err := outboxStore.Delete(entry.Id); err != nil {
+			return handled, err
+		}
+
+		outboxDelivered.WithLabelValues(entry.Kind).Inc()
+		logger.Debug("Delivered outbox entry.")
+		handled++
+	}
+
+	return handled, nil
+}
+
+func (r *Relay) deliver(entry *store.OutboxEntry) error {
+	fn, ok := r.deliverers[entry.Kind]
+	if !ok {
+		return fmt.Errorf("no deliverer for outbox entry kind: %s", entry.Kind)
+	}
+
+	event := &TransitionEvent{}
+	if err := json.Unmarshal(entry.Payload, event); err != nil {
+		return err
+	}
+
+	return fn(r.ctx, event)
+}
@@ -0,0 +1,48 @@
+package outbox
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelayBackoff(t *testing.T) {
+	r := NewRelay(&RelayConfig{MaxBackoff: time.Minute})
+
+	assert.Equal(t, 2*time.Second, r.backoff(1))
+	assert.Equal(t, 32*time.Second, r.backoff(5))
+	assert.Equal(t, time.Minute, r.backoff(6))
+	assert.Equal(t, time.Minute, r.backoff(100))
+}
+
+func TestTransitionEventRoundTrip(t *testing.T) {
+	entry := &checks.StateTransitionLogEntry{
+		Id:         42,
+		CheckId:    "check-id",
+		CustomerId: "11111111-1111-1111-1111-111111111111",
+		From:       checks.StateFailWait,
+		To:         checks.StateFail,
+	}
+	state := &checks.State{FailingCount: 1, ResponseCount: 2}
+	result := &schema.CheckResult{CheckId: "check-id", BastionId: "bastion-id", Passing: false}
+
+	event, err := NewTransitionEvent(entry, state, result)
+	assert.Nil(t, err)
+
+	payload, err := json.Marshal(event)
+	assert.Nil(t, err)
+
+	decoded := &TransitionEvent{}
+	assert.Nil(t, json.Unmarshal(payload, decoded))
+	assert.Equal(t, int64(42), decoded.TransitionId)
+	assert.Equal(t, checks.StateFail, decoded.To)
+	assert.Equal(t, int32(2), decoded.ResponseCount)
+
+	decodedResult, err := decoded.CheckResult()
+	assert.Nil(t, err)
+	assert.Equal(t, "bastion-id", decodedResult.BastionId)
+}
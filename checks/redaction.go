@@ -0,0 +1,149 @@
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/opsee/basic/schema"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+)
+
+// RedactedValue replaces any header value or body match that has been redacted.
+const RedactedValue = "[REDACTED]"
+
+// RedactionRule describes sensitive data that must be scrubbed from check
+// responses before they are persisted. A rule without a CheckId applies to
+// every check belonging to the customer.
+type RedactionRule struct {
+	Id           int64    `json:"id" db:"id"`
+	CustomerId   string   `json:"customer_id" db:"customer_id"`
+	CheckId      string   `json:"check_id" db:"check_id"`
+	Headers      []string `json:"headers" db:"-"`
+	BodyPatterns []string `json:"body_patterns" db:"-"`
+	MaxBodySize  int      `json:"max_body_size" db:"max_body_size"`
+}
+
+// Redactor applies a merged set of RedactionRules to CheckResults.
+type Redactor struct {
+	headers     map[string]bool
+	patterns    []*regexp.Regexp
+	maxBodySize int
+}
+
+// NewRedactor merges rules into a single Redactor. Header names are matched
+// case-insensitively, and when more than one rule sets a maximum body size the
+// smallest one wins.
+func NewRedactor(rules []*RedactionRule) (*Redactor, error) {
+	r := &Redactor{
+		headers: map[string]bool{},
+	}
+
+	for _, rule := range rules {
+		for _, h := range rule.Headers {
+			r.headers[strings.ToLower(h)] = true
+		}
+
+		for _, p := range rule.BodyPatterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid redaction pattern %q: %s", p, err)
+			}
+			r.patterns = append(r.patterns, re)
+		}
+
+		if rule.MaxBodySize > 0 && (r.maxBodySize == 0 || rule.MaxBodySize < r.maxBodySize) {
+			r.maxBodySize = rule.MaxBodySize
+		}
+	}
+
+	return r, nil
+}
+
+// Empty returns true if the Redactor would not modify any result.
+func (r *Redactor) Empty() bool {
+	return len(r.headers) == 0 && len(r.patterns) == 0 && r.maxBodySize == 0
+}
+
+// RedactResult scrubs every HttpResponse in the result in place. Both the
+// typed Reply and the legacy Any-encoded Response are redacted, because either
+// may be persisted by a results.Store.
+func (r *Redactor) RedactResult(result *schema.CheckResult) error {
+	if r.Empty() {
+		return nil
+	}
+
+	for _, resp := range result.Responses {
+		if resp == nil {
+			continue
+		}
+
+		if reply, ok := resp.Reply.(*schema.CheckResponse_HttpResponse); ok && reply.HttpResponse != nil {
+			r.redactHttpResponse(reply.HttpResponse)
+		}
+
+		if resp.Response == nil {
+			continue
+		}
+
+		any, err := opsee_types.UnmarshalAny(resp.Response)
+		if err != nil {
+			return err
+		}
+
+		httpResponse, ok := any.(*schema.HttpResponse)
+		if !ok {
+			continue
+		}
+
+		r.redactHttpResponse(httpResponse)
+		resp.Response, err = opsee_types.MarshalAny(httpResponse)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RedactCheck scrubs every result attached to a check, e.g. before the check
+// is written as a transition snapshot.
+func (r *Redactor) RedactCheck(check *schema.Check) error {
+	for _, result := range check.Results {
+		if result == nil {
+			continue
+		}
+
+		if err := r.RedactResult(result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Redactor) redactHttpResponse(resp *schema.HttpResponse) {
+	for _, h := range resp.Headers {
+		if h == nil || !r.headers[strings.ToLower(h.Name)] {
+			continue
+		}
+
+		for i := range h.Values {
+			h.Values[i] = RedactedValue
+		}
+	}
+
+	for _, re := range r.patterns {
+		resp.Body = re.ReplaceAllString(resp.Body, RedactedValue)
+	}
+
+	if r.maxBodySize > 0 && len(resp.Body) > r.maxBodySize {
+		// don't cut a multi-byte character in half
+		n := r.maxBodySize
+		for n > 0 && !utf8.RuneStart(resp.Body[n]) {
+			n--
+		}
+		resp.Body = resp.Body[:n]
+	}
+}
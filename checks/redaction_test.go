@@ -0,0 +1,70 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockHttpResult(body string, headers ...*schema.Header) *schema.CheckResult {
+	r := mockResult(1, 0)
+	r.Responses[0].Reply = &schema.CheckResponse_HttpResponse{
+		HttpResponse: &schema.HttpResponse{
+			Code:    200,
+			Body:    body,
+			Headers: headers,
+		},
+	}
+
+	return r
+}
+
+func TestRedactHeaders(t *testing.T) {
+	r := mockHttpResult("ok",
+		&schema.Header{Name: "X-Auth-Token", Values: []string{"secret"}},
+		&schema.Header{Name: "Content-Type", Values: []string{"text/plain"}},
+	)
+
+	redactor, err := NewRedactor([]*RedactionRule{{Headers: []string{"x-auth-token"}}})
+	assert.Nil(t, err)
+	assert.Nil(t, redactor.RedactResult(r))
+
+	resp := r.Responses[0].GetHttpResponse()
+	assert.Equal(t, []string{RedactedValue}, resp.Headers[0].Values)
+	assert.Equal(t, []string{"text/plain"}, resp.Headers[1].Values)
+}
+
+func TestRedactBodyPatternsAndSize(t *testing.T) {
+	r := mockHttpResult(`{"token": "abc123", "status": "ok"}`)
+
+	redactor, err := NewRedactor([]*RedactionRule{
+		{BodyPatterns: []string{`abc[0-9]+`}, MaxBodySize: 100},
+		{MaxBodySize: 20},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, redactor.RedactResult(r))
+
+	assert.Equal(t, `{"token": "[REDACTED`, r.Responses[0].GetHttpResponse().Body)
+}
+
+func TestRedactLegacyResponse(t *testing.T) {
+	r := mockResult(1, 0)
+	any, err := opsee_types.MarshalAny(&schema.HttpResponse{Code: 200, Body: "password=hunter2"})
+	assert.Nil(t, err)
+	r.Responses[0].Response = any
+
+	redactor, err := NewRedactor([]*RedactionRule{{BodyPatterns: []string{`hunter2`}}})
+	assert.Nil(t, err)
+	assert.Nil(t, redactor.RedactResult(r))
+
+	reply, err := opsee_types.UnmarshalAny(r.Responses[0].Response)
+	assert.Nil(t, err)
+	assert.Equal(t, "password="+RedactedValue, reply.(*schema.HttpResponse).Body)
+}
+
+func TestInvalidRedactionPattern(t *testing.T) {
+	_, err := NewRedactor([]*RedactionRule{{BodyPatterns: []string{`(`}}})
+	assert.NotNil(t, err)
+}
@@ -2,20 +2,37 @@ package results
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gogo/protobuf/proto"
 	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks/labels"
 	log "github.com/opsee/logrus"
 )
 
 // S3Store stores CheckResult objects in S3 by ResultId (check_id:bastion_id).
+// If History is set, every result is also kept under the check's history
+// prefix, keyed by its timestamp.
 type S3Store struct {
 	BucketName string
 	S3Client   *s3.S3
+	History    bool
+}
+
+func historyPrefix(checkId string) string {
+	return fmt.Sprintf("%s/history/", checkId)
+}
+
+// History keys sort by timestamp: check_id/history/<unix nanos>-<bastion_id>.pb
+func historyPath(result *schema.CheckResult) string {
+	return fmt.Sprintf("%s%019d-%s.pb", historyPrefix(result.CheckId), result.Timestamp.Time().UnixNano(), result.BastionId)
 }
 
 // GetResultByCheckId gets the latest CheckResult for a Check from persistent storage.
@@ -67,9 +84,87 @@ func (s *S3Store) PutResult(result *schema.CheckResult) error {
 		return err
 	}
 
+	if s.History {
+		_, err = s.S3Client.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(s.BucketName),
+			Key:    aws.String(historyPath(result)),
+			Body:   bytes.NewReader(resultBytes),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// ListResults returns the results for a check kept in its history, with
+// timestamps in [from, to], oldest first.
+func (s *S3Store) ListResults(checkId string, from, to time.Time) ([]*schema.CheckResult, error) {
+	prefix := historyPrefix(checkId)
+
+	var (
+		keys    []string
+		listErr error
+	)
+	err := s.S3Client.ListObjectsPages(&s3.ListObjectsInput{
+		Bucket: aws.String(s.BucketName),
+		Prefix: aws.String(prefix),
+		Marker: aws.String(fmt.Sprintf("%s%019d", prefix, from.UnixNano())),
+	}, func(page *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			key := aws.StringValue(obj.Key)
+			nanos, err := strconv.ParseInt(strings.SplitN(strings.TrimPrefix(key, prefix), "-", 2)[0], 10, 64)
+			if err != nil {
+				listErr = fmt.Errorf("invalid history key: %s", key)
+				return false
+			}
+
+			if nanos > to.UnixNano() {
+				return false
+			}
+
+			keys = append(keys, key)
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if listErr != nil {
+		return nil, listErr
+	}
+
+	results := make([]*schema.CheckResult, 0, len(keys))
+	for _, key := range keys {
+		getObjResp, err := s.S3Client.GetObject(&s3.GetObjectInput{
+			Bucket:              aws.String(s.BucketName),
+			Key:                 aws.String(key),
+			ResponseContentType: aws.String("application/octet-stream"),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		bodyBytes, err := ioutil.ReadAll(getObjResp.Body)
+		getObjResp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		result := &schema.CheckResult{}
+		if err := proto.Unmarshal(bodyBytes, result); err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
 func (s *S3Store) GetCheckSnapshot(transitionId int64, checkId string) (check *schema.Check, err error) {
 	snapshotPath := fmt.Sprintf("%s/snapshots/%d.pb", checkId, transitionId)
 
@@ -116,3 +211,48 @@ func (s *S3Store) PutCheckSnapshot(transitionId int64, check *schema.Check) erro
 
 	return nil
 }
+
+func (s *S3Store) GetCheckSnapshotLabels(transitionId int64, checkId string) (labels.Labels, error) {
+	labelsPath := fmt.Sprintf("%s/snapshots/%d.labels.json", checkId, transitionId)
+
+	getObjResp, err := s.S3Client.GetObject(&s3.GetObjectInput{
+		Bucket:              aws.String(s.BucketName),
+		Key:                 aws.String(labelsPath),
+		ResponseContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bodyBytes, err := ioutil.ReadAll(getObjResp.Body)
+	getObjResp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	l := labels.Labels{}
+	if err := json.Unmarshal(bodyBytes, &l); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (s *S3Store) PutCheckSnapshotLabels(transitionId int64, checkId string, l labels.Labels) error {
+	labelsPath := fmt.Sprintf("%s/snapshots/%d.labels.json", checkId, transitionId)
+	labelsBytes, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.S3Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.BucketName),
+		Key:    aws.String(labelsPath),
+		Body:   bytes.NewReader(labelsBytes),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -1,7 +1,10 @@
 package results
 
 import (
+	"time"
+
 	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks/labels"
 )
 
 // Store is used to store CheckResults and snapshots of checks with results.
@@ -10,4 +13,17 @@ type Store interface {
 	PutResult(result *schema.CheckResult) error
 	GetCheckSnapshot(transitionId int64, checkId string) (*schema.Check, error)
 	PutCheckSnapshot(transitionId int64, check *schema.Check) error
+	// GetCheckSnapshotLabels and PutCheckSnapshotLabels keep the check's
+	// labels alongside its snapshot, since schema.Check has no field for them.
+	GetCheckSnapshotLabels(transitionId int64, checkId string) (labels.Labels, error)
+	PutCheckSnapshotLabels(transitionId int64, checkId string, l labels.Labels) error
+}
+
+// HistoryStore is a Store that also keeps every result it is given, so that
+// results can be replayed.
+type HistoryStore interface {
+	Store
+	// ListResults returns the results for a check with timestamps in
+	// [from, to], oldest first.
+	ListResults(checkId string, from, to time.Time) ([]*schema.CheckResult, error)
 }
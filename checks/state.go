@@ -136,6 +136,14 @@ func callHooks(id StateId, state *State, result *schema.CheckResult) {
 	}
 }
 
+// IsAlertTransition returns true if a transition between two states should
+// notify the customer: a check has finished failing, or finished recovering.
+func IsAlertTransition(from, to StateId) bool {
+	return (from == StateFailWait && to == StateFail) ||
+		(from == StatePassWait && to == StateOK) ||
+		(from == StatePassWait && to == StateWarn)
+}
+
 func (state *State) TimeInState() time.Duration {
 	return state.LastUpdated.Sub(state.TimeEntered)
 }
@@ -144,7 +152,13 @@ func (state *State) TimeInState() time.Duration {
 // proposed change to the current state (a new CheckResult object), update the
 // state for the check associated with the result.
 func (state *State) Transition(result *schema.CheckResult) error {
-	state.LastUpdated = time.Now()
+	return state.TransitionAt(result, time.Now())
+}
+
+// TransitionAt is Transition with now as the current time, for replaying
+// historical results.
+func (state *State) TransitionAt(result *schema.CheckResult, now time.Time) error {
+	state.LastUpdated = now
 
 	sFn, ok := StateFnMap[state.Id]
 	if !ok {
@@ -159,9 +173,8 @@ func (state *State) Transition(result *schema.CheckResult) error {
 	if newSid != state.Id {
 		// hooks should be called on the state _before_ it has been modified.
 		callHooks(newSid, state, result)
-		t := time.Now()
-		state.TimeEntered = t
-		state.LastUpdated = t
+		state.TimeEntered = now
+		state.LastUpdated = now
 	}
 	state.Id = newSid
 	state.State = newSid.String()
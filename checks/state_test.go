@@ -201,3 +201,22 @@ func TestWarnToFailWait(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "FAIL_WAIT", s.State)
 }
+
+func TestTransitionAt(t *testing.T) {
+	entered := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := mockState(StateFailWait, 2, 2, entered, entered, 30*time.Second)
+	r := mockResult(2, 2)
+
+	// still within min_failing_time as of the replayed time
+	err := s.TransitionAt(r, entered.Add(10*time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, "FAIL_WAIT", s.State)
+	assert.Equal(t, entered, s.TimeEntered)
+
+	now := entered.Add(time.Minute)
+	err = s.TransitionAt(r, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "FAIL", s.State)
+	assert.Equal(t, now, s.TimeEntered)
+	assert.Equal(t, now, s.LastUpdated)
+}
@@ -0,0 +1,175 @@
+// Package templates is check templates: a check that is made once for every
+// target its selector matches, e.g. one HTTP check for each of a customer's
+// ELBs. A template's name, HTTP request and assertions may use the target
+// with Go template syntax, e.g. "{{.Target.Name}} health", and each check
+// is kept in sync with the template by planning it like a bundle.
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/template"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks/bundle"
+)
+
+type Template struct {
+	Id         string
+	CustomerId string
+	Name       string
+	// Check is the check made for each target. Its id and target are
+	// ignored.
+	Check   *bundle.Check
+	Targets TargetSelector
+}
+
+// TargetSelector selects the targets a template makes checks for.
+type TargetSelector struct {
+	Type string `json:"type" yaml:"type"`
+	// Ids limits the selector to these targets. Without ids, it matches
+	// every known target of its type.
+	Ids []string `json:"ids,omitempty" yaml:"ids,omitempty"`
+}
+
+// Select returns the targets the selector matches, by id. Known targets
+// name the targets in the selector's ids, which are selected whether or not
+// they are known.
+func (s TargetSelector) Select(known []bundle.Target) []bundle.Target {
+	names := make(map[string]string)
+	for _, t := range known {
+		if t.Type == s.Type && names[t.Id] == "" {
+			names[t.Id] = t.Name
+		}
+	}
+
+	ids := s.Ids
+	if len(ids) == 0 {
+		for id := range names {
+			ids = append(ids, id)
+		}
+	}
+
+	var (
+		targets []bundle.Target
+		seen    = make(map[string]bool, len(ids))
+	)
+
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			targets = append(targets, bundle.Target{Type: s.Type, Id: id, Name: names[id]})
+		}
+	}
+
+	sort.Sort(targetSort(targets))
+	return targets
+}
+
+// Validate checks that the template has a name, a check and a target type,
+// and that its templated fields parse.
+func (t *Template) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("template name is required")
+	}
+
+	if t.Check == nil {
+		return fmt.Errorf("template check is required")
+	}
+
+	if t.Targets.Type == "" {
+		return fmt.Errorf("template target type is required")
+	}
+
+	_, err := t.Render(bundle.Target{Type: t.Targets.Type})
+	return err
+}
+
+// Render returns the template's check for a target.
+func (t *Template) Render(target bundle.Target) (*bundle.Check, error) {
+	// a copy, so that rendering doesn't change the template
+	b, err := json.Marshal(t.Check)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &bundle.Check{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+
+	c.Id = ""
+	c.Target = target
+
+	data := struct{ Target bundle.Target }{target}
+	fields := []*string{&c.Name}
+
+	if c.Http != nil {
+		fields = append(fields, &c.Http.Path, &c.Http.Body)
+		for i := range c.Http.Headers {
+			for j := range c.Http.Headers[i].Values {
+				fields = append(fields, &c.Http.Headers[i].Values[j])
+			}
+		}
+	}
+
+	for i := range c.Assertions {
+		fields = append(fields, &c.Assertions[i].Value, &c.Assertions[i].Operand)
+	}
+
+	for _, field := range fields {
+		if *field, err = render(*field, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func render(text string, data interface{}) (string, error) {
+	tmpl, err := template.New("").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %s", text, err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("invalid template %q: %s", text, err)
+	}
+
+	return buf.String(), nil
+}
+
+// NewPlan plans the changes that make a template's checks, derived, match
+// the template for each of targets. A derived check whose target is still
+// selected is updated in place, so it keeps its id and state.
+func (t *Template) NewPlan(targets []bundle.Target, derived []*schema.Check) (*bundle.Plan, error) {
+	byTarget := make(map[string]string, len(derived))
+	for _, check := range derived {
+		if check.Target != nil {
+			byTarget[check.Target.Id] = check.Id
+		}
+	}
+
+	b := &bundle.Bundle{Version: bundle.Version}
+	for _, target := range targets {
+		c, err := t.Render(target)
+		if err != nil {
+			return nil, err
+		}
+		c.Id = byTarget[target.Id]
+		c.Normalize()
+
+		b.Checks = append(b.Checks, c)
+	}
+
+	return bundle.NewPlan(b, derived)
+}
+
+type targetSort []bundle.Target
+
+func (s targetSort) Len() int           { return len(s) }
+func (s targetSort) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s targetSort) Less(i, j int) bool { return s[i].Id < s[j].Id }
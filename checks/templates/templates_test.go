@@ -0,0 +1,105 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks/bundle"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTemplate() *Template {
+	return &Template{
+		Id:         "template-id",
+		CustomerId: "11111111-1111-1111-1111-111111111111",
+		Name:       "elb health",
+		Check: &bundle.Check{
+			Name:             "{{.Target.Name}} health",
+			ExecutionGroupId: "11111111-1111-1111-1111-111111111111",
+			MinFailingCount:  1,
+			MinFailingTime:   90,
+			Http:             &bundle.HttpSpec{Protocol: "http", Verb: "GET", Port: 80, Path: "/health/{{.Target.Id}}"},
+			Assertions:       []bundle.Assertion{{Key: "code", Relationship: "equal", Operand: "200"}},
+		},
+		Targets: TargetSelector{Type: "elb"},
+	}
+}
+
+func TestSelect(t *testing.T) {
+	assert := assert.New(t)
+
+	known := []bundle.Target{
+		{Type: "elb", Id: "web-elb", Name: "web"},
+		{Type: "sg", Id: "sg-123456"},
+		{Type: "elb", Id: "api-elb", Name: "api"},
+		{Type: "elb", Id: "web-elb", Name: "web"},
+	}
+
+	assert.Equal([]bundle.Target{
+		{Type: "elb", Id: "api-elb", Name: "api"},
+		{Type: "elb", Id: "web-elb", Name: "web"},
+	}, TargetSelector{Type: "elb"}.Select(known))
+
+	assert.Equal([]bundle.Target{
+		{Type: "elb", Id: "new-elb"},
+		{Type: "elb", Id: "web-elb", Name: "web"},
+	}, TargetSelector{Type: "elb", Ids: []string{"web-elb", "new-elb"}}.Select(known))
+}
+
+func TestRender(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpl := testTemplate()
+	assert.NoError(tmpl.Validate())
+
+	c, err := tmpl.Render(bundle.Target{Type: "elb", Id: "web-elb", Name: "web"})
+	assert.NoError(err)
+	assert.Equal("web health", c.Name)
+	assert.Equal("/health/web-elb", c.Http.Path)
+	assert.Equal(bundle.Target{Type: "elb", Id: "web-elb", Name: "web"}, c.Target)
+
+	// the template is left alone
+	assert.Equal("{{.Target.Name}} health", tmpl.Check.Name)
+
+	tmpl.Check.Name = "{{.Target.Nope}}"
+	assert.Error(tmpl.Validate())
+
+	tmpl.Check.Name = "{{.Target.Name"
+	assert.Error(tmpl.Validate())
+}
+
+func TestNewPlan(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpl := testTemplate()
+	web, err := tmpl.Render(bundle.Target{Type: "elb", Id: "web-elb", Name: "web"})
+	assert.NoError(err)
+	web.Id = "web-check"
+
+	gone, err := tmpl.Render(bundle.Target{Type: "elb", Id: "gone-elb", Name: "gone"})
+	assert.NoError(err)
+	gone.Id = "gone-check"
+
+	derived := []*schema.Check{web.Check(tmpl.CustomerId), gone.Check(tmpl.CustomerId)}
+	targets := []bundle.Target{
+		{Type: "elb", Id: "api-elb", Name: "api"},
+		{Type: "elb", Id: "web-elb", Name: "web"},
+	}
+
+	plan, err := tmpl.NewPlan(targets, derived)
+	assert.NoError(err)
+	assert.Len(plan.Create, 1)
+	assert.Equal("api health", plan.Create[0].Name)
+	assert.Empty(plan.Update)
+	assert.Len(plan.Delete, 1)
+	assert.Equal("gone-check", plan.Delete[0].Id)
+	assert.Equal(1, plan.Unchanged)
+
+	// editing the template updates every derived check
+	tmpl.Check.Http.Port = 8080
+	plan, err = tmpl.NewPlan(targets, derived)
+	assert.NoError(err)
+	assert.Len(plan.Update, 1)
+	assert.Equal("web-check", plan.Update[0].Id)
+	assert.Equal(int32(8080), plan.Update[0].Http.Port)
+}
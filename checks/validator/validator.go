@@ -0,0 +1,186 @@
+// Package validator validates CheckResults received from bastions before
+// they are processed. Every rejection carries a typed Reason and is counted
+// in the check_results_rejected metric.
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reason is the machine readable cause of a Rejection.
+type Reason string
+
+const (
+	ReasonMissingCustomerId   Reason = "missing_customer_id"
+	ReasonMissingCheckId      Reason = "missing_check_id"
+	ReasonMissingTimestamp    Reason = "missing_timestamp"
+	ReasonInvalidTimestamp    Reason = "invalid_timestamp"
+	ReasonFutureTimestamp     Reason = "future_timestamp"
+	ReasonInvalidBastionId    Reason = "invalid_bastion_id"
+	ReasonMissingResponse     Reason = "missing_response"
+	ReasonMissingTarget       Reason = "missing_target"
+	ReasonDuplicateTarget     Reason = "duplicate_target"
+	ReasonReplyTypeMismatch   Reason = "reply_type_mismatch"
+	ReasonUnsupportedSpecType Reason = "unsupported_spec_type"
+)
+
+var (
+	// MaxClockSkew is how far in the future a result timestamp may be before
+	// the result is rejected.
+	MaxClockSkew = 5 * time.Minute
+
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+	checkResultsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "check_results_rejected",
+		Help: "Total number of check results rejected by validation, by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	prometheus.MustRegister(checkResultsRejected)
+}
+
+// Rejection is returned when a CheckResult or CheckResponse fails validation.
+type Rejection struct {
+	Reason  Reason
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("%s: %s", r.Reason, r.Message)
+}
+
+// IsRejection returns true if err is a validation Rejection.
+func IsRejection(err error) bool {
+	_, ok := err.(*Rejection)
+	return ok
+}
+
+func reject(reason Reason, format string, args ...interface{}) *Rejection {
+	checkResultsRejected.WithLabelValues(string(reason)).Inc()
+	return &Rejection{
+		Reason:  reason,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// ValidateResult checks that a CheckResult is well formed. It does not need
+// the Check the result belongs to; see ValidateReplies for that.
+func ValidateResult(result *schema.CheckResult) error {
+	if result.CustomerId == "" {
+		return reject(ReasonMissingCustomerId, "result has no customer_id")
+	}
+
+	if result.CheckId == "" {
+		return reject(ReasonMissingCheckId, "result has no check_id")
+	}
+
+	if result.Timestamp == nil {
+		return reject(ReasonMissingTimestamp, "result has no timestamp")
+	}
+
+	if err := result.Timestamp.Validate(); err != nil || result.Timestamp.Seconds <= 0 {
+		return reject(ReasonInvalidTimestamp, "result timestamp is invalid: %v", result.Timestamp)
+	}
+
+	if ts := result.Timestamp.Time(); ts.After(time.Now().Add(MaxClockSkew)) {
+		return reject(ReasonFutureTimestamp, "result timestamp %s is in the future", ts)
+	}
+
+	// Older bastions don't send a bastion id, in which case we fall back to
+	// the customer id.
+	if result.BastionId != "" && !uuidPattern.MatchString(result.BastionId) {
+		return reject(ReasonInvalidBastionId, "bastion_id %q is not a uuid", result.BastionId)
+	}
+
+	targets := make(map[string]bool, len(result.Responses))
+	for i, resp := range result.Responses {
+		if err := ValidateResponse(resp); err != nil {
+			return err
+		}
+
+		id := targetId(resp.Target)
+		if targets[id] {
+			return reject(ReasonDuplicateTarget, "response %d has duplicate target id %q", i, id)
+		}
+		targets[id] = true
+	}
+
+	return nil
+}
+
+// ValidateResponse checks that a CheckResponse is well formed.
+func ValidateResponse(resp *schema.CheckResponse) error {
+	if resp == nil {
+		return reject(ReasonMissingResponse, "response is nil")
+	}
+
+	if resp.Target == nil || targetId(resp.Target) == "" {
+		return reject(ReasonMissingTarget, "response has no target")
+	}
+
+	return nil
+}
+
+// Results older than version 2 identify host targets only by address.
+func targetId(target *schema.Target) string {
+	if target.Id == "" {
+		return target.Address
+	}
+
+	return target.Id
+}
+
+// ValidateReplies checks that the reply in every response is of the type
+// the check's spec produces, e.g. an HttpResponse for an HttpCheck. spec is
+// the typed spec returned by schema.UnmarshalCrappyCheckSpecAnyJSON; a nil
+// spec is not checked. Responses without a reply (errored requests) are
+// skipped.
+func ValidateReplies(result *schema.CheckResult, spec interface{}) error {
+	var want string
+	switch spec.(type) {
+	case nil:
+		return nil
+	case *schema.HttpCheck:
+		want = "HttpResponse"
+	case *schema.CloudWatchCheck:
+		want = "CloudWatchResponse"
+	default:
+		return reject(ReasonUnsupportedSpecType, "check %s has unsupported spec type %T", result.CheckId, spec)
+	}
+
+	for i, resp := range result.Responses {
+		if resp == nil {
+			continue
+		}
+
+		got := replyType(resp)
+		if got != "" && got != want {
+			return reject(ReasonReplyTypeMismatch, "response %d is a %s, check %s expects a %s", i, got, result.CheckId, want)
+		}
+	}
+
+	return nil
+}
+
+func replyType(resp *schema.CheckResponse) string {
+	switch resp.Reply.(type) {
+	case *schema.CheckResponse_HttpResponse:
+		return "HttpResponse"
+	case *schema.CheckResponse_CloudwatchResponse:
+		return "CloudWatchResponse"
+	}
+
+	// legacy responses carry their type name as the Any type url
+	if resp.Response != nil {
+		return resp.Response.TypeUrl
+	}
+
+	return ""
+}
@@ -0,0 +1,79 @@
+package validator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockResult() *schema.CheckResult {
+	return &schema.CheckResult{
+		CheckId:    "check-id",
+		CustomerId: "11111111-1111-1111-1111-111111111111",
+		BastionId:  "61f25e94-4f6e-11e5-a99f-4771161a3518",
+		Timestamp:  opsee_types.NewTimestamp(time.Now()),
+		Responses: []*schema.CheckResponse{
+			{
+				Target: &schema.Target{Type: "instance", Id: "i-1"},
+				Reply:  &schema.CheckResponse_HttpResponse{HttpResponse: &schema.HttpResponse{Code: 200}},
+			},
+			{
+				Target: &schema.Target{Type: "instance", Id: "i-2"},
+				Error:  "connection refused",
+			},
+		},
+	}
+}
+
+func assertReason(t *testing.T, reason Reason, err error) {
+	if assert.NotNil(t, err) && assert.True(t, IsRejection(err)) {
+		assert.Equal(t, reason, err.(*Rejection).Reason)
+	}
+}
+
+func TestValidResult(t *testing.T) {
+	assert.Nil(t, ValidateResult(mockResult()))
+}
+
+func TestRejectedResults(t *testing.T) {
+	r := mockResult()
+	r.CustomerId = ""
+	assertReason(t, ReasonMissingCustomerId, ValidateResult(r))
+
+	r = mockResult()
+	r.Timestamp = nil
+	assertReason(t, ReasonMissingTimestamp, ValidateResult(r))
+
+	r = mockResult()
+	r.Timestamp = opsee_types.NewTimestamp(time.Now().Add(time.Hour))
+	assertReason(t, ReasonFutureTimestamp, ValidateResult(r))
+
+	r = mockResult()
+	r.BastionId = "not-a-bastion"
+	assertReason(t, ReasonInvalidBastionId, ValidateResult(r))
+
+	r = mockResult()
+	r.Responses[1].Target = nil
+	assertReason(t, ReasonMissingTarget, ValidateResult(r))
+
+	r = mockResult()
+	r.Responses[1].Target.Id = "i-1"
+	assertReason(t, ReasonDuplicateTarget, ValidateResult(r))
+}
+
+func TestLegacyHostTargets(t *testing.T) {
+	r := mockResult()
+	r.BastionId = ""
+	r.Responses[0].Target = &schema.Target{Type: "host", Address: "example.com"}
+	assert.Nil(t, ValidateResult(r))
+}
+
+func TestValidateReplies(t *testing.T) {
+	r := mockResult()
+	assert.Nil(t, ValidateReplies(r, nil))
+	assert.Nil(t, ValidateReplies(r, &schema.HttpCheck{}))
+	assertReason(t, ReasonReplyTypeMismatch, ValidateReplies(r, &schema.CloudWatchCheck{}))
+}
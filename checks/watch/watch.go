@@ -0,0 +1,234 @@
+// Package watch fans check state transitions out to subscribers as they
+// happen. The worker publishes each transition with Postgres NOTIFY in the
+// transaction that commits it, so that it is only seen once committed, and a
+// Hub listening on the channel delivers it to the subscribers for the
+// transition's customer.
+package watch
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/opsee/cats/checks"
+	log "github.com/opsee/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+const (
+	// Channel is the Postgres notification channel transitions are
+	// published on.
+	Channel = "check_state_transitions"
+
+	// DefaultBufferSize is the number of events a subscriber may fall
+	// behind by before it is evicted.
+	DefaultBufferSize = 64
+)
+
+var (
+	subscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "check_state_watch_subscribers",
+		Help: "Number of subscribers watching check states.",
+	})
+
+	evictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "check_state_watch_evictions",
+		Help: "Total number of check state watch subscribers evicted.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(subscribers)
+	prometheus.MustRegister(evictions)
+}
+
+// Event is a committed check state transition.
+type Event struct {
+	TransitionId  int64          `json:"transition_id"`
+	CheckId       string         `json:"check_id"`
+	CustomerId    string         `json:"customer_id"`
+	From          checks.StateId `json:"from_state"`
+	To            checks.StateId `json:"to_state"`
+	FailingCount  int32          `json:"failing_count"`
+	ResponseCount int32          `json:"response_count"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// NewEvent returns the event for a logged transition. state must already
+// have transitioned.
+func NewEvent(entry *checks.StateTransitionLogEntry, state *checks.State) *Event {
+	return &Event{
+		TransitionId:  entry.Id,
+		CheckId:       entry.CheckId,
+		CustomerId:    entry.CustomerId,
+		From:          entry.From,
+		To:            entry.To,
+		FailingCount:  state.FailingCount,
+		ResponseCount: state.ResponseCount,
+		CreatedAt:     entry.CreatedAt,
+	}
+}
+
+// Notify publishes event on Channel. q should be the transaction the
+// transition is committed in; Postgres delivers the notification when it
+// commits, and not at all if it rolls back.
+func Notify(q sqlx.Ext, event *Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec("SELECT pg_notify($1, $2)", Channel, string(payload))
+	return err
+}
+
+// Subscription receives a customer's events on C until it is closed, either
+// by Unsubscribe or because the subscriber fell too far behind.
+type Subscription struct {
+	C          <-chan *Event
+	c          chan *Event
+	customerId string
+	evicted    bool
+}
+
+// Evicted returns true if the subscription was closed because its buffer
+// filled up. It may only be called once C is closed.
+func (s *Subscription) Evicted() bool {
+	return s.evicted
+}
+
+// Hub fans events out to subscriptions by customer. Publishing never blocks
+// on a subscriber: one whose buffer is full is evicted instead, and may
+// subscribe again and resynchronize.
+type Hub struct {
+	bufferSize    int
+	mut           sync.Mutex
+	subscriptions map[string]map[*Subscription]struct{}
+}
+
+func NewHub(bufferSize int) *Hub {
+	return &Hub{
+		bufferSize:    bufferSize,
+		subscriptions: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe returns a subscription to customerId's events.
+func (h *Hub) Subscribe(customerId string) *Subscription {
+	c := make(chan *Event, h.bufferSize)
+	sub := &Subscription{
+		C:          c,
+		c:          c,
+		customerId: customerId,
+	}
+
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	subs, ok := h.subscriptions[customerId]
+	if !ok {
+		subs = make(map[*Subscription]struct{})
+		h.subscriptions[customerId] = subs
+	}
+	subs[sub] = struct{}{}
+	subscribers.Inc()
+
+	return sub
+}
+
+// Unsubscribe closes sub, if it hasn't been evicted already.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	h.remove(sub)
+}
+
+// Publish delivers event to its customer's subscriptions.
+func (h *Hub) Publish(event *Event) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	for sub := range h.subscriptions[event.CustomerId] {
+		select {
+		case sub.c <- event:
+		default:
+			log.WithField("customer_id", sub.customerId).Warn("evicting slow check state watcher")
+			sub.evicted = true
+			evictions.Inc()
+			h.remove(sub)
+		}
+	}
+}
+
+// EvictAll evicts every subscription, e.g. when events may have been lost,
+// so that subscribers resynchronize.
+func (h *Hub) EvictAll() {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	for _, subs := range h.subscriptions {
+		for sub := range subs {
+			sub.evicted = true
+			evictions.Inc()
+			h.remove(sub)
+		}
+	}
+}
+
+// Listen publishes the events received by listener, which must already be
+// listening on Channel, until ctx is done.
+func (h *Hub) Listen(ctx context.Context, listener *pq.Listener) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case n := <-listener.Notify:
+			// a nil notification means the connection was re-established,
+			// and notifications sent while it was down are lost
+			if n == nil {
+				log.Warn("check state listener reconnected, evicting watchers")
+				h.EvictAll()
+				continue
+			}
+
+			event := &Event{}
+			if err := json.Unmarshal([]byte(n.Extra), event); err != nil {
+				log.WithError(err).Error("invalid check state notification")
+				continue
+			}
+
+			h.Publish(event)
+
+		case <-time.After(90 * time.Second):
+			go func() {
+				if err := listener.Ping(); err != nil {
+					log.WithError(err).Warn("check state listener ping failed")
+				}
+			}()
+		}
+	}
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	subs, ok := h.subscriptions[sub.customerId]
+	if !ok {
+		return
+	}
+
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscriptions, sub.customerId)
+	}
+
+	close(sub.c)
+	subscribers.Dec()
+}
@@ -0,0 +1,94 @@
+package watch
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/opsee/cats/checks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHubFanOut(t *testing.T) {
+	hub := NewHub(4)
+	a1 := hub.Subscribe("a")
+	a2 := hub.Subscribe("a")
+	b := hub.Subscribe("b")
+
+	hub.Publish(&Event{TransitionId: 1, CustomerId: "a"})
+
+	for _, sub := range []*Subscription{a1, a2} {
+		select {
+		case event := <-sub.C:
+			assert.Equal(t, int64(1), event.TransitionId)
+		default:
+			t.Fatal("expected an event")
+		}
+	}
+
+	select {
+	case <-b.C:
+		t.Fatal("event delivered to another customer")
+	default:
+	}
+
+	hub.Unsubscribe(a1)
+	_, ok := <-a1.C
+	assert.False(t, ok)
+	assert.False(t, a1.Evicted())
+
+	// unsubscribing twice is harmless
+	hub.Unsubscribe(a1)
+
+	hub.Publish(&Event{TransitionId: 2, CustomerId: "a"})
+	event := <-a2.C
+	assert.Equal(t, int64(2), event.TransitionId)
+}
+
+func TestHubEvictsSlowSubscribers(t *testing.T) {
+	hub := NewHub(2)
+	slow := hub.Subscribe("a")
+	fast := hub.Subscribe("a")
+
+	for i := int64(1); i <= 3; i++ {
+		hub.Publish(&Event{TransitionId: i, CustomerId: "a"})
+		if i < 3 {
+			<-fast.C
+		}
+	}
+
+	var received []int64
+	for event := range slow.C {
+		received = append(received, event.TransitionId)
+	}
+	assert.Equal(t, []int64{1, 2}, received)
+	assert.True(t, slow.Evicted())
+
+	event := <-fast.C
+	assert.Equal(t, int64(3), event.TransitionId)
+	assert.False(t, fast.Evicted())
+
+	hub.EvictAll()
+	_, ok := <-fast.C
+	assert.False(t, ok)
+	assert.True(t, fast.Evicted())
+}
+
+func TestEventPayload(t *testing.T) {
+	entry := &checks.StateTransitionLogEntry{
+		Id:         42,
+		CheckId:    "check",
+		CustomerId: "customer",
+		From:       checks.StateOK,
+		To:         checks.StateFailWait,
+		CreatedAt:  time.Unix(1460000000, 0).UTC(),
+	}
+	state := &checks.State{FailingCount: 1, ResponseCount: 3}
+
+	b, err := json.Marshal(NewEvent(entry, state))
+	assert.NoError(t, err)
+
+	event := &Event{}
+	assert.NoError(t, json.Unmarshal(b, event))
+	assert.Equal(t, NewEvent(entry, state), event)
+}
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/opsee/logrus"
+	"golang.org/x/net/context"
+)
+
+type channelSource struct {
+	config   *ChannelSourceConfig
+	queue    chan *Message
+	handlers []Handler
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	logger   *log.Entry
+}
+
+type ChannelSourceConfig struct {
+	BufferSize   int
+	HandlerCount int
+	RequeueDelay time.Duration
+}
+
+// NewChannelSource returns an in-memory ResultSource. Messages are published
+// with Publish and redelivered after RequeueDelay when a handler fails. It is
+// meant for tests and single-process deployments: messages are lost if the
+// process exits.
+func NewChannelSource(config *ChannelSourceConfig) *channelSource {
+	if config.HandlerCount == 0 {
+		config.HandlerCount = 4
+	}
+
+	return &channelSource{
+		config:   config,
+		queue:    make(chan *Message, config.BufferSize),
+		stopChan: make(chan struct{}),
+		logger:   log.WithField("consumer", "channel"),
+	}
+}
+
+// Publish enqueues a serialized CheckResult, blocking while the buffer is full.
+func (s *channelSource) Publish(body []byte) error {
+	select {
+	case <-s.stopChan:
+		return fmt.Errorf("channel source is stopped")
+	default:
+	}
+
+	select {
+	case <-s.stopChan:
+		return fmt.Errorf("channel source is stopped")
+	case s.queue <- &Message{Body: body}:
+		return nil
+	}
+}
+
+func (s *channelSource) AddHandler(handler Handler) {
+	s.handlers = append(s.handlers, handler)
+}
+
+func (s *channelSource) Start() error {
+	for _, h := range s.handlers {
+		for i := 0; i < s.config.HandlerCount; i++ {
+			s.wg.Add(1)
+			go s.run(h)
+		}
+	}
+
+	return nil
+}
+
+func (s *channelSource) run(handler Handler) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case msg := <-s.queue:
+			msg.Attempts++
+			if err := handler(msg); err != nil {
+				s.requeue(msg)
+			}
+		}
+	}
+}
+
+func (s *channelSource) requeue(msg *Message) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		select {
+		case <-s.stopChan:
+		case <-time.After(s.config.RequeueDelay):
+			select {
+			case <-s.stopChan:
+			case s.queue <- msg:
+			}
+		}
+	}()
+}
+
+func (s *channelSource) Stop(ctx context.Context) error {
+	s.logger.Info("stopping")
+	close(s.stopChan)
+	if err := waitGroupDone(ctx, &s.wg); err != nil {
+		s.logger.WithError(err).Error("Timed out waiting for handlers to finish.")
+		return err
+	}
+	s.logger.Info("stopped")
+	return nil
+}
@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestChannelSourceRedelivers(t *testing.T) {
+	source := NewChannelSource(&ChannelSourceConfig{
+		BufferSize:   1,
+		HandlerCount: 1,
+	})
+
+	delivered := make(chan Message, 2)
+	source.AddHandler(func(msg *Message) error {
+		delivered <- *msg
+		if msg.Attempts == 1 {
+			return errors.New("try again")
+		}
+		return nil
+	})
+
+	assert.Nil(t, source.Start())
+	assert.Nil(t, source.Publish([]byte("result")))
+
+	for i := uint16(1); i <= 2; i++ {
+		select {
+		case msg := <-delivered:
+			assert.Equal(t, "result", string(msg.Body))
+			assert.Equal(t, i, msg.Attempts)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for delivery")
+		}
+	}
+
+	assert.Nil(t, source.Stop(context.Background()))
+	assert.NotNil(t, source.Publish([]byte("late")))
+}
+
+func TestChannelSourceStopDeadline(t *testing.T) {
+	source := NewChannelSource(&ChannelSourceConfig{HandlerCount: 1})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	source.AddHandler(func(msg *Message) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	assert.Nil(t, source.Start())
+	assert.Nil(t, source.Publish([]byte("result")))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, source.Stop(ctx))
+	close(release)
+}
@@ -1,20 +1,15 @@
 package worker
 
 import (
-	"time"
-
 	log "github.com/opsee/logrus"
 	"github.com/nsqio/go-nsq"
-	"github.com/opsee/basic/schema"
+	"golang.org/x/net/context"
 )
 
 type nsqConsumer struct {
-	config      *ConsumerConfig
-	consumer    *nsq.Consumer
-	eventChan   chan *schema.CheckResult
-	stopChan    chan struct{}
-	stoppedChan chan struct{}
-	logger      *log.Entry
+	config   *ConsumerConfig
+	consumer *nsq.Consumer
+	logger   *log.Entry
 }
 
 type ConsumerConfig struct {
@@ -25,13 +20,21 @@ type ConsumerConfig struct {
 	HandlerCount     int
 }
 
+// NewNSQConfig returns the nsq config for a result consumer. It leaves
+// retries to DeadLetterHandler: go-nsq would otherwise finish a message that
+// has been attempted more than its own MaxAttempts times, dropping it before
+// it could be dead-lettered.
+func NewNSQConfig(maxInFlight int) *nsq.Config {
+	config := nsq.NewConfig()
+	config.MaxInFlight = maxInFlight
+	config.MaxAttempts = 0
+	return config
+}
+
 func NewConsumer(config *ConsumerConfig) (*nsqConsumer, error) {
 	c := &nsqConsumer{
-		config:      config,
-		stopChan:    make(chan struct{}, 1),
-		stoppedChan: make(chan struct{}, 1),
-		eventChan:   make(chan *schema.CheckResult),
-		logger:      log.WithField("consumer", "nsq"),
+		config: config,
+		logger: log.WithField("consumer", "nsq"),
 	}
 
 	var err error
@@ -52,26 +55,40 @@ func NewConsumer(config *ConsumerConfig) (*nsqConsumer, error) {
 func (c *nsqConsumer) Start() error {
 	if c.config.NSQConfig == nil {
 		c.logger.Info("no nsq config detected, setting max_in_flight to 4")
-		c.config.NSQConfig = nsq.NewConfig()
-		c.config.NSQConfig.MaxInFlight = 4
+		c.config.NSQConfig = NewNSQConfig(4)
 	}
 
 	return c.consumer.ConnectToNSQLookupds(c.config.LookupdAddresses)
 }
 
-func (c *nsqConsumer) Stop() {
+// Stop stops reading from nsqd and waits for in-flight messages to be
+// finished or requeued.
+func (c *nsqConsumer) Stop(ctx context.Context) error {
 	c.logger.Info("stopping")
-	defer close(c.stopChan)
-	defer close(c.stoppedChan)
+	c.consumer.Stop()
 
-	c.stopChan <- struct{}{}
 	select {
-	case <-c.stoppedChan:
-	case <-time.After(5 * time.Second):
+	case <-c.consumer.StopChan:
+	case <-ctx.Done():
+		c.logger.WithError(ctx.Err()).Error("Timed out waiting for handlers to finish.")
+		return ctx.Err()
 	}
+
 	c.logger.Info("stopped")
+	return nil
+}
+
+func (c *nsqConsumer) AddHandler(handler Handler) {
+	c.consumer.AddConcurrentHandlers(nsq.HandlerFunc(func(msg *nsq.Message) error {
+		return handler(&Message{
+			Body:     msg.Body,
+			Attempts: msg.Attempts,
+		})
+	}), c.config.HandlerCount)
 }
 
-func (c *nsqConsumer) AddHandler(handlerFunc func(msg *nsq.Message) error) {
-	c.consumer.AddConcurrentHandlers(nsq.HandlerFunc(handlerFunc), c.config.HandlerCount)
+// SetMaxInFlight changes how many messages nsqd may have outstanding with
+// this consumer.
+func (c *nsqConsumer) SetMaxInFlight(n int) {
+	c.consumer.ChangeMaxInFlight(n)
 }
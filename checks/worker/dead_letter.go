@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"github.com/gogo/protobuf/proto"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/store"
+	log "github.com/opsee/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	checkResultsDeadLettered = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "check_results_dead_lettered",
+		Help: "Total number of check results moved to the dead letter store.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(checkResultsDeadLettered)
+}
+
+// DeadLetterHandler wraps a Handler so that a message which has failed
+// maxAttempts times is written to the dead letter store and acknowledged
+// instead of being redelivered forever.
+func DeadLetterHandler(dlStore store.DeadLetterStore, maxAttempts uint16, handler Handler) Handler {
+	return func(msg *Message) error {
+		err := handler(msg)
+		if err == nil || msg.Attempts < maxAttempts {
+			return err
+		}
+
+		letter := &store.DeadLetter{
+			Body:     msg.Body,
+			Error:    err.Error(),
+			Attempts: int(msg.Attempts),
+		}
+
+		// The body may be the reason we're here, so ids are best effort.
+		result := &schema.CheckResult{}
+		if perr := proto.Unmarshal(msg.Body, result); perr == nil {
+			letter.CustomerId = result.CustomerId
+			letter.CheckId = result.CheckId
+		}
+
+		logger := log.WithFields(log.Fields{
+			"customer_id": letter.CustomerId,
+			"check_id":    letter.CheckId,
+			"attempts":    msg.Attempts,
+		})
+
+		if perr := dlStore.Put(letter); perr != nil {
+			logger.WithError(perr).Error("Error putting message to dead letter store.")
+			return err
+		}
+
+		logger.WithError(err).Errorf("Moved message to dead letter store: %d", letter.Id)
+		checkResultsDeadLettered.Inc()
+		return nil
+	}
+}
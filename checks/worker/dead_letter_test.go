@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/opsee/cats/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDeadLetterStore struct {
+	letters []*store.DeadLetter
+}
+
+func (s *fakeDeadLetterStore) Put(letter *store.DeadLetter) error {
+	letter.Id = int64(len(s.letters) + 1)
+	s.letters = append(s.letters, letter)
+	return nil
+}
+
+func (s *fakeDeadLetterStore) Get(id int64) (*store.DeadLetter, error) {
+	return s.letters[id-1], nil
+}
+
+func (s *fakeDeadLetterStore) List(page, perPage int) ([]*store.DeadLetter, store.ListMeta, error) {
+	return s.letters, store.ListMeta{Page: page, PerPage: perPage, Total: uint64(len(s.letters))}, nil
+}
+
+func (s *fakeDeadLetterStore) Delete(id int64) error {
+	return nil
+}
+
+func TestDeadLetterHandler(t *testing.T) {
+	dlStore := &fakeDeadLetterStore{}
+	handler := DeadLetterHandler(dlStore, 3, func(msg *Message) error {
+		return errors.New("constraint violation")
+	})
+
+	body, err := proto.Marshal(mockResult(1, 1))
+	assert.Nil(t, err)
+
+	// retried until the last attempt
+	assert.NotNil(t, handler(&Message{Body: body, Attempts: 2}))
+	assert.Equal(t, 0, len(dlStore.letters))
+
+	assert.Nil(t, handler(&Message{Body: body, Attempts: 3}))
+	assert.Equal(t, 1, len(dlStore.letters))
+
+	letter := dlStore.letters[0]
+	assert.Equal(t, "check-id", letter.CheckId)
+	assert.Equal(t, "11111111-1111-1111-1111-111111111111", letter.CustomerId)
+	assert.Equal(t, "constraint violation", letter.Error)
+	assert.Equal(t, 3, letter.Attempts)
+
+	// undecodable bodies are still dead lettered
+	assert.Nil(t, handler(&Message{Body: []byte{0xff}, Attempts: 3}))
+	assert.Equal(t, 2, len(dlStore.letters))
+	assert.Equal(t, "", dlStore.letters[1].CheckId)
+}
+
+func TestNSQConfigLeavesRetriesToDeadLetters(t *testing.T) {
+	config := NewNSQConfig(4)
+	assert.Nil(t, config.Validate())
+	assert.Equal(t, 4, config.MaxInFlight)
+
+	// go-nsq finishes messages attempted more than MaxAttempts times without
+	// calling the handler, so a non-zero MaxAttempts below max_attempts would
+	// drop results that should be dead lettered.
+	assert.Equal(t, uint16(0), config.MaxAttempts)
+}
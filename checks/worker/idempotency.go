@@ -0,0 +1,28 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/opsee/basic/schema"
+)
+
+// IdempotencyKey identifies a check result for deduplication. Redeliveries of
+// the same result have the same key; a different result from the same bastion
+// and timestamp does not.
+func IdempotencyKey(result *schema.CheckResult) (string, error) {
+	body, err := proto.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+
+	contentHash := sha256.Sum256(body)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d.%09d\x00", result.CheckId, result.BastionId, result.Timestamp.Seconds, result.Timestamp.Nanos)
+	h.Write(contentHash[:])
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	result := mockResult(2, 1)
+
+	key, err := IdempotencyKey(result)
+	assert.Nil(t, err)
+	assert.Len(t, key, 64)
+
+	// a redelivery of the same result
+	again, err := IdempotencyKey(result)
+	assert.Nil(t, err)
+	assert.Equal(t, key, again)
+
+	// same bastion and timestamp, different content
+	result.Responses[1].Passing = false
+	changed, err := IdempotencyKey(result)
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, changed)
+
+	result.Responses[1].Passing = true
+	result.BastionId = "71f25e94-4f6e-11e5-a99f-4771161a3518"
+	otherBastion, err := IdempotencyKey(result)
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, otherBastion)
+}
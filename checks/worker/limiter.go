@@ -0,0 +1,208 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/opsee/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	concurrencyLimit = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "check_result_concurrency_limit",
+		Help: "Current limit on check results handled concurrently.",
+	})
+
+	concurrencyInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "check_result_concurrency_in_flight",
+		Help: "Number of check results currently being handled.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(concurrencyLimit)
+	prometheus.MustRegister(concurrencyInFlight)
+}
+
+// InFlightSetter is implemented by ResultSources that can change how many
+// messages they have outstanding, e.g. NSQ's max_in_flight.
+type InFlightSetter interface {
+	SetMaxInFlight(n int)
+}
+
+type LimiterConfig struct {
+	// Min and Max bound the limit. Max should not be more than the number
+	// of goroutines calling the limited Handler, e.g. the ResultSource's
+	// HandlerCount or the shard count of a sharded handler.
+	Min int
+	Max int
+	// Initial is the limit before any results have been observed.
+	Initial int
+	// TargetLatency is the mean handler latency over a window above which
+	// the limit is decreased.
+	TargetLatency time.Duration
+	// MaxErrorRate is the fraction of failed handlers over a window above
+	// which the limit is decreased.
+	MaxErrorRate float64
+	// Window is how often the limit is adjusted.
+	Window time.Duration
+	// Backoff is the factor the limit is multiplied by when it is decreased.
+	Backoff float64
+	// OnChange, if set, is called with the new limit whenever it changes.
+	OnChange func(limit int)
+}
+
+// AdaptiveLimiter limits how many check results are handled concurrently. It
+// adjusts the limit with AIMD: every window in which handlers are fast and
+// healthy the limit grows by one, and every window in which the mean latency
+// or error rate is over target the limit is multiplied by Backoff. Latency is
+// dominated by the worker's database transaction, so the limit tracks what
+// Postgres can take.
+type AdaptiveLimiter struct {
+	config *LimiterConfig
+	mut    sync.Mutex
+	cond   *sync.Cond
+
+	limit    int
+	inFlight int
+
+	windowStart time.Time
+	samples     int
+	errors      int
+	latency     time.Duration
+}
+
+func NewAdaptiveLimiter(config *LimiterConfig) *AdaptiveLimiter {
+	if config.Min < 1 {
+		config.Min = 1
+	}
+
+	if config.Max < config.Min {
+		config.Max = config.Min
+	}
+
+	if config.Initial < config.Min || config.Initial > config.Max {
+		config.Initial = config.Min
+	}
+
+	if config.TargetLatency <= 0 {
+		config.TargetLatency = 500 * time.Millisecond
+	}
+
+	if config.MaxErrorRate <= 0 {
+		config.MaxErrorRate = 0.1
+	}
+
+	if config.Backoff <= 0 || config.Backoff >= 1 {
+		config.Backoff = 0.75
+	}
+
+	l := &AdaptiveLimiter{
+		config:      config,
+		limit:       config.Initial,
+		windowStart: time.Now(),
+	}
+	l.cond = sync.NewCond(&l.mut)
+	concurrencyLimit.Set(float64(l.limit))
+
+	return l
+}
+
+// Limit returns the current limit.
+func (l *AdaptiveLimiter) Limit() int {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return l.limit
+}
+
+// Handler wraps a Handler so that at most Limit() calls run at once. Calls
+// over the limit block until a slot is free, which holds messages in the
+// ResultSource instead of in the database.
+func (l *AdaptiveLimiter) Handler(handler Handler) Handler {
+	return func(msg *Message) error {
+		l.acquire()
+		start := time.Now()
+		err := handler(msg)
+		l.release(time.Since(start), err)
+		return err
+	}
+}
+
+func (l *AdaptiveLimiter) acquire() {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	for l.inFlight >= l.limit {
+		l.cond.Wait()
+	}
+	l.inFlight++
+	concurrencyInFlight.Set(float64(l.inFlight))
+}
+
+func (l *AdaptiveLimiter) release(latency time.Duration, err error) {
+	l.mut.Lock()
+
+	l.inFlight--
+	concurrencyInFlight.Set(float64(l.inFlight))
+
+	l.samples++
+	l.latency += latency
+	if err != nil {
+		l.errors++
+	}
+
+	changed := false
+	if time.Since(l.windowStart) >= l.config.Window {
+		changed = l.adjust()
+	}
+	limit := l.limit
+
+	l.cond.Broadcast()
+	l.mut.Unlock()
+
+	if changed && l.config.OnChange != nil {
+		l.config.OnChange(limit)
+	}
+}
+
+// adjust sets the limit from the current window and starts a new one. It
+// must be called with mut held, and returns true if the limit changed.
+func (l *AdaptiveLimiter) adjust() bool {
+	meanLatency := l.latency / time.Duration(l.samples)
+	errorRate := float64(l.errors) / float64(l.samples)
+
+	old := l.limit
+	if meanLatency > l.config.TargetLatency || errorRate > l.config.MaxErrorRate {
+		l.limit = int(float64(l.limit) * l.config.Backoff)
+	} else {
+		l.limit++
+	}
+
+	if l.limit < l.config.Min {
+		l.limit = l.config.Min
+	}
+
+	if l.limit > l.config.Max {
+		l.limit = l.config.Max
+	}
+
+	l.windowStart = time.Now()
+	l.samples = 0
+	l.errors = 0
+	l.latency = 0
+
+	if l.limit == old {
+		return false
+	}
+
+	log.WithFields(log.Fields{
+		"limit":        l.limit,
+		"old_limit":    old,
+		"mean_latency": meanLatency,
+		"error_rate":   errorRate,
+	}).Info("Adjusted check result concurrency limit.")
+	concurrencyLimit.Set(float64(l.limit))
+
+	return true
+}
@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterAIMD(t *testing.T) {
+	var changes []int
+	limiter := NewAdaptiveLimiter(&LimiterConfig{
+		Min:           2,
+		Max:           4,
+		Initial:       3,
+		TargetLatency: 50 * time.Millisecond,
+		MaxErrorRate:  0.5,
+		Backoff:       0.5,
+		OnChange: func(limit int) {
+			changes = append(changes, limit)
+		},
+	})
+
+	ok := limiter.Handler(func(msg *Message) error { return nil })
+	fail := limiter.Handler(func(msg *Message) error { return errors.New("") })
+	slow := limiter.Handler(func(msg *Message) error {
+		time.Sleep(60 * time.Millisecond)
+		return nil
+	})
+
+	// increase by one per healthy window, up to the ceiling
+	ok(&Message{})
+	assert.Equal(t, 4, limiter.Limit())
+	ok(&Message{})
+	assert.Equal(t, 4, limiter.Limit())
+
+	// halve on errors, down to the floor
+	fail(&Message{})
+	assert.Equal(t, 2, limiter.Limit())
+	fail(&Message{})
+	assert.Equal(t, 2, limiter.Limit())
+
+	ok(&Message{})
+	assert.Equal(t, 3, limiter.Limit())
+	slow(&Message{})
+	assert.Equal(t, 2, limiter.Limit())
+
+	assert.Equal(t, []int{4, 2, 3, 2}, changes)
+}
+
+func TestLimiterBlocksOverLimit(t *testing.T) {
+	limiter := NewAdaptiveLimiter(&LimiterConfig{
+		Min:    1,
+		Max:    1,
+		Window: time.Hour,
+	})
+
+	release := make(chan struct{})
+	var mut sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	handler := limiter.Handler(func(msg *Message) error {
+		mut.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mut.Unlock()
+
+		<-release
+
+		mut.Lock()
+		inFlight--
+		mut.Unlock()
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler(&Message{})
+		}()
+	}
+
+	for i := 0; i < 3; i++ {
+		release <- struct{}{}
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, maxInFlight)
+}
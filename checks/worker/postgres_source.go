@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	log "github.com/opsee/logrus"
+	"golang.org/x/net/context"
+)
+
+type postgresSource struct {
+	config   *PostgresSourceConfig
+	handlers []Handler
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	logger   *log.Entry
+}
+
+type PostgresSourceConfig struct {
+	DB           *sqlx.DB
+	HandlerCount int
+	PollInterval time.Duration
+	RequeueDelay time.Duration
+	// VisibilityTimeout is how long a claimed result is hidden from other
+	// handlers. A result whose handler dies is handled again after it.
+	VisibilityTimeout time.Duration
+}
+
+type queuedResult struct {
+	Id       int64  `db:"id"`
+	Body     []byte `db:"body"`
+	Attempts int    `db:"attempts"`
+}
+
+// NewPostgresSource returns a ResultSource backed by the result_queue table.
+// Each handler goroutine claims one row at a time with FOR UPDATE SKIP LOCKED,
+// so any number of pracovnik processes can share the queue. A claim counts
+// an attempt and hides the row for VisibilityTimeout, and is committed
+// before the row is handled, so no lock or connection is held meanwhile.
+func NewPostgresSource(config *PostgresSourceConfig) *postgresSource {
+	if config.HandlerCount == 0 {
+		config.HandlerCount = 4
+	}
+
+	if config.PollInterval == 0 {
+		config.PollInterval = time.Second
+	}
+
+	if config.RequeueDelay == 0 {
+		config.RequeueDelay = 10 * time.Second
+	}
+
+	if config.VisibilityTimeout == 0 {
+		config.VisibilityTimeout = time.Minute
+	}
+
+	return &postgresSource{
+		config:   config,
+		stopChan: make(chan struct{}),
+		logger:   log.WithField("consumer", "postgres"),
+	}
+}
+
+// Publish enqueues a serialized CheckResult.
+func (s *postgresSource) Publish(body []byte) error {
+	_, err := s.config.DB.Exec("INSERT INTO result_queue (body) VALUES ($1)", body)
+	return err
+}
+
+func (s *postgresSource) AddHandler(handler Handler) {
+	s.handlers = append(s.handlers, handler)
+}
+
+func (s *postgresSource) Start() error {
+	for _, h := range s.handlers {
+		for i := 0; i < s.config.HandlerCount; i++ {
+			s.wg.Add(1)
+			go s.run(h)
+		}
+	}
+
+	return nil
+}
+
+func (s *postgresSource) run(handler Handler) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+
+		found, err := s.poll(handler)
+		if err != nil {
+			s.logger.WithError(err).Error("Error polling result queue.")
+		}
+
+		// only sleep when the queue is drained or broken
+		if found && err == nil {
+			continue
+		}
+
+		select {
+		case <-s.stopChan:
+			return
+		case <-time.After(s.config.PollInterval):
+		}
+	}
+}
+
+// poll claims and handles a single queued result, returning false if there
+// was nothing to claim.
+func (s *postgresSource) poll(handler Handler) (bool, error) {
+	row := &queuedResult{}
+	err := s.config.DB.Get(row, `UPDATE result_queue SET attempts = attempts + 1, visible_at = $1
+		WHERE id = (SELECT id FROM result_queue WHERE visible_at <= now() ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED)
+		RETURNING id, body, attempts`, time.Now().Add(s.config.VisibilityTimeout))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if herr := handler(&Message{Body: row.Body, Attempts: uint16(row.Attempts)}); herr != nil {
+		_, err = s.config.DB.Exec("UPDATE result_queue SET visible_at = $2 WHERE id = $1", row.Id, time.Now().Add(s.config.RequeueDelay))
+	} else {
+		_, err = s.config.DB.Exec("DELETE FROM result_queue WHERE id = $1", row.Id)
+	}
+
+	return true, err
+}
+
+func (s *postgresSource) Stop(ctx context.Context) error {
+	s.logger.Info("stopping")
+	close(s.stopChan)
+	if err := waitGroupDone(ctx, &s.wg); err != nil {
+		s.logger.WithError(err).Error("Timed out waiting for handlers to finish.")
+		return err
+	}
+	s.logger.Info("stopped")
+	return nil
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func testSetupResultQueue() *sqlx.DB {
+	db, err := sqlx.Open("postgres", viper.GetString("postgres_conn"))
+	if err != nil {
+		panic(err)
+	}
+
+	db.MustExec("DELETE FROM result_queue")
+	return db
+}
+
+func TestPostgresSourceClaimsBeforeHandling(t *testing.T) {
+	db := testSetupResultQueue()
+	source := NewPostgresSource(&PostgresSourceConfig{DB: db, RequeueDelay: time.Millisecond})
+	assert.Nil(t, source.Publish([]byte("result")))
+
+	// the attempt is committed before the handler runs, so a handler that
+	// dies still counts towards dead lettering
+	var attempts int
+	found, err := source.poll(func(msg *Message) error {
+		assert.Equal(t, "result", string(msg.Body))
+		assert.Equal(t, uint16(1), msg.Attempts)
+		assert.Nil(t, db.Get(&attempts, "SELECT attempts FROM result_queue"))
+		return errors.New("try again")
+	})
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// the claimed result is hidden until the visibility timeout
+	source.config.VisibilityTimeout = time.Hour
+	time.Sleep(10 * time.Millisecond)
+	found, err = source.poll(func(msg *Message) error {
+		assert.Equal(t, uint16(2), msg.Attempts)
+		found, err := source.poll(func(msg *Message) error { return nil })
+		assert.False(t, found)
+		assert.Nil(t, err)
+		return nil
+	})
+	assert.True(t, found)
+	assert.Nil(t, err)
+
+	var count int
+	assert.Nil(t, db.Get(&count, "SELECT count(*) FROM result_queue"))
+	assert.Equal(t, 0, count)
+}
+
+func TestPostgresSourceRedelivers(t *testing.T) {
+	db := testSetupResultQueue()
+	source := NewPostgresSource(&PostgresSourceConfig{
+		DB:           db,
+		HandlerCount: 1,
+		PollInterval: 10 * time.Millisecond,
+		RequeueDelay: time.Millisecond,
+	})
+
+	delivered := make(chan Message, 2)
+	source.AddHandler(func(msg *Message) error {
+		delivered <- *msg
+		if msg.Attempts == 1 {
+			return errors.New("try again")
+		}
+		return nil
+	})
+
+	assert.Nil(t, source.Publish([]byte("result")))
+	assert.Nil(t, source.Start())
+
+	for i := uint16(1); i <= 2; i++ {
+		select {
+		case msg := <-delivered:
+			assert.Equal(t, "result", string(msg.Body))
+			assert.Equal(t, i, msg.Attempts)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for delivery")
+		}
+	}
+
+	assert.Nil(t, source.Stop(context.Background()))
+}
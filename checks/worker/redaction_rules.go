@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/store"
+)
+
+// RedactionRulesTTL is how long a customer's redaction rules are cached
+// before they're read from the db again, so that every result doesn't cost
+// a query. Rule changes take up to this long to apply to new results.
+var RedactionRulesTTL = 30 * time.Second
+
+var redactionRules = &redactionRulesCache{
+	rules: make(map[string]*cachedRedactionRules),
+}
+
+type cachedRedactionRules struct {
+	rules   []*checks.RedactionRule
+	expires time.Time
+}
+
+// redactionRulesCache holds each customer's redaction rules, whatever their
+// scope, and picks out the ones that apply to a check.
+type redactionRulesCache struct {
+	sync.Mutex
+	rules map[string]*cachedRedactionRules
+}
+
+// get returns the redaction rules that apply to a check, reading the
+// customer's rules with checkStore if they aren't cached or have expired.
+func (c *redactionRulesCache) get(checkStore store.CheckStore, customerId, checkId string, now time.Time) ([]*checks.RedactionRule, error) {
+	c.Lock()
+	cached, ok := c.rules[customerId]
+	c.Unlock()
+
+	if !ok || !now.Before(cached.expires) {
+		rules, err := checkStore.GetCustomerRedactionRules(customerId)
+		if err != nil {
+			return nil, err
+		}
+
+		cached = &cachedRedactionRules{rules: rules, expires: now.Add(RedactionRulesTTL)}
+
+		c.Lock()
+		c.rules[customerId] = cached
+		c.Unlock()
+	}
+
+	var rules []*checks.RedactionRule
+	for _, r := range cached.rules {
+		if r.CheckId == "" || r.CheckId == checkId {
+			rules = append(rules, r)
+		}
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type redactionRulesStore struct {
+	store.CheckStore
+	rules []*checks.RedactionRule
+	reads int
+}
+
+func (s *redactionRulesStore) GetCustomerRedactionRules(customerId string) ([]*checks.RedactionRule, error) {
+	s.reads++
+	return s.rules, nil
+}
+
+func TestRedactionRulesCache(t *testing.T) {
+	customerId := "11111111-1111-1111-1111-111111111111"
+	customerRule := &checks.RedactionRule{Id: 1, CustomerId: customerId, Headers: []string{"Authorization"}}
+	checkRule := &checks.RedactionRule{Id: 2, CustomerId: customerId, CheckId: "check-1", MaxBodySize: 64}
+	otherRule := &checks.RedactionRule{Id: 3, CustomerId: customerId, CheckId: "check-2", MaxBodySize: 32}
+
+	s := &redactionRulesStore{rules: []*checks.RedactionRule{customerRule, checkRule, otherRule}}
+	cache := &redactionRulesCache{rules: make(map[string]*cachedRedactionRules)}
+	now := time.Now()
+
+	rules, err := cache.get(s, customerId, "check-1", now)
+	assert.NoError(t, err)
+	assert.Equal(t, []*checks.RedactionRule{customerRule, checkRule}, rules)
+
+	// the customer's rules are cached for every one of its checks
+	rules, err = cache.get(s, customerId, "check-2", now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, []*checks.RedactionRule{customerRule, otherRule}, rules)
+	assert.Equal(t, 1, s.reads)
+
+	s.rules = []*checks.RedactionRule{customerRule}
+	rules, err = cache.get(s, customerId, "check-1", now.Add(RedactionRulesTTL))
+	assert.NoError(t, err)
+	assert.Equal(t, []*checks.RedactionRule{customerRule}, rules)
+	assert.Equal(t, 2, s.reads)
+}
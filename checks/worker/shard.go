@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/opsee/basic/schema"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	shardQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "check_result_shard_queue_depth",
+		Help: "Number of check results waiting to be handled, per shard.",
+	}, []string{"shard"})
+)
+
+func init() {
+	prometheus.MustRegister(shardQueueDepth)
+}
+
+type shardTask struct {
+	msg  *Message
+	done chan error
+}
+
+type shardedHandler struct {
+	handler Handler
+	key     func(msg *Message) string
+	shards  []chan *shardTask
+	depth   []prometheus.Gauge
+}
+
+// NewShardedHandler returns a handler that routes each message to one of
+// shardCount goroutines by the hash of key(msg). Messages with the same key
+// are handled serially, in the order they were routed, while messages with
+// different keys are handled in parallel. Handle blocks until the message
+// has been handled, so the ResultSource acknowledges or redelivers it as
+// usual.
+func NewShardedHandler(shardCount, queueSize int, key func(msg *Message) string, handler Handler) *shardedHandler {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+
+	s := &shardedHandler{
+		handler: handler,
+		key:     key,
+		shards:  make([]chan *shardTask, shardCount),
+		depth:   make([]prometheus.Gauge, shardCount),
+	}
+
+	for i := range s.shards {
+		s.shards[i] = make(chan *shardTask, queueSize)
+		s.depth[i] = shardQueueDepth.WithLabelValues(strconv.Itoa(i))
+		go s.run(i)
+	}
+
+	return s
+}
+
+// CheckIdKey shards messages by the check id of the CheckResult they carry.
+// Messages that can't be decoded all go to the same shard.
+func CheckIdKey(msg *Message) string {
+	result := &schema.CheckResult{}
+	if err := proto.Unmarshal(msg.Body, result); err != nil {
+		return ""
+	}
+
+	return result.CheckId
+}
+
+func (s *shardedHandler) shardFor(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+func (s *shardedHandler) Handle(msg *Message) error {
+	i := s.shardFor(s.key(msg))
+	task := &shardTask{
+		msg:  msg,
+		done: make(chan error, 1),
+	}
+
+	s.shards[i] <- task
+	s.depth[i].Set(float64(len(s.shards[i])))
+
+	return <-task.done
+}
+
+func (s *shardedHandler) run(i int) {
+	for task := range s.shards[i] {
+		s.depth[i].Set(float64(len(s.shards[i])))
+		task.done <- s.handler(task.msg)
+	}
+}
+
+// Stop stops the shard goroutines. It must only be called once the
+// ResultSource has stopped calling Handle.
+func (s *shardedHandler) Stop() {
+	for _, shard := range s.shards {
+		close(shard)
+	}
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedHandlerOrdersByKey(t *testing.T) {
+	var (
+		mut  sync.Mutex
+		seen = map[string][]int{}
+	)
+
+	sharder := NewShardedHandler(4, 8, func(msg *Message) string {
+		return string(msg.Body[:1])
+	}, func(msg *Message) error {
+		mut.Lock()
+		defer mut.Unlock()
+		key := string(msg.Body[:1])
+		seen[key] = append(seen[key], int(msg.Attempts))
+		return nil
+	})
+	defer sharder.Stop()
+
+	// each key is sent from its own goroutine, in order
+	wg := &sync.WaitGroup{}
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			for i := 1; i <= 20; i++ {
+				assert.Nil(t, sharder.Handle(&Message{Body: []byte(key), Attempts: uint16(i)}))
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	for key, order := range seen {
+		assert.Equal(t, 20, len(order), key)
+		for i, n := range order {
+			assert.Equal(t, i+1, n, key)
+		}
+	}
+}
+
+func TestShardForIsStable(t *testing.T) {
+	sharder := NewShardedHandler(8, 0, CheckIdKey, func(msg *Message) error { return nil })
+	defer sharder.Stop()
+
+	assert.Equal(t, sharder.shardFor("check-id"), sharder.shardFor("check-id"))
+}
@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// Message is a single serialized CheckResult delivered by a ResultSource.
+type Message struct {
+	Body []byte
+	// Attempts is the number of times this message has been delivered,
+	// including the current delivery.
+	Attempts uint16
+}
+
+// Handler processes a Message. Returning an error tells the ResultSource
+// to redeliver the message later.
+type Handler func(msg *Message) error
+
+// ResultSource delivers check results to handlers. Implementations must call
+// every added handler with HandlerCount concurrency once started.
+type ResultSource interface {
+	AddHandler(handler Handler)
+	Start() error
+	// Stop stops taking new messages and waits for in-flight handlers to
+	// return. If ctx is done first, Stop returns ctx.Err() and handlers may
+	// still be running.
+	Stop(ctx context.Context) error
+}
+
+// waitGroupDone waits for wg, or returns ctx.Err() if ctx is done first.
+func waitGroupDone(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -7,9 +7,13 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/opsee/basic/schema"
 	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/outbox"
 	"github.com/opsee/cats/checks/results"
+	"github.com/opsee/cats/checks/validator"
+	"github.com/opsee/cats/checks/watch"
 	"github.com/opsee/cats/store"
 	log "github.com/opsee/logrus"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/net/context"
 )
 
@@ -17,13 +21,51 @@ var (
 	logger = log.WithFields(log.Fields{
 		"worker": "check_worker",
 	})
+
+	stateLockWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "check_state_lock_wait_seconds",
+		Help: "Time spent waiting to acquire the check state row lock.",
+	})
+
+	checkResultsDuplicate = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "check_results_duplicate",
+		Help: "Total number of duplicate check results skipped.",
+	})
+
+	// ProcessedResultTTL is how long the idempotency key of a processed
+	// result is kept. Redeliveries after this are deduplicated only by the
+	// result memo.
+	ProcessedResultTTL = 24 * time.Hour
 )
 
+func init() {
+	prometheus.MustRegister(stateLockWait)
+	prometheus.MustRegister(checkResultsDuplicate)
+}
+
 type CheckWorker struct {
 	db          *sqlx.DB
 	context     context.Context
 	result      *schema.CheckResult
 	resultStore results.Store
+	options     *WorkerOptions
+}
+
+// WorkerOptions change how a CheckWorker handles its result. The zero value
+// is what pracovnik uses for live results.
+type WorkerOptions struct {
+	// SkipDedupe processes the result even if its idempotency key has
+	// already been processed, and doesn't record the key.
+	SkipDedupe bool
+	// SkipAlerts doesn't enqueue alerts for transitions. Snapshots are still
+	// enqueued.
+	SkipAlerts bool
+	// SkipWatches doesn't notify check state watchers of transitions, which
+	// they would take for live ones.
+	SkipWatches bool
+	// ReplayTime uses the result's timestamp as the current time, for
+	// replaying historical results in timestamp order.
+	ReplayTime bool
 }
 
 func rollback(logger log.FieldLogger, tx *sqlx.Tx) error {
@@ -42,12 +84,20 @@ func commit(logger log.FieldLogger, tx *sqlx.Tx) error {
 	return err
 }
 
-func NewCheckWorker(db *sqlx.DB, rStore results.Store, result *schema.CheckResult) *CheckWorker {
+// NewCheckWorker returns a worker for a single result. If ctx is cancelled
+// before the worker commits, its transaction is rolled back and Execute
+// returns ctx.Err() so the result is redelivered.
+func NewCheckWorker(ctx context.Context, db *sqlx.DB, rStore results.Store, result *schema.CheckResult) *CheckWorker {
+	return NewCheckWorkerWithOptions(ctx, db, rStore, result, &WorkerOptions{})
+}
+
+func NewCheckWorkerWithOptions(ctx context.Context, db *sqlx.DB, rStore results.Store, result *schema.CheckResult, options *WorkerOptions) *CheckWorker {
 	return &CheckWorker{
 		db:          db,
-		context:     context.Background(),
+		context:     ctx,
 		result:      result,
 		resultStore: rStore,
+		options:     options,
 	}
 }
 
@@ -63,12 +113,39 @@ func (w *CheckWorker) Execute() (interface{}, error) {
 	})
 	logger.Debug("Handling check result")
 
+	if err := w.context.Err(); err != nil {
+		return nil, err
+	}
+
 	tx, err := w.db.Beginx()
 	if err != nil {
 		logger.WithError(err).Error("Cannot open transaction.")
 		return nil, err
 	}
 
+	if !w.options.SkipDedupe {
+		key, err := IdempotencyKey(w.result)
+		if err != nil {
+			logger.WithError(err).Error("Unable to compute idempotency key.")
+			rollback(logger, tx)
+			return nil, err
+		}
+
+		claimed, err := store.NewProcessedResultStore(tx).Claim(key, ProcessedResultTTL)
+		if err != nil {
+			logger.WithError(err).Error("Unable to record idempotency key.")
+			rollback(logger, tx)
+			return nil, err
+		}
+
+		if !claimed {
+			logger.Debug("Skipping duplicate result.")
+			checkResultsDuplicate.Inc()
+			rollback(logger, tx)
+			return nil, nil
+		}
+	}
+
 	checkStore := store.NewCheckStore(tx)
 
 	memo, err := checkStore.GetMemo(w.result.CheckId, w.result.BastionId)
@@ -85,15 +162,60 @@ func (w *CheckWorker) Execute() (interface{}, error) {
 	// We've seen this bastion before, and we have a newer result so we don't
 	// transition. In any other case, we transition.
 	resultTimestamp := time.Unix(w.result.Timestamp.Seconds, int64(w.result.Timestamp.Nanos))
-	if err == nil && (memo.LastUpdated.After(resultTimestamp) || memo.LastUpdated.Equal(resultTimestamp)) {
-		logger.Error("Skipping older result because we have a newer result memo.")
+	if err == nil && !resultTimestamp.After(memo.LastUpdated) {
+		if memo.LastUpdated.Equal(resultTimestamp) {
+			logger.Debug("Skipping result because we have a result memo with the same timestamp.")
+		} else {
+			logger.Warn("Skipping older result because we have a newer result memo.")
+		}
+		rollback(logger, tx)
+		return nil, nil
+	}
+
+	spec, err := checkStore.GetCheckSpec(w.result.CustomerId, w.result.CheckId)
+	if err != nil {
+		rollback(logger, tx)
+
+		// the check has been deleted or soft deleted, return no error so we
+		// don't requeue results
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		logger.WithError(err).Error("Unable to get check spec from DB.")
+		return nil, err
+	}
+
+	if err := validator.ValidateReplies(w.result, spec); err != nil {
+		logger.WithError(err).Error("Rejected check result.")
 		rollback(logger, tx)
 		return nil, nil
 	}
 
+	rules, err := redactionRules.get(checkStore, w.result.CustomerId, w.result.CheckId, time.Now())
+	if err != nil {
+		logger.WithError(err).Error("Unable to get redaction rules from DB.")
+		rollback(logger, tx)
+		return nil, err
+	}
+
+	redactor, err := checks.NewRedactor(rules)
+	if err != nil {
+		logger.WithError(err).Error("Invalid redaction rules.")
+		rollback(logger, tx)
+		return nil, err
+	}
+
+	if err := redactor.RedactResult(w.result); err != nil {
+		logger.WithError(err).Error("Error redacting check result.")
+		rollback(logger, tx)
+		return nil, err
+	}
+
 	err = w.resultStore.PutResult(w.result)
 	if err != nil {
 		logger.WithError(err).Error("Error putting result to result store.")
+		rollback(logger, tx)
 		return nil, err
 	}
 
@@ -101,7 +223,9 @@ func (w *CheckWorker) Execute() (interface{}, error) {
 	memo.ResponseCount = len(w.result.Responses)
 	memo.LastUpdated = resultTimestamp
 
+	lockStart := time.Now()
 	state, err := checkStore.GetAndLockState(w.result.CustomerId, w.result.CheckId)
+	stateLockWait.Observe(time.Since(lockStart).Seconds())
 	if err != nil {
 		logger.WithError(err).Error("Error getting state.")
 		rollback(logger, tx)
@@ -130,7 +254,17 @@ func (w *CheckWorker) Execute() (interface{}, error) {
 	}
 	logger.Debug("Updated state: ", state)
 
-	if err := state.Transition(w.result); err != nil {
+	now := time.Now()
+	if w.options.ReplayTime {
+		now = resultTimestamp
+		// a check without state starts out entered at the wall clock time
+		if state.TimeEntered.After(now) {
+			state.TimeEntered = now
+		}
+	}
+
+	fromState := state.Id
+	if err := state.TransitionAt(w.result, now); err != nil {
 		logger.WithError(err).Error("Error transitioning state.")
 		rollback(logger, tx)
 		return nil, err
@@ -144,14 +278,74 @@ func (w *CheckWorker) Execute() (interface{}, error) {
 	}
 	logger.Debug("State after put state: ", state)
 
-	// still try to store the result even if we couldn't transition
-	// check state?
-	// TODO(greg): should we do this? should we do something else?
+	if state.Id != fromState {
+		if err := w.recordTransition(tx, checkStore, fromState, state, now); err != nil {
+			logger.WithError(err).Error("Error recording state transition.")
+			rollback(logger, tx)
+			return nil, err
+		}
+	}
+
+	if err := w.context.Err(); err != nil {
+		logger.WithError(err).Error("Cancelled before committing check state.")
+		rollback(logger, tx)
+		return nil, err
+	}
 
 	if err := commit(logger, tx); err != nil {
 		logger.WithError(err).Error("Could not commit check state.")
+		return nil, err
 	}
 	logger.Debug("committed state.")
 
 	return nil, nil
 }
+
+// recordTransition writes the transition log entry and the outbox entries
+// for its side effects, and notifies watchers, so that they are committed
+// with the new state.
+func (w *CheckWorker) recordTransition(tx *sqlx.Tx, checkStore store.CheckStore, fromState checks.StateId, state *checks.State, now time.Time) error {
+	logEntry, err := checkStore.CreateStateTransitionLogEntryAt(state.CheckId, state.CustomerId, fromState, state.Id, now)
+	if err != nil {
+		return err
+	}
+
+	event, err := outbox.NewTransitionEvent(logEntry, state, w.result)
+	if err != nil {
+		return err
+	}
+
+	checkLabels, err := checkStore.GetCheckLabels(state.CustomerId, []string{state.CheckId})
+	if err != nil {
+		return err
+	}
+	event.Labels = checkLabels[state.CheckId]
+
+	kinds := []string{outbox.KindSnapshot}
+	if checks.IsAlertTransition(fromState, state.Id) && !w.options.SkipAlerts {
+		kinds = append(kinds, outbox.KindAlert)
+	}
+
+	for _, kind := range kinds {
+		if err := outbox.Enqueue(tx, kind, event); err != nil {
+			return err
+		}
+	}
+
+	if !w.options.SkipWatches {
+		if err := watch.Notify(tx, watch.NewEvent(logEntry, state)); err != nil {
+			return err
+		}
+	}
+
+	logger.WithFields(log.Fields{
+		"transition_id":         logEntry.Id,
+		"old_state":             fromState.String(),
+		"new_state":             state.Id.String(),
+		"failing_count":         state.FailingCount,
+		"result.response_count": len(w.result.Responses),
+		"result.passing":        w.result.Passing,
+	}).Infof("Created StateTransitionLogEntry: %d", logEntry.Id)
+
+	return nil
+}
@@ -6,14 +6,17 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/opsee/basic/schema"
 	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/labels"
 	"github.com/opsee/cats/store"
 	opsee_types "github.com/opsee/protobuf/opseeproto/types"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func mockResult(responseCount, failingCount int) *schema.CheckResult {
@@ -73,12 +76,28 @@ func (s *fakeStore) PutCheckSnapshot(transitionId int64, check *schema.Check) er
 	return nil
 }
 
+func (s *fakeStore) GetCheckSnapshotLabels(transitionId int64, checkId string) (labels.Labels, error) {
+	if s.fail {
+		return nil, errors.New("")
+	}
+
+	return nil, nil
+}
+
+func (s *fakeStore) PutCheckSnapshotLabels(transitionId int64, checkId string, l labels.Labels) error {
+	if s.fail {
+		return errors.New("")
+	}
+
+	return nil
+}
+
 func TestDeletedCheck(t *testing.T) {
 	db := testSetupFixtures()
 	db.MustExec("update checks set deleted = true")
 	result := mockResult(2, 1)
 
-	wrkr := NewCheckWorker(db, &fakeStore{false}, result)
+	wrkr := NewCheckWorker(context.Background(), db, &fakeStore{false}, result)
 	_, err := wrkr.Execute()
 	assert.Nil(t, err)
 	// make sure no check state has been created
@@ -109,7 +128,7 @@ func TestExistingState(t *testing.T) {
 	err := checkStore.PutState(state)
 	assert.Nil(t, err)
 
-	wrkr := NewCheckWorker(db, &fakeStore{false}, result)
+	wrkr := NewCheckWorker(context.Background(), db, &fakeStore{false}, result)
 	_, err = wrkr.Execute()
 	assert.Nil(t, err)
 
@@ -123,6 +142,89 @@ func TestExistingState(t *testing.T) {
 	assert.Equal(t, int32(1), state.FailingCount)
 	assert.Equal(t, int32(2), state.ResponseCount)
 	tx.Commit()
+
+	// the transition and its snapshot are committed with the state
+	var transitions, snapshots int
+	assert.Nil(t, db.Get(&transitions, "select count(*) from check_state_transitions"))
+	assert.Equal(t, 1, transitions)
+	assert.Nil(t, db.Get(&snapshots, "select count(*) from outbox where kind = 'snapshot'"))
+	assert.Equal(t, 1, snapshots)
+}
+
+func TestChannelSourcePipeline(t *testing.T) {
+	db := testSetupFixtures()
+	result := mockResult(2, 2)
+
+	source := NewChannelSource(&ChannelSourceConfig{HandlerCount: 1})
+	done := make(chan error, 1)
+	source.AddHandler(func(msg *Message) error {
+		r := &schema.CheckResult{}
+		if err := proto.Unmarshal(msg.Body, r); err != nil {
+			done <- err
+			return err
+		}
+
+		_, err := NewCheckWorker(context.Background(), db, &fakeStore{false}, r).Execute()
+		done <- err
+		return err
+	})
+	assert.Nil(t, source.Start())
+	defer source.Stop(context.Background())
+
+	body, err := proto.Marshal(result)
+	assert.Nil(t, err)
+	assert.Nil(t, source.Publish(body))
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for result to be handled")
+	}
+
+	var stateName string
+	assert.Nil(t, db.Get(&stateName, "select state_name from check_states where check_id = $1", result.CheckId))
+	assert.Equal(t, "FAIL_WAIT", stateName)
+}
+
+func TestDuplicateResult(t *testing.T) {
+	db := testSetupFixtures()
+	result := mockResult(2, 1)
+
+	for i := 0; i < 2; i++ {
+		wrkr := NewCheckWorker(context.Background(), db, &fakeStore{false}, result)
+		_, err := wrkr.Execute()
+		assert.Nil(t, err)
+	}
+
+	var keys int
+	assert.Nil(t, db.Get(&keys, "select count(*) from processed_results"))
+	assert.Equal(t, 1, keys)
+
+	// a failed result store doesn't record the key, so the redelivery is
+	// processed
+	db.MustExec("DELETE FROM processed_results")
+	db.MustExec("DELETE FROM check_state_memos")
+	wrkr := NewCheckWorker(context.Background(), db, &fakeStore{true}, result)
+	_, err := wrkr.Execute()
+	assert.NotNil(t, err)
+	assert.Nil(t, db.Get(&keys, "select count(*) from processed_results"))
+	assert.Equal(t, 0, keys)
+}
+
+func TestCancelledWorker(t *testing.T) {
+	db := testSetupFixtures()
+	result := mockResult(2, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewCheckWorker(ctx, db, &fakeStore{false}, result).Execute()
+	assert.Equal(t, context.Canceled, err)
+
+	var keys int
+	assert.Nil(t, db.Get(&keys, "select count(*) from processed_results"))
+	assert.Equal(t, 0, keys)
 }
 
 func testSetupFixtures() *sqlx.DB {
@@ -131,6 +233,9 @@ func testSetupFixtures() *sqlx.DB {
 		panic(err)
 	}
 
+	db.MustExec("DELETE FROM outbox")
+	db.MustExec("DELETE FROM processed_results")
+	db.MustExec("DELETE FROM check_state_transitions")
 	db.MustExec("DELETE FROM checks")
 	db.MustExec("DELETE FROM check_states")
 	db.MustExec("DELETE FROM check_state_memos")
@@ -6,11 +6,14 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jmoiron/sqlx"
 	newrelic "github.com/newrelic/go-agent"
 	"github.com/opsee/cats/checks/results"
+	"github.com/opsee/cats/mailer"
 	"github.com/opsee/cats/service"
 	"github.com/opsee/cats/servicer"
 	vapestore "github.com/opsee/cats/servicer/store"
+	"github.com/opsee/cats/store"
 	log "github.com/opsee/logrus"
 	"github.com/opsee/vaper"
 	"github.com/spf13/viper"
@@ -47,6 +50,12 @@ func main() {
 		SlackUrl:    viper.GetString("slack_url"),
 	})
 
+	db, err := sqlx.Open("postgres", viper.GetString("postgres_conn"))
+	if err != nil {
+		log.WithError(err).Fatal("Cannot connect to database.")
+	}
+	mailer.Preferences = store.NewPreferenceStore(db).GetPreferencesByEmail
+
 	resultStore := &results.S3Store{
 		BucketName: viper.GetString("results_s3_bucket"),
 		S3Client:   s3.New(session.New(aws.NewConfig().WithRegion("us-west-2"))),
@@ -64,6 +73,10 @@ func main() {
 		log.WithError(err).Fatal("Unable to start service.")
 	}
 
+	if err := svc.ListenForWatches(viper.GetString("postgres_conn")); err != nil {
+		log.WithError(err).Fatal("Unable to listen for check state transitions.")
+	}
+
 	log.WithError(svc.StartMux(
 		viper.GetString("address"),
 		viper.GetString("cert"),
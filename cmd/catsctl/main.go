@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/opsee/cats/api"
+	log "github.com/opsee/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const usage = `usage: catsctl [flags] <command> [args]
+
+commands:
+  checks export           write the customer's checks as a bundle to stdout
+  checks import <file>    make the customer's checks match a bundle, "-" for stdin
+
+flags:
+`
+
+var (
+	addr       = flag.String("addr", "cats.in.opsee.com:8443", "cats grpc address")
+	customerId = flag.String("customer", "", "customer id (required)")
+	format     = flag.String("format", "yaml", "export format, yaml or json")
+	dryRun     = flag.Bool("dry-run", false, "print the import plan without changing checks")
+)
+
+func main() {
+	viper.SetEnvPrefix("cats")
+	viper.AutomaticEnv()
+
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 || args[0] != "checks" || *customerId == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	token := viper.GetString("token")
+	if token == "" {
+		log.Fatal("Must set CATS_TOKEN environment variable.")
+	}
+
+	conn, err := grpc.Dial(*addr, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+	if err != nil {
+		log.WithError(err).Fatal("Cannot connect to cats.")
+	}
+	defer conn.Close()
+
+	client := api.NewCatsApiClient(conn)
+	ctx := metadata.NewContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+
+	switch args[1] {
+	case "export":
+		err = export(ctx, client)
+	case "import":
+		if len(args) < 3 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = importBundle(ctx, client, args[2])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.WithError(err).Fatal("Command failed.")
+	}
+}
+
+func export(ctx context.Context, client api.CatsApiClient) error {
+	resp, err := client.ExportChecks(ctx, &api.ExportChecksRequest{
+		CustomerId: *customerId,
+		Format:     *format,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(resp.Document)
+	return err
+}
+
+func importBundle(ctx context.Context, client api.CatsApiClient, path string) error {
+	var (
+		doc []byte
+		err error
+	)
+
+	if path == "-" {
+		doc, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		doc, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.ImportChecks(ctx, &api.ImportChecksRequest{
+		CustomerId: *customerId,
+		Document:   doc,
+		DryRun:     *dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tCHECK\tNAME")
+	for _, c := range resp.Changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Action, c.CheckId, c.Name)
+	}
+	w.Flush()
+
+	if resp.DryRun {
+		fmt.Printf("dry run: %d changes planned, %d checks unchanged\n", len(resp.Changes), resp.Unchanged)
+	} else {
+		fmt.Printf("%d changes made, %d checks unchanged\n", len(resp.Changes), resp.Unchanged)
+	}
+
+	return nil
+}
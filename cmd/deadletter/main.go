@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/nsqio/go-nsq"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks/worker"
+	"github.com/opsee/cats/store"
+	log "github.com/opsee/logrus"
+	"github.com/spf13/viper"
+)
+
+const usage = `usage: deadletter <command> [args]
+
+commands:
+  list [page]     list dead lettered check results, newest first
+  inspect <id>    show a dead letter and its decoded check result
+  redrive <id>    republish a dead letter to the result source and delete it
+`
+
+func main() {
+	viper.SetEnvPrefix("cats")
+	viper.AutomaticEnv()
+	viper.SetDefault("result_source", "nsq")
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	db, err := sqlx.Open("postgres", viper.GetString("postgres_conn"))
+	if err != nil {
+		log.WithError(err).Fatal("Cannot connect to database.")
+	}
+	dlStore := store.NewDeadLetterStore(db)
+
+	switch os.Args[1] {
+	case "list":
+		page := 1
+		if len(os.Args) > 2 {
+			page, err = strconv.Atoi(os.Args[2])
+			if err != nil {
+				log.WithError(err).Fatal("Invalid page.")
+			}
+		}
+		err = list(dlStore, page)
+	case "inspect":
+		err = inspect(dlStore, idArg())
+	case "redrive":
+		err = redrive(db, dlStore, idArg())
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.WithError(err).Fatal("Command failed.")
+	}
+}
+
+func idArg() int64 {
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	id, err := strconv.ParseInt(os.Args[2], 10, 64)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid dead letter id.")
+	}
+
+	return id
+}
+
+func list(dlStore store.DeadLetterStore, page int) error {
+	letters, meta, err := dlStore.List(page, 50)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tCUSTOMER\tCHECK\tATTEMPTS\tERROR")
+	for _, l := range letters {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\n", l.Id, l.CreatedAt.Format("2006-01-02 15:04:05"), l.CustomerId, l.CheckId, l.Attempts, l.Error)
+	}
+	w.Flush()
+
+	fmt.Printf("page %d, %d total\n", meta.Page, meta.Total)
+	return nil
+}
+
+func inspect(dlStore store.DeadLetterStore, id int64) error {
+	letter, err := dlStore.Get(id)
+	if err != nil {
+		return err
+	}
+
+	out := map[string]interface{}{
+		"id":          letter.Id,
+		"customer_id": letter.CustomerId,
+		"check_id":    letter.CheckId,
+		"error":       letter.Error,
+		"attempts":    letter.Attempts,
+		"created_at":  letter.CreatedAt,
+	}
+
+	result := &schema.CheckResult{}
+	if err := proto.Unmarshal(letter.Body, result); err != nil {
+		out["decode_error"] = err.Error()
+		out["body"] = letter.Body
+	} else {
+		out["result"] = result
+	}
+
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(b))
+	return nil
+}
+
+func redrive(db *sqlx.DB, dlStore store.DeadLetterStore, id int64) error {
+	letter, err := dlStore.Get(id)
+	if err != nil {
+		return err
+	}
+
+	switch viper.GetString("result_source") {
+	case "nsq":
+		producer, err := nsq.NewProducer(viper.GetString("nsqd_host"), nsq.NewConfig())
+		if err != nil {
+			return err
+		}
+		defer producer.Stop()
+
+		if err := producer.Publish("_.results", letter.Body); err != nil {
+			return err
+		}
+	case "postgres":
+		source := worker.NewPostgresSource(&worker.PostgresSourceConfig{DB: db})
+		if err := source.Publish(letter.Body); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown result source: %s", viper.GetString("result_source"))
+	}
+
+	if err := dlStore.Delete(letter.Id); err != nil {
+		return err
+	}
+
+	fmt.Printf("re-drove dead letter %d\n", letter.Id)
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"database/sql"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/jmoiron/sqlx"
+	"github.com/nsqio/go-nsq"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/outbox"
+	"github.com/opsee/cats/checks/results"
+	"github.com/opsee/cats/service"
+	"github.com/opsee/cats/store"
+	log "github.com/opsee/logrus"
+	"golang.org/x/net/context"
+)
+
+// resultsTimeoutMs is how long to wait for a check's results from the
+// result store.
+const resultsTimeoutMs = 30000
+
+func eventLogger(event *outbox.TransitionEvent) log.FieldLogger {
+	return log.WithFields(log.Fields{
+		"customer_id":   event.CustomerId,
+		"check_id":      event.CheckId,
+		"transition_id": event.TransitionId,
+		"old_state":     event.From.String(),
+		"new_state":     event.To.String(),
+	})
+}
+
+// transitionResults returns the latest results for the check, with the result
+// for the transitioning bastion replaced by the one that caused the
+// transition. If partial, results that can't be got are left out and
+// logged instead of failing.
+func transitionResults(ctx context.Context, catsSvc api.CatsApiServer, event *outbox.TransitionEvent, partial bool) ([]*schema.CheckResult, error) {
+	result, err := event.CheckResult()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsResp, err := catsSvc.ListCheckResults(service.TrustedContext(ctx), &api.ListCheckResultsRequest{
+		CustomerId: event.CustomerId,
+		CheckId:    event.CheckId,
+		Partial:    partial,
+		TimeoutMs:  resultsTimeoutMs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range resultsResp.Errors {
+		eventLogger(event).WithField("bastion_id", e.BastionId).Warnf("Leaving out result that couldn't be got: %s", e.Error)
+	}
+
+	var (
+		results  = resultsResp.Results
+		replaced bool
+	)
+
+	for i, r := range results {
+		if result.BastionId == r.BastionId {
+			results[i] = result
+			replaced = true
+		}
+	}
+
+	// the transitioning bastion's stored result may be the one missing
+	if !replaced {
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// snapshotDeliverer writes the redacted check snapshot for a transition to
+// the result store.
+func snapshotDeliverer(db *sqlx.DB, catsSvc api.CatsApiServer, s3Store *results.S3Store) outbox.DeliveryFunc {
+	return func(ctx context.Context, event *outbox.TransitionEvent) error {
+		logger := eventLogger(event)
+
+		// one missing result shouldn't keep the snapshot from being written
+		results, err := transitionResults(ctx, catsSvc, event, true)
+		if err != nil {
+			logger.WithError(err).Error("Error getting results for check")
+			return err
+		}
+
+		checkStore := store.NewCheckStore(db)
+		check, err := checkStore.GetCheck(&schema.User{CustomerId: event.CustomerId}, event.CheckId)
+		if err != nil {
+			// the check has since been deleted, there's nothing to snapshot
+			if err == sql.ErrNoRows {
+				logger.Info("Check deleted, dropping transition snapshot.")
+				return nil
+			}
+
+			logger.WithError(err).Error("Error getting check from db: ", event.CheckId)
+			return err
+		}
+		check.Results = results
+		check.State = event.To.String()
+		check.FailingCount = event.FailingCount
+		check.ResponseCount = event.ResponseCount
+
+		rules, err := checkStore.GetRedactionRules(event.CustomerId, event.CheckId)
+		if err != nil {
+			logger.WithError(err).Error("Error getting redaction rules from db")
+			return err
+		}
+
+		redactor, err := checks.NewRedactor(rules)
+		if err != nil {
+			logger.WithError(err).Error("Invalid redaction rules")
+			return err
+		}
+
+		if err := redactor.RedactCheck(check); err != nil {
+			logger.WithError(err).Error("Error redacting transition snapshot")
+			return err
+		}
+
+		if err := s3Store.PutCheckSnapshot(event.TransitionId, check); err != nil {
+			logger.WithError(err).Error("Error putting transition snapshot to s3")
+			return err
+		}
+
+		if err := s3Store.PutCheckSnapshotLabels(event.TransitionId, event.CheckId, event.Labels); err != nil {
+			logger.WithError(err).Error("Error putting transition snapshot labels to s3")
+			return err
+		}
+
+		return nil
+	}
+}
+
+// alertDeliverer publishes the result for an alerting transition to the NSQ
+// alerts topic.
+func alertDeliverer(catsSvc api.CatsApiServer, producer *nsq.Producer) outbox.DeliveryFunc {
+	return func(ctx context.Context, event *outbox.TransitionEvent) error {
+		logger := eventLogger(event)
+		logger.Info("Sending alert.")
+
+		results, err := transitionResults(ctx, catsSvc, event, false)
+		if err != nil {
+			logger.WithError(err).Error("Error getting results for check")
+			return err
+		}
+
+		var alertResult *schema.CheckResult
+		// Pick the first failing result or the first passing.
+		for _, r := range results {
+			if r.Passing == (event.To != checks.StateFail) {
+				alertResult = r
+				break
+			}
+		}
+		if alertResult == nil {
+			logger.Error("Could not find an appropriate result to send to alert.")
+			return nil
+		}
+
+		resultBytes, err := proto.Marshal(alertResult)
+		if err != nil {
+			logger.WithError(err).Error("Unable to marshal Check to protobuf")
+			return err
+		}
+
+		if err := producer.Publish("alerts", resultBytes); err != nil {
+			logger.WithError(err).Error("Error publishing alert to NSQ.")
+			return err
+		}
+
+		return nil
+	}
+}
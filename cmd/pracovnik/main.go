@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/tls"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,16 +15,21 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gogo/protobuf/proto"
 	"github.com/jmoiron/sqlx"
+	"github.com/keighl/mandrill"
 	_ "github.com/lib/pq"
 	newrelic "github.com/newrelic/go-agent"
 	"github.com/nsqio/go-nsq"
 	"github.com/opsee/basic/schema"
-	opsee "github.com/opsee/basic/service"
-	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/outbox"
 	"github.com/opsee/cats/checks/results"
+	"github.com/opsee/cats/checks/validator"
 	"github.com/opsee/cats/checks/worker"
+	"github.com/opsee/cats/jobs/slack"
+	"github.com/opsee/cats/mailer"
+	"github.com/opsee/cats/notifications"
 	"github.com/opsee/cats/service"
 	"github.com/opsee/cats/store"
+	"github.com/opsee/gmunch/client"
 	log "github.com/opsee/logrus"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
@@ -52,42 +59,47 @@ func main() {
 	}
 	log.SetLevel(logLevel)
 
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.WithError(err).Error("Error getting hostname.")
+	}
+
 	go func() {
-		hostname, err := os.Hostname()
-		if err != nil {
-			log.WithError(err).Error("Error getting hostname.")
+		if hostname == "" {
 			return
 		}
 
 		ticker := time.Tick(5 * time.Second)
 		for {
 			<-ticker
-			err = prometheus.Push("pracovnik", hostname, "172.30.35.35:9091")
-			if err != nil {
-				log.WithError(err).Error("Error pushing to pushgateway.")
-			}
+			pushMetrics(hostname)
 		}
 	}()
 
-	nsqConfig := nsq.NewConfig()
-	nsqConfig.MaxInFlight = 4
+	// ctx is cancelled if in-flight work hasn't finished within
+	// shutdown_timeout of SIGTERM.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nsqConfig := worker.NewNSQConfig(4)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
+	// Results start out handled with concurrency_initial concurrency, by
+	// default max_tasks. From there, the limit adapts to handler latency and
+	// errors between concurrency_min and concurrency_max, so that it grows
+	// while Postgres keeps up and a reconnect storm backs off instead of
+	// overwhelming it.
+	viper.SetDefault("max_tasks", 4)
 	maxTasks := viper.GetInt("max_tasks")
-
-	consumer, err := worker.NewConsumer(&worker.ConsumerConfig{
-		Topic:            "_.results",
-		Channel:          "dynamo-results-worker",
-		LookupdAddresses: viper.GetStringSlice("nsqlookupd_addrs"),
-		NSQConfig:        nsqConfig,
-		HandlerCount:     maxTasks,
-	})
-
-	if err != nil {
-		log.WithError(err).Fatal("Failed to create consumer.")
-	}
+	viper.SetDefault("concurrency_min", 1)
+	viper.SetDefault("concurrency_initial", maxTasks)
+	viper.SetDefault("concurrency_max", 4*maxTasks)
+	viper.SetDefault("concurrency_target_latency", "500ms")
+	viper.SetDefault("concurrency_max_error_rate", 0.1)
+	viper.SetDefault("concurrency_window", "5s")
+	concurrencyMax := viper.GetInt("concurrency_max")
 
 	nsqdHost := viper.GetString("nsqd_host")
 	producer, err := nsq.NewProducer(nsqdHost, nsqConfig)
@@ -100,10 +112,42 @@ func main() {
 		log.WithError(err).Fatal("Cannot connect to database.")
 	}
 
+	// result_source selects where check results are consumed from: "nsq" (the
+	// default) or "postgres" for deployments without an NSQ cluster.
+	viper.SetDefault("result_source", "nsq")
+
+	var consumer worker.ResultSource
+	switch viper.GetString("result_source") {
+	case "nsq":
+		consumer, err = worker.NewConsumer(&worker.ConsumerConfig{
+			Topic:            "_.results",
+			Channel:          "dynamo-results-worker",
+			LookupdAddresses: viper.GetStringSlice("nsqlookupd_addrs"),
+			NSQConfig:        nsqConfig,
+			HandlerCount:     concurrencyMax,
+		})
+	case "postgres":
+		consumer = worker.NewPostgresSource(&worker.PostgresSourceConfig{
+			DB:           db,
+			HandlerCount: concurrencyMax,
+		})
+	default:
+		log.Fatalf("Unknown result source: %s", viper.GetString("result_source"))
+	}
+
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create consumer.")
+	}
+
 	awsSession := session.New(&aws.Config{Region: aws.String("us-west-2")})
+	// With results_history, every result is also kept in the results history
+	// so that check state can be rebuilt with cmd/reprocess. It doubles the
+	// S3 PUTs for results, so it is off by default.
+	viper.SetDefault("results_history", false)
 	s3Store := &results.S3Store{
 		S3Client:   s3.New(awsSession),
 		BucketName: viper.GetString("results_s3_bucket"),
+		History:    viper.GetBool("results_history"),
 	}
 
 	agentConfig := newrelic.NewConfig("Cats", viper.GetString("newrelic_key"))
@@ -118,10 +162,51 @@ func main() {
 		log.WithError(err).Fatal("Can't create cats service")
 	}
 
-	consumer.AddHandler(func(msg *nsq.Message) error {
+	// Results that fail max_attempts times are moved to the dead letter store
+	// so that they can be inspected and re-driven with cmd/deadletter.
+	viper.SetDefault("max_attempts", 10)
+	maxAttempts := uint16(viper.GetInt("max_attempts"))
+	deadLetterStore := store.NewDeadLetterStore(db)
+
+	// Results are routed to shard_count goroutines by check id, so that
+	// results for a single check are handled in order and don't contend on
+	// the check state lock.
+	viper.SetDefault("shard_count", 16)
+	viper.SetDefault("shard_queue_size", 64)
+	viper.SetDefault("outbox_poll_interval", "1s")
+
+	// Idempotency keys of processed results are kept for dedupe_ttl, so that
+	// redelivered results are skipped.
+	viper.SetDefault("dedupe_ttl", "24h")
+	worker.ProcessedResultTTL = viper.GetDuration("dedupe_ttl")
+
+	// The limiter is applied in the shard goroutines, so that it measures
+	// handling results and not waiting behind other results for the same
+	// check. Its limit can't usefully be more than shard_count.
+	limiterConfig := &worker.LimiterConfig{
+		Min:           viper.GetInt("concurrency_min"),
+		Max:           concurrencyMax,
+		Initial:       viper.GetInt("concurrency_initial"),
+		TargetLatency: viper.GetDuration("concurrency_target_latency"),
+		MaxErrorRate:  viper.GetFloat64("concurrency_max_error_rate"),
+		Window:        viper.GetDuration("concurrency_window"),
+	}
+
+	// keep nsqd from sending more than we're willing to handle
+	setter, ok := consumer.(worker.InFlightSetter)
+	if ok {
+		limiterConfig.OnChange = setter.SetMaxInFlight
+	}
+
+	limiter := worker.NewAdaptiveLimiter(limiterConfig)
+	if ok {
+		setter.SetMaxInFlight(limiter.Limit())
+	}
+
+	sharder := worker.NewShardedHandler(viper.GetInt("shard_count"), viper.GetInt("shard_queue_size"), worker.CheckIdKey, limiter.Handler(func(msg *worker.Message) error {
 		result := &schema.CheckResult{}
 		if err := proto.Unmarshal(msg.Body, result); err != nil {
-			log.WithError(err).Error("Error unmarshalling message from NSQ.")
+			log.WithError(err).Error("Error unmarshalling check result message.")
 			return err
 		}
 
@@ -131,13 +216,12 @@ func main() {
 			"bastion_id":  result.BastionId,
 		})
 
-		// TODO(greg): CheckResult objects should probably have a validator.
-		if result.CustomerId == "" || result.CheckId == "" {
-			logger.Error("Received invalid check result.")
+		if err := validator.ValidateResult(result); err != nil {
+			logger.WithError(err).Error("Received invalid check result.")
 			return nil
 		}
 
-		task := worker.NewCheckWorker(db, s3Store, result)
+		task := worker.NewCheckWorker(ctx, db, s3Store, result)
 		_, err = task.Execute()
 		if err != nil {
 			logger.WithError(err).Error("Error executing task.")
@@ -146,110 +230,143 @@ func main() {
 
 		checkResultsHandled.Inc()
 		return nil
-	})
-
-	checks.AddHook(func(newStateID checks.StateId, state *checks.State, result *schema.CheckResult) {
-		logger := log.WithFields(log.Fields{
-			"customer_id":           state.CustomerId,
-			"check_id":              state.CheckId,
-			"min_failing_count":     state.MinFailingCount,
-			"min_failing_time":      state.MinFailingTime,
-			"failing_count":         state.FailingCount,
-			"failing_time_s":        state.TimeInState().Seconds(),
-			"old_state":             state.Id.String(),
-			"new_state":             newStateID.String(),
-			"bastion_id":            result.BastionId,
-			"result.response_count": len(result.Responses),
-			"result.passing":        result.Passing,
-			"result.failing_count":  result.FailingCount(),
-			"result.timestamp":      result.Timestamp.String(),
-		})
-
-		checkStore := store.NewCheckStore(db)
-
-		logEntry, err := checkStore.CreateStateTransitionLogEntry(state.CheckId, state.CustomerId, state.Id, newStateID)
-		if err != nil {
-			logger.WithError(err).Error("Error creating StateTransitionLogEntry")
-		}
+	}))
 
-		logger.Infof("Created StateTransitionLogEntry: %d", logEntry.Id)
+	consumer.AddHandler(worker.DeadLetterHandler(deadLetterStore, maxAttempts, sharder.Handle))
 
-		resultsResp, err := catsSvc.GetCheckResults(context.Background(), &opsee.GetCheckResultsRequest{
-			CustomerId: state.CustomerId,
-			CheckId:    state.CheckId,
+	// Snapshots and alerts for state transitions are written to the outbox
+	// in the worker's transaction and delivered from here, so that they are
+	// retried until they succeed.
+	relay := outbox.NewRelay(&outbox.RelayConfig{
+		DB:           db,
+		PollInterval: viper.GetDuration("outbox_poll_interval"),
+	})
+	relay.Handle(outbox.KindSnapshot, snapshotDeliverer(db, catsSvc, s3Store))
+
+	// alert_routing selects how alerts are delivered: "nsq" (the default)
+	// publishes them to the alerts topic, "cats" sends them to the check's
+	// notifications from here.
+	viper.SetDefault("alert_routing", "nsq")
+
+	var router *notifications.Router
+	switch viper.GetString("alert_routing") {
+	case "nsq":
+		relay.Handle(outbox.KindAlert, alertDeliverer(catsSvc, producer))
+	case "cats":
+		mailer.Client = mandrill.ClientWithKey(viper.GetString("mandrill_key"))
+		mailer.BaseURL = viper.GetString("opsee_host")
+		mailer.Preferences = store.NewPreferenceStore(db).GetPreferencesByEmail
+
+		// Each team is sent up to notification_rate alerts per
+		// notification_rate_window, and digests past that.
+		viper.SetDefault("notification_rate", 10)
+		viper.SetDefault("notification_rate_window", "5m")
+		viper.SetDefault("notification_digest_interval", "5m")
+
+		router = notifications.NewRouter(&notifications.RouterConfig{
+			DB: db,
+			Governor: notifications.NewGovernor(&notifications.GovernorConfig{
+				Rate:           viper.GetInt("notification_rate"),
+				Window:         viper.GetDuration("notification_rate_window"),
+				DigestInterval: viper.GetDuration("notification_digest_interval"),
+			}),
+		})
+		httpClient := &http.Client{Timeout: 10 * time.Second}
+		router.Register("email", &notifications.EmailNotifier{}, notifications.DefaultRetryPolicy)
+		router.Register("slack_webhook", &notifications.SlackWebhookNotifier{Client: httpClient}, notifications.DefaultRetryPolicy)
+		router.Register("webhook", &notifications.WebhookNotifier{
+			Client:   httpClient,
+			Secret:   store.NewNotificationStore(db).GetWebhookSecret,
+			Breaker:  notifications.NewCircuitBreaker(5, time.Minute),
+			LinkBase: viper.GetString("opsee_host"),
+		}, notifications.RetryPolicy{
+			MaxAttempts:    20,
+			InitialBackoff: 10 * time.Second,
+			MaxBackoff:     30 * time.Minute,
 		})
 
-		if err != nil {
-			logger.WithError(err).Error("Error getting results for check")
-			return
-		}
-		results := resultsResp.Results
-		// What we just got from the check store has the old result object for this
-		// bastion. Replace it with the result from the transition.
-		for i, r := range results {
-			if result.BastionId == r.BastionId {
-				results[i] = result
-			}
-		}
+		router.Register("pagerduty", &notifications.PagerDutyNotifier{Client: httpClient}, notifications.DefaultRetryPolicy)
 
-		check, err := checkStore.GetCheck(&schema.User{CustomerId: state.CustomerId}, state.CheckId)
-		if err != nil {
-			logger.WithError(err).Error("Error getting check from db: ", state.CheckId)
-			return
+		// slack_bot notifications are queued for sluice, which posts them
+		// to the team's Slack. Without it they couldn't be delivered.
+		sluiceAddress := viper.GetString("sluice_address")
+		if sluiceAddress == "" {
+			log.Fatal("Must set CATS_SLUICE_ADDRESS to route alerts from cats.")
 		}
-		check.Results = results
-		check.State = state.State
-		check.FailingCount = state.FailingCount
-		check.ResponseCount = state.ResponseCount
-
-		err = s3Store.PutCheckSnapshot(logEntry.Id, check)
+		sluice, err := client.New(sluiceAddress, client.Config{TLSConfig: tls.Config{}})
 		if err != nil {
-			logger.WithError(err).Error("Error putting transition snapshot to s3")
-			return
+			log.WithError(err).Fatal("Cannot connect to sluice.")
 		}
+		router.Register("slack_bot", &slack.Notifier{Publisher: sluice}, notifications.DefaultRetryPolicy)
+		router.Start(ctx)
 
-		if (state.Id == checks.StateFailWait && newStateID == checks.StateFail) ||
-			(state.Id == checks.StatePassWait && newStateID == checks.StateOK) ||
-			(state.Id == checks.StatePassWait && newStateID == checks.StateWarn) {
-			logger.Info("Sending alert.")
-
-			var alertResult *schema.CheckResult
-			// Pick the first failing result or the first passing.
-			if newStateID == checks.StateFail {
-				for _, r := range results {
-					if !r.Passing {
-						alertResult = r
-						break
-					}
-				}
-			} else {
-				for _, r := range results {
-					if r.Passing {
-						alertResult = r
-						break
-					}
-				}
-			}
-			if alertResult == nil {
-				logger.Error("Could not find an appropriate result to send to alert.")
-				return
-			}
+		relay.Handle(outbox.KindAlert, router.Route)
+	default:
+		log.Fatalf("Unknown alert routing: %s", viper.GetString("alert_routing"))
+	}
+
+	relay.Start(ctx)
 
-			resultBytes, err := proto.Marshal(alertResult)
+	go func() {
+		processedResultStore := store.NewProcessedResultStore(db)
+		for range time.Tick(10 * time.Minute) {
+			n, err := processedResultStore.Purge()
 			if err != nil {
-				logger.WithError(err).Error("Unable to marshal Check to protobuf")
-			}
-			if err := producer.Publish("alerts", resultBytes); err != nil {
-				logger.WithError(err).Error("Error publishing alert to NSQ.")
+				log.WithError(err).Error("Error purging processed result keys.")
+				continue
 			}
+			log.Debugf("Purged %d processed result keys.", n)
 		}
-	})
+	}()
 
 	if err := consumer.Start(); err != nil {
 		log.WithError(err).Fatal("Failed to start consumer.")
 	}
 
 	<-sigChan
+	log.Info("Shutting down.")
+
+	viper.SetDefault("shutdown_timeout", "30s")
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), viper.GetDuration("shutdown_timeout"))
+	defer drainCancel()
+
+	// Stop taking new results and wait for in-flight workers. Shards can only
+	// be stopped once nothing is calling Handle.
+	if err := consumer.Stop(drainCtx); err != nil {
+		log.WithError(err).Error("In-flight check results did not finish, cancelling.")
+		cancel()
+	} else {
+		sharder.Stop()
+	}
+
+	if err := relay.Stop(drainCtx); err != nil {
+		log.WithError(err).Error("In-flight outbox deliveries did not finish, cancelling.")
+		cancel()
+	}
+
+	if router != nil {
+		if err := router.Stop(drainCtx); err != nil {
+			log.WithError(err).Error("In-flight notifications did not finish, cancelling.")
+			cancel()
+		}
+	}
+
+	producer.Stop()
 
-	consumer.Stop()
+	if hostname != "" {
+		pushMetrics(hostname)
+	}
+
+	if err := db.Close(); err != nil {
+		log.WithError(err).Error("Error closing database.")
+	}
+
+	log.Info("Shut down.")
+}
+
+func pushMetrics(hostname string) {
+	err := prometheus.Push("pracovnik", hostname, "172.30.35.35:9091")
+	if err != nil {
+		log.WithError(err).Error("Error pushing to pushgateway.")
+	}
 }
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks/labels"
+	"github.com/opsee/cats/checks/results"
+	"github.com/opsee/cats/checks/worker"
+	log "github.com/opsee/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+// Tables rebuilt by replaying results. Shadow schemas get an empty copy of
+// each; everything else is read from public.
+var shadowTables = []string{
+	"check_states",
+	"check_state_memos",
+	"check_state_transitions",
+	"outbox",
+	"processed_results",
+}
+
+// discardStore is a results.Store that doesn't write, so that replayed
+// results don't overwrite the latest results.
+type discardStore struct{}
+
+var errDiscardStore = errors.New("results are discarded while reprocessing")
+
+func (discardStore) PutResult(result *schema.CheckResult) error { return nil }
+func (discardStore) GetResultByCheckId(bastionId, checkId string) (*schema.CheckResult, error) {
+	return nil, errDiscardStore
+}
+func (discardStore) GetCheckSnapshot(transitionId int64, checkId string) (*schema.Check, error) {
+	return nil, errDiscardStore
+}
+func (discardStore) PutCheckSnapshot(transitionId int64, check *schema.Check) error {
+	return errDiscardStore
+}
+func (discardStore) GetCheckSnapshotLabels(transitionId int64, checkId string) (labels.Labels, error) {
+	return nil, errDiscardStore
+}
+func (discardStore) PutCheckSnapshotLabels(transitionId int64, checkId string, l labels.Labels) error {
+	return errDiscardStore
+}
+
+type checkState struct {
+	CheckId       string    `db:"check_id"`
+	State         string    `db:"state_name"`
+	TimeEntered   time.Time `db:"time_entered"`
+	FailingCount  int32     `db:"failing_count"`
+	ResponseCount int32     `db:"response_count"`
+	Transitions   int       `db:"transitions"`
+}
+
+func main() {
+	viper.SetEnvPrefix("cats")
+	viper.AutomaticEnv()
+
+	var (
+		customerId = flag.String("customer", "", "customer id (required)")
+		checkList  = flag.String("checks", "", "comma separated check ids (default all of the customer's checks)")
+		fromStr    = flag.String("from", "", "start of the time range, RFC3339 (default 24h before -to)")
+		toStr      = flag.String("to", "", "end of the time range, RFC3339 (default now)")
+		shadow     = flag.String("shadow", "reprocess", "schema to rebuild check state in")
+		live       = flag.Bool("live", false, "replace the live tables with those rebuilt in the shadow schema; stop pracovnik first")
+		alerts     = flag.Bool("alerts", false, "enqueue alerts for replayed transitions")
+	)
+	flag.Parse()
+
+	if *customerId == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	to := time.Now()
+	if *toStr != "" {
+		t, err := time.Parse(time.RFC3339, *toStr)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid -to.")
+		}
+		to = t
+	}
+
+	from := to.Add(-24 * time.Hour)
+	if *fromStr != "" {
+		t, err := time.Parse(time.RFC3339, *fromStr)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid -from.")
+		}
+		from = t
+	}
+
+	if *shadow == "" || *shadow == "public" {
+		log.Fatal("The shadow schema must not be public.")
+	}
+
+	db, err := sqlx.Open("postgres", viper.GetString("postgres_conn"))
+	if err != nil {
+		log.WithError(err).Fatal("Cannot connect to database.")
+	}
+
+	var checkIds []string
+	if *checkList != "" {
+		checkIds = strings.Split(*checkList, ",")
+	} else if err := db.Select(&checkIds, "SELECT id FROM checks WHERE customer_id = $1 AND deleted = false", *customerId); err != nil {
+		log.WithError(err).Fatal("Cannot get checks.")
+	}
+
+	if len(checkIds) == 0 {
+		log.Fatal("No checks to reprocess.")
+	}
+
+	oldStates, err := loadStates(db, "public", *customerId, checkIds, from, to)
+	if err != nil {
+		log.WithError(err).Fatal("Cannot get current check states.")
+	}
+
+	awsSession := session.New(&aws.Config{Region: aws.String("us-west-2")})
+	resultStore := &results.S3Store{
+		S3Client:   s3.New(awsSession),
+		BucketName: viper.GetString("results_s3_bucket"),
+	}
+
+	replay, err := loadResults(resultStore, *customerId, checkIds, from, to)
+	if err != nil {
+		log.WithError(err).Fatal("Cannot get results.")
+	}
+
+	// Results are always replayed into the shadow schema. With -live, the
+	// rebuilt state replaces the live state once every result has replayed.
+	replayDB, err := createShadow(db, viper.GetString("postgres_conn"), *shadow)
+	if err != nil {
+		log.WithError(err).Fatal("Cannot prepare tables.")
+	}
+	log.Infof("Replaying %d results for %d checks into %s.", len(replay), len(checkIds), *shadow)
+
+	options := &worker.WorkerOptions{
+		SkipDedupe:  true,
+		SkipAlerts:  !*alerts,
+		SkipWatches: true,
+		ReplayTime:  true,
+	}
+
+	for i, result := range replay {
+		_, err := worker.NewCheckWorkerWithOptions(context.Background(), replayDB, discardStore{}, result, options).Execute()
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"check_id":   result.CheckId,
+				"bastion_id": result.BastionId,
+				"timestamp":  result.Timestamp.String(),
+			}).Fatalf("Error replaying result %d of %d, nothing was changed.", i+1, len(replay))
+		}
+	}
+
+	target := *shadow
+	if *live {
+		if err := swapLive(db, *shadow, *customerId, checkIds, from, to); err != nil {
+			log.WithError(err).Fatal("Cannot replace the live tables, nothing was changed.")
+		}
+		target = "public"
+	}
+
+	newStates, err := loadStates(db, target, *customerId, checkIds, from, to)
+	if err != nil {
+		log.WithError(err).Fatal("Cannot get rebuilt check states.")
+	}
+
+	printDiff(checkIds, oldStates, newStates)
+}
+
+// createShadow (re)creates the shadow schema with empty copies of the rebuilt
+// tables, and returns a database whose connections have it first on their
+// search path, so that the worker writes to it.
+func createShadow(db *sqlx.DB, conn, schemaName string) (*sqlx.DB, error) {
+	quoted := pq.QuoteIdentifier(schemaName)
+
+	stmts := []string{
+		fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoted),
+		fmt.Sprintf("CREATE SCHEMA %s", quoted),
+	}
+	for _, table := range shadowTables {
+		stmts = append(stmts, fmt.Sprintf("CREATE TABLE %s.%s (LIKE public.%s INCLUDING ALL)", quoted, table, table))
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	shadowConn, err := withSearchPath(conn, quoted+", public")
+	if err != nil {
+		return nil, err
+	}
+
+	return sqlx.Open("postgres", shadowConn)
+}
+
+// withSearchPath returns a connection string for conn, which may be a URL,
+// that sets search_path on every connection.
+func withSearchPath(conn, searchPath string) (string, error) {
+	if strings.HasPrefix(conn, "postgres://") || strings.HasPrefix(conn, "postgresql://") {
+		var err error
+		if conn, err = pq.ParseURL(conn); err != nil {
+			return "", err
+		}
+	}
+
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(searchPath)
+	return fmt.Sprintf("%s search_path='%s'", conn, value), nil
+}
+
+// swapLive replaces the live state and memos for the checks, and their
+// transition log between from and to, with those rebuilt in the shadow
+// schema, adds the shadow outbox to the live one, and drops the shadow
+// schema, in one transaction. Shadow tables share the live tables' id
+// sequences, so the rows keep their ids.
+func swapLive(db *sqlx.DB, schemaName, customerId string, checkIds []string, from, to time.Time) error {
+	quoted := pq.QuoteIdentifier(schemaName)
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	stmts := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"DELETE FROM public.check_state_transitions WHERE customer_id = ? AND check_id IN (?) AND created_at BETWEEN ? AND ?", []interface{}{customerId, checkIds, from, to}},
+		{"DELETE FROM public.check_states WHERE customer_id = ? AND check_id IN (?)", []interface{}{customerId, checkIds}},
+		{"DELETE FROM public.check_state_memos WHERE customer_id = ? AND check_id IN (?)", []interface{}{customerId, checkIds}},
+		{fmt.Sprintf("INSERT INTO public.check_state_transitions SELECT * FROM %s.check_state_transitions WHERE customer_id = ? AND check_id IN (?)", quoted), []interface{}{customerId, checkIds}},
+		{fmt.Sprintf("INSERT INTO public.check_states SELECT * FROM %s.check_states WHERE customer_id = ? AND check_id IN (?)", quoted), []interface{}{customerId, checkIds}},
+		{fmt.Sprintf("INSERT INTO public.check_state_memos SELECT * FROM %s.check_state_memos WHERE customer_id = ? AND check_id IN (?)", quoted), []interface{}{customerId, checkIds}},
+		{fmt.Sprintf("INSERT INTO public.outbox SELECT * FROM %s.outbox", quoted), nil},
+		{fmt.Sprintf("DROP SCHEMA %s CASCADE", quoted), nil},
+	}
+
+	for _, stmt := range stmts {
+		query, args, err := sqlx.In(stmt.query, stmt.args...)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec(tx.Rebind(query), args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// loadResults returns the customer's results for the checks, oldest first.
+func loadResults(resultStore results.HistoryStore, customerId string, checkIds []string, from, to time.Time) ([]*schema.CheckResult, error) {
+	var replay []*schema.CheckResult
+	for _, checkId := range checkIds {
+		checkResults, err := resultStore.ListResults(checkId, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range checkResults {
+			if result.CustomerId == customerId {
+				replay = append(replay, result)
+			}
+		}
+	}
+
+	sort.SliceStable(replay, func(i, j int) bool {
+		return replay[i].Timestamp.Time().Before(replay[j].Timestamp.Time())
+	})
+
+	return replay, nil
+}
+
+// loadStates returns the check states in a schema, with the number of
+// transitions logged between from and to, by check id.
+func loadStates(db *sqlx.DB, schemaName, customerId string, checkIds []string, from, to time.Time) (map[string]*checkState, error) {
+	quoted := pq.QuoteIdentifier(schemaName)
+	query, args, err := sqlx.In(fmt.Sprintf(`SELECT s.check_id, s.state_name, s.time_entered, s.failing_count, s.response_count,
+		(SELECT count(*) FROM %s.check_state_transitions t WHERE t.check_id = s.check_id AND t.created_at BETWEEN ? AND ?) AS transitions
+		FROM %s.check_states s WHERE s.customer_id = ? AND s.check_id IN (?)`, quoted, quoted), from, to, customerId, checkIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []*checkState
+	if err := db.Select(&states, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	byCheck := make(map[string]*checkState, len(states))
+	for _, s := range states {
+		byCheck[s.CheckId] = s
+	}
+
+	return byCheck, nil
+}
+
+func printDiff(checkIds []string, oldStates, newStates map[string]*checkState) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATE\tFAILING\tRESPONSES\tENTERED\tTRANSITIONS\t")
+
+	changed := 0
+	for _, checkId := range checkIds {
+		o, n := oldStates[checkId], newStates[checkId]
+		if o == nil && n == nil {
+			continue
+		}
+
+		if o == nil || n == nil || o.State != n.State || o.FailingCount != n.FailingCount ||
+			o.ResponseCount != n.ResponseCount || !o.TimeEntered.Equal(n.TimeEntered) || o.Transitions != n.Transitions {
+			changed++
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", checkId,
+				diffField(o, n, func(s *checkState) string { return s.State }),
+				diffField(o, n, func(s *checkState) string { return fmt.Sprint(s.FailingCount) }),
+				diffField(o, n, func(s *checkState) string { return fmt.Sprint(s.ResponseCount) }),
+				diffField(o, n, func(s *checkState) string { return s.TimeEntered.UTC().Format(time.RFC3339) }),
+				diffField(o, n, func(s *checkState) string { return fmt.Sprint(s.Transitions) }),
+			)
+		}
+	}
+	w.Flush()
+
+	fmt.Printf("%d of %d checks changed\n", changed, len(checkIds))
+}
+
+// diffField formats a field as "old -> new", or just the value if it didn't
+// change.
+func diffField(o, n *checkState, field func(*checkState) string) string {
+	oldVal, newVal := "-", "-"
+	if o != nil {
+		oldVal = field(o)
+	}
+	if n != nil {
+		newVal = field(n)
+	}
+
+	if oldVal == newVal {
+		return oldVal
+	}
+
+	return fmt.Sprintf("%s -> %s", oldVal, newVal)
+}
@@ -8,12 +8,15 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jmoiron/sqlx"
 	"github.com/keighl/mandrill"
 	newrelic "github.com/newrelic/go-agent"
 	"github.com/opsee/cats/checks/results"
+	"github.com/opsee/cats/jobs/slack"
 	"github.com/opsee/cats/jobs/subscriptions"
 	"github.com/opsee/cats/mailer"
 	"github.com/opsee/cats/service"
+	"github.com/opsee/cats/store"
 	"github.com/opsee/gmunch"
 	consumer "github.com/opsee/gmunch/consumer/kinesis"
 	producer "github.com/opsee/gmunch/producer/kinesis"
@@ -51,12 +54,22 @@ func main() {
 		log.WithError(err).Fatal("Can't create cats service")
 	}
 
+	db, err := sqlx.Open("postgres", viper.GetString("postgres_conn"))
+	if err != nil {
+		log.WithError(err).Fatal("Cannot connect to database.")
+	}
+
+	mailer.Preferences = store.NewPreferenceStore(db).GetPreferencesByEmail
+
+	kinesisProducer := producer.New(producer.Config{
+		Stream: viper.GetString("kinesis_stream"),
+		Region: "us-west-2",
+	})
+	slack.DefaultPublisher = slack.EventPublisher(kinesisProducer.Publish)
+
 	server := server.New(server.Config{
 		LogLevel: viper.GetString("log_level"),
-		Producer: producer.New(producer.Config{
-			Stream: viper.GetString("kinesis_stream"),
-			Region: "us-west-2",
-		}),
+		Producer: kinesisProducer,
 		Consumer: consumer.New(consumer.Config{
 			Stream:        viper.GetString("kinesis_stream"),
 			EtcdEndpoints: viper.GetStringSlice("etcd_address"),
@@ -67,9 +80,9 @@ func main() {
 			"stripe_hook": func(evt *gmunch.Event) []worker.Task {
 				return []worker.Task{subscriptions.New(catsSvc, evt)}
 			},
-			// "slack_notification": func(evt *gmunch.Event) []worker.Task {
-			// 	return []worker.Task{slack.New(evt)}
-			// },
+			slack.EventName: func(evt *gmunch.Event) []worker.Task {
+				return []worker.Task{slack.New(store.NewSlackStore(db), slack.DefaultPublisher, evt)}
+			},
 		},
 	})
 
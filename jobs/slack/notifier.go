@@ -0,0 +1,58 @@
+package slack
+
+import (
+	"strings"
+
+	"github.com/opsee/cats/notifications"
+	"golang.org/x/net/context"
+)
+
+// Notifier queues alerts for check transitions as Slack notifications, using
+// the check-failing, check-passing or check-digest template. The target is
+// the channel to post to; an empty target posts to the webhook's default
+// channel.
+type Notifier struct {
+	Publisher Publisher
+}
+
+func (n *Notifier) Notify(ctx context.Context, target string, alert *notifications.Alert) error {
+	if alert.Digest != nil {
+		return Publish(n.Publisher, alert.CustomerId, "check-digest", DigestVars(target, alert.Digest))
+	}
+
+	template := "check-failing"
+	if alert.Passing {
+		template = "check-passing"
+	}
+
+	return Publish(n.Publisher, alert.CustomerId, template, AlertVars(target, alert))
+}
+
+// AlertVars returns the template variables for an alert.
+func AlertVars(channel string, alert *notifications.Alert) map[string]interface{} {
+	return map[string]interface{}{
+		"channel":        channel,
+		"check_id":       alert.CheckId,
+		"check_name":     alert.CheckName,
+		"group_name":     alert.TargetName,
+		"fail_count":     int(alert.FailingCount),
+		"instance_count": int(alert.ResponseCount),
+		"json_url":       "?",
+	}
+}
+
+// DigestVars returns the template variables for a digest.
+func DigestVars(channel string, digest *notifications.Digest) map[string]interface{} {
+	color := "#69a92c"
+	if len(digest.Failing) > 0 {
+		color = "#f44336"
+	}
+
+	return map[string]interface{}{
+		"channel":       channel,
+		"text":          digest.Text(),
+		"failing":       strings.Join(digest.Failing, ", "),
+		"failing_count": len(digest.Failing),
+		"color":         color,
+	}
+}
@@ -1,66 +1,229 @@
+// Package slack posts templated messages to the Slack incoming webhooks a
+// team installed. Messages are queued as slack_notification gmunch events and
+// delivered by a Job in sluice.
 package slack
 
-//
-// import (
-// 	"bytes"
-// 	"fmt"
-// 	"net/http"
-//
-// 	"github.com/opsee/gmunch"
-// 	log "github.com/opsee/logrus"
-// 	"github.com/opsee/notification-templates/dist/go/slack"
-// 	"golang.org/x/net/context"
-// )
-//
-// var Endpoint string
-//
-// type Job struct {
-// 	event   *gmunch.Event
-// 	context context.Context
-// }
-//
-// func New(evt *gmunch.Event) *Job {
-// 	return &Job{
-// 		event:   evt,
-// 		context: context.Background(),
-// 	}
-// }
-//
-// func (j *Job) Context() context.Context {
-// 	return j.context
-// }
-//
-// func (j *Job) Execute() (interface{}, error) {
-// 	log.Infof("job: %s", j.event.Name)
-//
-// 	event := make(map[string]interface{})
-// 	if err := j.event.Decoder().Decode(&event); err != nil {
-// 		log.WithError(err).Errorf("couldn't decode gmunch event: %#v", j.event)
-// 		return nil, err
-// 	}
-//
-// 	if err := notifySlack(, )
-//
-// 	return struct{}{}, nil
-// }
-//
-// func notifySlack(name string, vars map[string]interface{}) error {
-// 	template, ok := slack.Templates[name]
-// 	if !ok {
-// 		return fmt.Errorf("slack template not found: %s", name)
-// 	}
-//
-// 	body := template.Render(vars)
-//
-// 	if Endpoint == "" {
-// 		return fmt.Errorf("slack.Endpoint is not set")
-// 	}
-//
-// 	resp, err := http.Post(Endpoint, "application/json", bytes.NewBufferString(body))
-// 	if err != nil {
-// 		return err
-// 	}
-//
-// 	defer resp.Body.Close()
-// 	return nil
-// }
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hoisie/mustache"
+	"github.com/opsee/cats/store"
+	"github.com/opsee/gmunch"
+	log "github.com/opsee/logrus"
+	slacktmpl "github.com/opsee/notification-templates/dist/go/slack"
+	"golang.org/x/net/context"
+)
+
+// EventName is the gmunch event name for Slack notifications.
+const EventName = "slack_notification"
+
+var (
+	// Client is used to post messages to Slack.
+	Client = &http.Client{Timeout: 10 * time.Second}
+
+	// MaxRetries is how many times a rate limited message is retried.
+	MaxRetries = 3
+
+	// MaxRetryAfter caps how long a rate limited message waits before it is
+	// retried, whatever Slack's Retry-After says.
+	MaxRetryAfter = time.Minute
+
+	// DefaultPublisher is used by Emit. Emit does nothing until it is set.
+	DefaultPublisher Publisher
+)
+
+// Event is the data of a slack_notification gmunch event.
+type Event struct {
+	CustomerId string
+	Template   string
+	Vars       map[string]interface{}
+	// URL is the incoming webhook the message is posted to. An event without
+	// one is split into an event for each of the team's webhooks.
+	URL string
+}
+
+// Publisher queues gmunch events. A gmunch client.Client is a Publisher.
+type Publisher interface {
+	Send(name string, data interface{}) error
+}
+
+// EventPublisher adapts a gmunch producer's Publish method to a Publisher,
+// for processes that produce events directly rather than through sluice.
+type EventPublisher func(event *gmunch.Event) error
+
+func (f EventPublisher) Send(name string, data interface{}) error {
+	event := &gmunch.Event{Name: name}
+	if err := event.EncodeData(data); err != nil {
+		return err
+	}
+
+	return f(event)
+}
+
+// Publish queues a Slack notification for a customer.
+func Publish(p Publisher, customerId, template string, vars map[string]interface{}) error {
+	if _, err := lookup(template); err != nil {
+		return err
+	}
+
+	return p.Send(EventName, &Event{
+		CustomerId: customerId,
+		Template:   template,
+		Vars:       vars,
+	})
+}
+
+// Emit queues a Slack notification with the DefaultPublisher.
+func Emit(customerId, template string, vars map[string]interface{}) error {
+	if DefaultPublisher == nil {
+		log.WithField("template", template).Warn("not sending slack notification since no publisher is set")
+		return nil
+	}
+
+	return Publish(DefaultPublisher, customerId, template, vars)
+}
+
+type Job struct {
+	event      *gmunch.Event
+	context    context.Context
+	slackStore store.SlackStore
+	publisher  Publisher
+}
+
+// New returns a Job for a slack_notification event. Events for a team are
+// split with publisher into an event for each of its webhooks.
+func New(slackStore store.SlackStore, publisher Publisher, evt *gmunch.Event) *Job {
+	return &Job{
+		event:      evt,
+		context:    context.Background(),
+		slackStore: slackStore,
+		publisher:  publisher,
+	}
+}
+
+func (j *Job) Context() context.Context {
+	return j.context
+}
+
+func (j *Job) Execute() (interface{}, error) {
+	log.Infof("job: %s", j.event.Name)
+
+	event := &Event{}
+	if err := j.event.Decoder().Decode(event); err != nil {
+		log.WithError(err).Errorf("couldn't decode gmunch event: %#v", j.event)
+		return nil, err
+	}
+
+	logger := log.WithFields(log.Fields{"customer_id": event.CustomerId, "template": event.Template})
+
+	template, err := lookup(event.Template)
+	if err != nil {
+		logger.WithError(err).Error("couldn't render slack notification")
+		return nil, err
+	}
+
+	if event.URL != "" {
+		if err := post(j.context, event.URL, template.Render(event.Vars)); err != nil {
+			logger.WithError(err).Error("couldn't send slack notification")
+			return nil, err
+		}
+
+		logger.Info("sent slack notification")
+		return struct{}{}, nil
+	}
+
+	urls, err := j.slackStore.GetIncomingWebhookURLs(event.CustomerId)
+	if err != nil {
+		logger.WithError(err).Error("couldn't get slack webhooks")
+		return nil, err
+	}
+
+	if len(urls) == 0 {
+		logger.Info("no slack webhooks, skipping slack notification")
+		return struct{}{}, nil
+	}
+
+	// each webhook is posted to by its own event, so that one that fails
+	// doesn't make the others post the message again
+	for _, url := range urls {
+		webhookEvent := *event
+		webhookEvent.URL = url
+		if err := j.publisher.Send(EventName, &webhookEvent); err != nil {
+			logger.WithError(err).Error("couldn't queue slack notification")
+			return nil, err
+		}
+	}
+
+	logger.Infof("queued slack notification for %d webhooks", len(urls))
+	return struct{}{}, nil
+}
+
+// lookup returns a template from notification-templates, or one of ours.
+func lookup(name string) (*mustache.Template, error) {
+	if template, ok := slacktmpl.Templates[name]; ok {
+		return template, nil
+	}
+
+	if template, ok := templates[name]; ok {
+		return template, nil
+	}
+
+	return nil, fmt.Errorf("slack template not found: %s", name)
+}
+
+// post sends a message to an incoming webhook, waiting and retrying up to
+// MaxRetries times when Slack rate limits it.
+func post(ctx context.Context, url, body string) error {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("POST", url, bytes.NewBufferString(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := Client.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= MaxRetries {
+			return fmt.Errorf("slack webhook returned %s", resp.Status)
+		}
+
+		wait := retryAfter(resp.Header.Get("Retry-After"))
+		log.WithField("retry_after", wait.String()).Warn("slack rate limited notification, retrying")
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header in seconds, defaulting to one
+// second and capped at MaxRetryAfter.
+func retryAfter(header string) time.Duration {
+	wait := time.Second
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	if wait > MaxRetryAfter {
+		wait = MaxRetryAfter
+	}
+
+	return wait
+}
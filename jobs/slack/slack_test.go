@@ -0,0 +1,138 @@
+package slack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opsee/cats/notifications"
+	"github.com/opsee/gmunch"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type testSlackStore struct {
+	urls []string
+}
+
+func (s *testSlackStore) GetIncomingWebhookURLs(customerId string) ([]string, error) {
+	return s.urls, nil
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(time.Second, retryAfter(""))
+	assert.Equal(time.Second, retryAfter("soon"))
+	assert.Equal(5*time.Second, retryAfter("5"))
+	assert.Equal(MaxRetryAfter, retryAfter("3600"))
+}
+
+func TestPublishUnknownTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	err := Publish(EventPublisher(func(*gmunch.Event) error { return nil }), "customer", "nope", nil)
+	assert.Error(err)
+}
+
+func TestJobRetriesRateLimited(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(max time.Duration) { MaxRetryAfter = max }(MaxRetryAfter)
+	MaxRetryAfter = 10 * time.Millisecond
+
+	var calls int32
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	var event *gmunch.Event
+	publisher := EventPublisher(func(evt *gmunch.Event) error {
+		event = evt
+		return nil
+	})
+
+	alert := &notifications.Alert{
+		CustomerId:    "customer",
+		CheckId:       "check",
+		CheckName:     "a check",
+		TargetName:    "a group",
+		FailingCount:  2,
+		ResponseCount: 3,
+	}
+	err := (&Notifier{Publisher: publisher}).Notify(context.Background(), "#alerts", alert)
+	assert.NoError(err)
+	assert.Equal(EventName, event.Name)
+
+	// the team's event is split into one for each webhook
+	teamEvent := event
+	_, err = New(&testSlackStore{urls: []string{server.URL}}, publisher, teamEvent).Execute()
+	assert.NoError(err)
+	assert.EqualValues(0, calls)
+	assert.NotEqual(teamEvent, event)
+
+	_, err = New(&testSlackStore{}, publisher, event).Execute()
+	assert.NoError(err)
+	assert.EqualValues(2, calls)
+
+	msg := map[string]interface{}{}
+	assert.NoError(json.Unmarshal(body, &msg))
+	assert.Equal("#alerts", msg["channel"])
+
+	attachment := msg["attachments"].([]interface{})[0].(map[string]interface{})
+	assert.Equal("a check failing in a group", attachment["title"])
+	assert.Equal("2 of 3 Failing", attachment["text"])
+}
+
+func TestJobGivesUpOnError(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var published []*Event
+	publisher := EventPublisher(func(evt *gmunch.Event) error {
+		e := &Event{}
+		if err := evt.Decoder().Decode(e); err != nil {
+			return err
+		}
+		published = append(published, e)
+		return nil
+	})
+
+	event := &gmunch.Event{Name: EventName}
+	assert.NoError(event.EncodeData(&Event{
+		CustomerId: "customer",
+		Template:   "billing-payment-failed",
+		Vars:       map[string]interface{}{"team_name": "team", "attempt_count": "1"},
+	}))
+
+	_, err := New(&testSlackStore{urls: []string{server.URL, "https://hooks.slack.com/other"}}, publisher, event).Execute()
+	assert.NoError(err)
+	assert.Len(published, 2)
+	assert.Equal(server.URL, published[0].URL)
+	assert.Equal("https://hooks.slack.com/other", published[1].URL)
+
+	// a webhook that fails fails only its own event
+	webhookEvent := &gmunch.Event{Name: EventName}
+	assert.NoError(webhookEvent.EncodeData(published[0]))
+	_, err = New(&testSlackStore{}, publisher, webhookEvent).Execute()
+	assert.Error(err)
+
+	_, err = New(&testSlackStore{}, publisher, event).Execute()
+	assert.NoError(err)
+	assert.Len(published, 2)
+}
@@ -0,0 +1,85 @@
+package slack
+
+import "github.com/hoisie/mustache"
+
+// Digest and billing templates, which notification-templates doesn't have
+// yet.
+var (
+	CheckDigest = `{
+  "channel":"{{channel}}",
+  "username": "OpseeBot",
+  "icon_url": "https://s3-us-west-1.amazonaws.com/opsee-public-images/slack-avi-48-red.png",
+  "attachments": [
+    {
+      "pretext": "Alert digest",
+      "title": "{{text}}",
+      "title_link": "https://app.opsee.com/?utm_source=notification&utm_medium=slack&utm_campaign=app",
+      "text": "{{#failing_count}}Failing: {{failing}}{{/failing_count}}",
+      "color": "{{color}}"
+    }
+  ]
+}
+`
+
+	BillingTrialEnding = `{
+  "username": "OpseeBot",
+  "icon_url": "https://s3-us-west-1.amazonaws.com/opsee-public-images/slack-avi-48-red.png",
+  "attachments": [
+    {
+      "pretext": "Your Opsee trial is ending",
+      "title": "{{team_name}}'s trial ends in 3 days",
+      "title_link": "https://app.opsee.com/team?utm_source=notification&utm_medium=slack&utm_campaign=billing",
+      "text": "Add a credit card to keep your checks running.",
+      "color": "#ffa000"
+    }
+  ]
+}
+`
+
+	BillingTrialExpired = `{
+  "username": "OpseeBot",
+  "icon_url": "https://s3-us-west-1.amazonaws.com/opsee-public-images/slack-avi-48-red.png",
+  "attachments": [
+    {
+      "pretext": "Your Opsee trial has ended",
+      "title": "{{team_name}}'s trial has ended",
+      "title_link": "https://app.opsee.com/team?utm_source=notification&utm_medium=slack&utm_campaign=billing",
+      "text": "Add a credit card to keep your checks running.",
+      "color": "#f44336"
+    }
+  ]
+}
+`
+
+	BillingPaymentFailed = `{
+  "username": "OpseeBot",
+  "icon_url": "https://s3-us-west-1.amazonaws.com/opsee-public-images/slack-avi-48-red.png",
+  "attachments": [
+    {
+      "pretext": "Payment failed",
+      "title": "{{team_name}}'s payment failed (attempt {{attempt_count}})",
+      "title_link": "https://app.opsee.com/team?utm_source=notification&utm_medium=slack&utm_campaign=billing",
+      "text": "Please update your credit card.",
+      "color": "#f44336"
+    }
+  ]
+}
+`
+
+	templates = make(map[string]*mustache.Template)
+)
+
+func init() {
+	for name, src := range map[string]string{
+		"check-digest":           CheckDigest,
+		"billing-trial-ending":   BillingTrialEnding,
+		"billing-trial-expired":  BillingTrialExpired,
+		"billing-payment-failed": BillingPaymentFailed,
+	} {
+		tmpl, err := mustache.ParseString(src)
+		if err != nil {
+			panic(err)
+		}
+		templates[name] = tmpl
+	}
+}
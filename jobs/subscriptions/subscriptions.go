@@ -3,6 +3,7 @@ package subscriptions
 import (
 	"github.com/opsee/basic/schema"
 	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/cats/service"
 	"github.com/opsee/cats/subscriptions"
 	"github.com/opsee/gmunch"
 	log "github.com/opsee/logrus"
@@ -48,7 +49,10 @@ func (j *Job) Execute() (interface{}, error) {
 		stripeCustomerId = "cus_8szeJAcdhSXmUY"
 	}
 
-	teamResponse, err := j.service.GetTeam(context.Background(), &opsee.GetTeamRequest{
+	// stripe events aren't made by a user, so the job acts for any team
+	ctx := service.TrustedContext(j.context)
+
+	teamResponse, err := j.service.GetTeam(ctx, &opsee.GetTeamRequest{
 		Team: &schema.Team{
 			StripeCustomerId: stripeCustomerId,
 		},
@@ -64,7 +68,7 @@ func (j *Job) Execute() (interface{}, error) {
 		return nil, err
 	}
 
-	if _, err := j.service.UpdateTeam(context.Background(), &opsee.UpdateTeamRequest{
+	if _, err := j.service.UpdateTeam(ctx, &opsee.UpdateTeamRequest{
 		Team: teamResponse.Team,
 	}); err != nil {
 		log.WithError(err).Error("couldn't update team")
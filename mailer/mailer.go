@@ -1,13 +1,22 @@
 package mailer
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/keighl/mandrill"
+	"github.com/opsee/cats/preferences"
 	log "github.com/opsee/logrus"
 )
 
 var (
 	Client  *mandrill.Client
 	BaseURL = "https://app.opsee.com"
+
+	// Preferences returns the notification preferences of the user with an
+	// email address. If it isn't set, every email is sent.
+	Preferences func(email string) (*preferences.Preferences, error)
 )
 
 func Send(toEmail, toName, templateName string, mergeVars map[string]interface{}) ([]*mandrill.Response, error) {
@@ -25,3 +34,60 @@ func Send(toEmail, toName, templateName string, mergeVars map[string]interface{}
 	message.MergeVars = []*mandrill.RcptMergeVars{mandrill.MapToRecipientVars(toEmail, mergeVars)}
 	return Client.MessagesSendTemplate(message, templateName, map[string]string{})
 }
+
+// ErrSuppressed is returned by SendCategory when the recipient has opted out
+// of emails of the category.
+var ErrSuppressed = errors.New("recipient opted out")
+
+// QuietError is returned by SendCategory during the recipient's quiet hours,
+// which end at Until.
+type QuietError struct {
+	Until time.Time
+}
+
+func (e *QuietError) Error() string {
+	return fmt.Sprintf("recipient's quiet hours end at %s", e.Until.UTC().Format(time.RFC3339))
+}
+
+// Allowed returns true if the recipient's notification preferences allow an
+// email of category now. Emails are allowed if the preferences can't be
+// read.
+func Allowed(category preferences.Category, toEmail string) bool {
+	return allowed(category, toEmail, time.Now()) == nil
+}
+
+// allowed returns ErrSuppressed or a QuietError if the recipient's
+// preferences don't allow an email of category at now.
+func allowed(category preferences.Category, toEmail string, now time.Time) error {
+	if Preferences == nil {
+		return nil
+	}
+
+	prefs, err := Preferences(toEmail)
+	if err != nil {
+		log.WithError(err).WithField("email", toEmail).Warn("couldn't get notification preferences, sending anyway")
+		return nil
+	}
+
+	if !prefs.Wants(category, preferences.Email) {
+		return ErrSuppressed
+	}
+
+	if !prefs.Allows(category, preferences.Email, now) {
+		return &QuietError{Until: prefs.QuietUntil(now)}
+	}
+
+	return nil
+}
+
+// SendCategory sends an email of a notification category, unless the
+// recipient's preferences don't allow it, in which case it returns
+// ErrSuppressed or a QuietError.
+func SendCategory(category preferences.Category, toEmail, toName, templateName string, mergeVars map[string]interface{}) ([]*mandrill.Response, error) {
+	if err := allowed(category, toEmail, time.Now()); err != nil {
+		log.WithError(err).WithFields(log.Fields{"email": toEmail, "template": templateName, "category": category}).Info("not sending message")
+		return nil, err
+	}
+
+	return Send(toEmail, toName, templateName, mergeVars)
+}
@@ -0,0 +1,13 @@
+CREATE TABLE redaction_rules (
+    id serial PRIMARY KEY,
+    customer_id uuid NOT NULL,
+    check_id character varying(255),
+    headers jsonb DEFAULT '[]'::jsonb NOT NULL,
+    body_patterns jsonb DEFAULT '[]'::jsonb NOT NULL,
+    max_body_size integer DEFAULT 0 NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE INDEX idx_redaction_rules_customer_id_check_id ON redaction_rules (customer_id, check_id);
+CREATE TRIGGER update_redaction_rules BEFORE UPDATE ON redaction_rules FOR EACH ROW EXECUTE PROCEDURE update_time();
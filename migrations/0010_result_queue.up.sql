@@ -0,0 +1,9 @@
+CREATE TABLE result_queue (
+    id bigserial PRIMARY KEY,
+    body bytea NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    visible_at timestamp with time zone DEFAULT now() NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE INDEX idx_result_queue_visible_at ON result_queue (visible_at);
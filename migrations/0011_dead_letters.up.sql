@@ -0,0 +1,11 @@
+CREATE TABLE dead_letters (
+    id bigserial PRIMARY KEY,
+    customer_id character varying(255) DEFAULT '' NOT NULL,
+    check_id character varying(255) DEFAULT '' NOT NULL,
+    body bytea NOT NULL,
+    error text DEFAULT '' NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE INDEX idx_dead_letters_customer_id ON dead_letters (customer_id);
@@ -0,0 +1,11 @@
+CREATE TABLE outbox (
+    id bigserial PRIMARY KEY,
+    kind character varying(64) NOT NULL,
+    payload jsonb NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp with time zone DEFAULT now() NOT NULL,
+    last_error text DEFAULT '' NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE INDEX idx_outbox_next_attempt_at ON outbox (next_attempt_at);
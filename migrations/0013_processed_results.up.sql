@@ -0,0 +1,6 @@
+CREATE TABLE processed_results (
+    key character(64) PRIMARY KEY,
+    expires_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX idx_processed_results_expires_at ON processed_results (expires_at);
@@ -0,0 +1,30 @@
+-- Notification types are varchar with a check, instead of the
+-- notification_type enum, because migrations run in a transaction and
+-- Postgres 9.5 can't add an enum value in one.
+ALTER TABLE notifications ALTER COLUMN type TYPE character varying(64) USING type::text;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check CHECK (type IN ('webhook', 'slack_bot', 'email', 'pagerduty', 'slack_webhook'));
+ALTER TABLE default_notifications ALTER COLUMN type TYPE character varying(64) USING type::text;
+ALTER TABLE default_notifications ADD CONSTRAINT default_notifications_type_check CHECK (type IN ('webhook', 'slack_bot', 'email', 'pagerduty', 'slack_webhook'));
+
+CREATE INDEX idx_notifications_check_id ON notifications (check_id);
+
+CREATE TABLE notification_deliveries (
+    id bigserial PRIMARY KEY,
+    transition_id bigint NOT NULL,
+    check_id character varying(255) NOT NULL,
+    customer_id uuid NOT NULL,
+    type character varying(64) NOT NULL,
+    value character varying(255) NOT NULL,
+    payload jsonb NOT NULL,
+    status character varying(32) DEFAULT 'pending' NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp with time zone DEFAULT now() NOT NULL,
+    last_error text DEFAULT '' NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    UNIQUE (transition_id, type, value)
+);
+
+CREATE INDEX idx_notification_deliveries_pending ON notification_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_notification_deliveries_check_id ON notification_deliveries (check_id, created_at);
+CREATE TRIGGER update_notification_deliveries BEFORE UPDATE ON notification_deliveries FOR EACH ROW EXECUTE PROCEDURE update_time();
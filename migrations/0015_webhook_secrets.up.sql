@@ -0,0 +1,8 @@
+CREATE TABLE webhook_secrets (
+    customer_id uuid PRIMARY KEY,
+    secret character varying(128) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE TRIGGER update_webhook_secrets BEFORE UPDATE ON webhook_secrets FOR EACH ROW EXECUTE PROCEDURE update_time();
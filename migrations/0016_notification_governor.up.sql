@@ -0,0 +1,36 @@
+CREATE TABLE notification_governors (
+    customer_id uuid PRIMARY KEY,
+    window_start timestamp with time zone DEFAULT now() NOT NULL,
+    window_count integer DEFAULT 0 NOT NULL,
+    digesting boolean DEFAULT false NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE TRIGGER update_notification_governors BEFORE UPDATE ON notification_governors FOR EACH ROW EXECUTE PROCEDURE update_time();
+
+CREATE TABLE notification_digest_entries (
+    id bigserial PRIMARY KEY,
+    customer_id uuid NOT NULL,
+    transition_id bigint NOT NULL,
+    check_id character varying(255) NOT NULL,
+    check_name character varying(255) DEFAULT '' NOT NULL,
+    passing boolean NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    UNIQUE (customer_id, transition_id)
+);
+
+CREATE INDEX idx_notification_digest_entries_customer_id ON notification_digest_entries (customer_id, id);
+
+CREATE TABLE notification_critical_checks (
+    customer_id uuid NOT NULL,
+    check_id character varying(255) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (customer_id, check_id)
+);
+
+-- Digest deliveries have a digest_id, the id of the last digest entry they
+-- cover, and a transition_id of 0.
+ALTER TABLE notification_deliveries ADD COLUMN digest_id bigint DEFAULT 0 NOT NULL;
+ALTER TABLE notification_deliveries DROP CONSTRAINT notification_deliveries_transition_id_type_value_key;
+ALTER TABLE notification_deliveries ADD CONSTRAINT notification_deliveries_transition_id_digest_id_type_value_key UNIQUE (transition_id, digest_id, type, value);
@@ -0,0 +1,3 @@
+CREATE INDEX idx_checks_customer_id_name ON checks (customer_id, name, id) WHERE deleted = false;
+CREATE INDEX idx_checks_customer_id_target ON checks (customer_id, target_type, target_id) WHERE deleted = false;
+CREATE INDEX idx_check_states_customer_id ON check_states (customer_id, state_name, time_entered);
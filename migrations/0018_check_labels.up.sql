@@ -0,0 +1,29 @@
+CREATE TABLE check_labels (
+    check_id character varying(255) NOT NULL,
+    customer_id uuid NOT NULL,
+    key character varying(317) NOT NULL,
+    value character varying(63) DEFAULT '' NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (check_id, key)
+);
+
+CREATE TRIGGER update_check_labels BEFORE UPDATE ON check_labels FOR EACH ROW EXECUTE PROCEDURE update_time();
+
+CREATE INDEX idx_check_labels_customer_id ON check_labels (customer_id, key, value);
+
+-- Notification routes send the alerts of checks whose labels match the
+-- selector to a notification, in addition to the check's own.
+CREATE TABLE notification_routes (
+    id bigserial PRIMARY KEY,
+    customer_id uuid NOT NULL,
+    selector text NOT NULL,
+    type character varying(64) NOT NULL CHECK (type IN ('webhook', 'slack_bot', 'email', 'pagerduty', 'slack_webhook')),
+    value character varying(255) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE TRIGGER update_notification_routes BEFORE UPDATE ON notification_routes FOR EACH ROW EXECUTE PROCEDURE update_time();
+
+CREATE INDEX idx_notification_routes_customer_id ON notification_routes (customer_id);
@@ -0,0 +1,19 @@
+-- A check template is materialised as one check per target its selector
+-- matches. Those checks point back at the template.
+CREATE TABLE check_templates (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    customer_id uuid NOT NULL,
+    name character varying(255) NOT NULL,
+    spec jsonb NOT NULL,
+    deleted boolean DEFAULT false NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE TRIGGER update_check_templates BEFORE UPDATE ON check_templates FOR EACH ROW EXECUTE PROCEDURE update_time();
+
+CREATE INDEX idx_check_templates_customer_id ON check_templates (customer_id) WHERE deleted = false;
+
+ALTER TABLE checks ADD COLUMN template_id uuid;
+
+CREATE INDEX idx_checks_template_id ON checks (template_id) WHERE template_id IS NOT NULL;
@@ -0,0 +1,95 @@
+package notifications
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for deliveries to an endpoint whose circuit is
+// open. Notifiers defer the delivery until the circuit lets a call through,
+// so that it isn't counted as an attempt.
+var ErrCircuitOpen = errors.New("circuit open for endpoint")
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+}
+
+// CircuitBreaker stops calls to an endpoint after Threshold consecutive
+// failures. After Cooldown a single trial call is allowed through; if it
+// succeeds the circuit closes, otherwise it stays open for another Cooldown.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mut      sync.Mutex
+	circuits map[string]*circuit
+	now      func() time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		circuits:  make(map[string]*circuit),
+		now:       time.Now,
+	}
+}
+
+// Allow returns ErrCircuitOpen if calls to endpoint should not be made.
+func (b *CircuitBreaker) Allow(endpoint string) error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	c, ok := b.circuits[endpoint]
+	if !ok || c.failures < b.Threshold {
+		return nil
+	}
+
+	now := b.now()
+	if now.Before(c.openUntil) {
+		return ErrCircuitOpen
+	}
+
+	// half open: let this call through, and hold everything else until it
+	// has finished or the cooldown has passed again
+	c.openUntil = now.Add(b.Cooldown)
+	return nil
+}
+
+// OpenUntil returns when the circuit for endpoint next lets a call through,
+// or the zero time if it is closed.
+func (b *CircuitBreaker) OpenUntil(endpoint string) time.Time {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	c, ok := b.circuits[endpoint]
+	if !ok || c.failures < b.Threshold {
+		return time.Time{}
+	}
+
+	return c.openUntil
+}
+
+// Record records the outcome of a call to endpoint.
+func (b *CircuitBreaker) Record(endpoint string, err error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if err == nil {
+		delete(b.circuits, endpoint)
+		return
+	}
+
+	c, ok := b.circuits[endpoint]
+	if !ok {
+		c = &circuit{}
+		b.circuits[endpoint] = c
+	}
+
+	c.failures++
+	if c.failures >= b.Threshold {
+		c.openUntil = b.now().Add(b.Cooldown)
+	}
+}
@@ -0,0 +1,191 @@
+package notifications
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/opsee/cats/store"
+)
+
+// GovernorConfig configures the per-team notification governor.
+type GovernorConfig struct {
+	// Rate is how many alerts a team is sent individually per Window. Past
+	// that, alerts are held for digests until a Window passes with no more
+	// than Rate alerts.
+	Rate   int
+	Window time.Duration
+	// DigestInterval is how often held alerts are sent as a digest.
+	DigestInterval time.Duration
+}
+
+// Governor limits the rate of alerts sent to each team, batching alerts
+// into digests during alert storms. Its state is kept in Postgres, so that
+// every pracovnik shares it.
+type Governor struct {
+	rate           int
+	window         time.Duration
+	digestInterval time.Duration
+}
+
+func NewGovernor(cfg *GovernorConfig) *Governor {
+	g := &Governor{
+		rate:           cfg.Rate,
+		window:         cfg.Window,
+		digestInterval: cfg.DigestInterval,
+	}
+
+	if g.rate < 1 {
+		g.rate = 10
+	}
+
+	if g.window <= 0 {
+		g.window = 5 * time.Minute
+	}
+
+	if g.digestInterval <= 0 {
+		g.digestInterval = g.window
+	}
+
+	return g
+}
+
+// Admit counts an alert for a team and returns true if it should be sent
+// individually, or false if it should be held for a digest. Alerts for
+// critical checks are always sent and not counted. It must be called in the
+// transaction that routes the alert. A retried alert that was already held,
+// or sent to any notifications, isn't counted again.
+func (g *Governor) Admit(governorStore store.GovernorStore, alert *Alert, now time.Time) (bool, error) {
+	critical, err := governorStore.IsCriticalCheck(alert.CustomerId, alert.CheckId)
+	if err != nil {
+		return false, err
+	}
+
+	if critical {
+		return true, nil
+	}
+
+	state, err := governorStore.LockGovernor(alert.CustomerId)
+	if err != nil {
+		return false, err
+	}
+
+	// under the governor's lock, so that concurrent retries are admitted once
+	admitted, held, err := governorStore.GetAdmission(alert.CustomerId, alert.TransitionId)
+	if err != nil {
+		return false, err
+	}
+
+	if admitted {
+		return !held, nil
+	}
+
+	send := g.step(state, now)
+	if err := governorStore.UpdateGovernor(state); err != nil {
+		return false, err
+	}
+
+	if !send {
+		err := governorStore.PutDigestEntry(&store.DigestEntry{
+			CustomerId:   alert.CustomerId,
+			TransitionId: alert.TransitionId,
+			CheckId:      alert.CheckId,
+			CheckName:    alert.CheckName,
+			Passing:      alert.Passing,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return send, nil
+}
+
+// step counts an alert in a team's fixed rate window. A team starts
+// digesting once the count passes the rate, and stops at the end of the
+// first window that doesn't.
+func (g *Governor) step(state *store.NotificationGovernor, now time.Time) bool {
+	if elapsed := now.Sub(state.WindowStart); elapsed >= g.window {
+		if state.WindowCount <= g.rate || elapsed >= 2*g.window {
+			state.Digesting = false
+		}
+		state.WindowStart = now
+		state.WindowCount = 0
+	}
+
+	state.WindowCount++
+	if state.WindowCount > g.rate {
+		state.Digesting = true
+	}
+
+	return !state.Digesting
+}
+
+// Digest summarizes the alerts held for a team, by the latest state of each
+// check.
+type Digest struct {
+	Failing   []string  `json:"failing"`
+	Recovered []string  `json:"recovered"`
+	Alerts    int       `json:"alerts"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+}
+
+// NewDigest summarizes digest entries, which must be oldest first.
+func NewDigest(entries []*store.DigestEntry, now time.Time) *Digest {
+	digest := &Digest{
+		Failing:   []string{},
+		Recovered: []string{},
+		Alerts:    len(entries),
+		Until:     now,
+	}
+
+	if len(entries) > 0 {
+		digest.Since = entries[0].CreatedAt
+	}
+
+	latest := make(map[string]*store.DigestEntry)
+	var order []string
+	for _, e := range entries {
+		if _, ok := latest[e.CheckId]; !ok {
+			order = append(order, e.CheckId)
+		}
+		latest[e.CheckId] = e
+	}
+
+	for _, checkId := range order {
+		e := latest[checkId]
+		name := e.CheckName
+		if name == "" {
+			name = e.CheckId
+		}
+
+		if e.Passing {
+			digest.Recovered = append(digest.Recovered, name)
+		} else {
+			digest.Failing = append(digest.Failing, name)
+		}
+	}
+
+	return digest
+}
+
+// Text describes the digest, e.g. "37 checks failing, 5 recovered in the
+// last 5 minutes".
+func (d *Digest) Text() string {
+	minutes := int(d.Until.Sub(d.Since).Minutes() + 0.5)
+	if minutes < 1 {
+		minutes = 1
+	}
+
+	checks := "checks"
+	if len(d.Failing) == 1 {
+		checks = "check"
+	}
+
+	period := fmt.Sprintf("%d minutes", minutes)
+	if minutes == 1 {
+		period = "minute"
+	}
+
+	return fmt.Sprintf("%d %s failing, %d recovered in the last %s", len(d.Failing), checks, len(d.Recovered), period)
+}
@@ -0,0 +1,186 @@
+// Package notifications routes alerts for check state transitions to the
+// notifications configured for each check. Each alert is fanned out to one
+// delivery per notification, and deliveries are retried according to the
+// RetryPolicy of their notifier and kept as a delivery log.
+package notifications
+
+import (
+	"errors"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/outbox"
+	"golang.org/x/net/context"
+)
+
+// Alert is what notifiers are given for an alerting transition.
+type Alert struct {
+	TransitionId  int64              `json:"transition_id"`
+	CheckId       string             `json:"check_id"`
+	CheckName     string             `json:"check_name"`
+	TargetName    string             `json:"target_name,omitempty"`
+	Labels        map[string]string  `json:"labels,omitempty"`
+	CustomerId    string             `json:"customer_id"`
+	State         string             `json:"state"`
+	PreviousState string             `json:"previous_state"`
+	Passing       bool               `json:"passing"`
+	FailingCount  int32              `json:"failing_count"`
+	ResponseCount int32              `json:"response_count"`
+	Timestamp     time.Time          `json:"timestamp"`
+	Responses     []*ResponseSummary `json:"responses"`
+	// Digest is set, and the check fields are empty, for digests of alerts
+	// held by the Governor.
+	Digest *Digest `json:"digest,omitempty"`
+}
+
+// ResponseSummary describes a single target's response in an Alert.
+type ResponseSummary struct {
+	Target  string `json:"target"`
+	Passing bool   `json:"passing"`
+	Error   string `json:"error,omitempty"`
+	Code    int32  `json:"code,omitempty"`
+}
+
+// NewAlert returns the Alert for a transition event.
+func NewAlert(event *outbox.TransitionEvent, checkName string) (*Alert, error) {
+	result, err := event.CheckResult()
+	if err != nil {
+		return nil, err
+	}
+
+	alert := &Alert{
+		TransitionId:  event.TransitionId,
+		CheckId:       event.CheckId,
+		CheckName:     checkName,
+		CustomerId:    event.CustomerId,
+		State:         event.To.String(),
+		PreviousState: event.From.String(),
+		Passing:       event.To != checks.StateFail,
+		FailingCount:  event.FailingCount,
+		ResponseCount: event.ResponseCount,
+		Responses:     make([]*ResponseSummary, 0, len(result.Responses)),
+	}
+
+	if result.Timestamp != nil {
+		alert.Timestamp = result.Timestamp.Time()
+	}
+
+	for _, resp := range result.Responses {
+		if resp == nil {
+			continue
+		}
+
+		summary := &ResponseSummary{
+			Passing: resp.Passing,
+			Error:   resp.Error,
+		}
+
+		if resp.Target != nil {
+			summary.Target = resp.Target.Id
+			if summary.Target == "" {
+				summary.Target = resp.Target.Address
+			}
+		}
+
+		if r, ok := resp.Reply.(*schema.CheckResponse_HttpResponse); ok && r.HttpResponse != nil {
+			summary.Code = r.HttpResponse.Code
+		}
+
+		alert.Responses = append(alert.Responses, summary)
+	}
+
+	return alert, nil
+}
+
+// NewDigestAlert returns the Alert for a digest.
+func NewDigestAlert(customerId string, digest *Digest) *Alert {
+	return &Alert{
+		CustomerId:    customerId,
+		Passing:       len(digest.Failing) == 0,
+		FailingCount:  int32(len(digest.Failing)),
+		ResponseCount: int32(len(digest.Failing) + len(digest.Recovered)),
+		Timestamp:     digest.Until,
+		Responses:     []*ResponseSummary{},
+		Digest:        digest,
+	}
+}
+
+// Notifier sends an Alert to a target, e.g. an email address or a webhook
+// URL, taken from the value of a schema.Notification.
+type Notifier interface {
+	Notify(ctx context.Context, target string, alert *Alert) error
+}
+
+// RetryPolicy controls how failed deliveries for a notifier are retried.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy retries for about an hour.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    10,
+	InitialBackoff: 5 * time.Second,
+	MaxBackoff:     15 * time.Minute,
+}
+
+// Backoff returns how long to wait before retrying a delivery that has failed
+// attempts times.
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempts && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+
+	return d
+}
+
+type permanentError struct {
+	error
+}
+
+// Permanent marks an error as not worth retrying, e.g. a rejected address.
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+// IsPermanent returns true if err was returned by Permanent.
+func IsPermanent(err error) bool {
+	_, ok := err.(*permanentError)
+	return ok
+}
+
+type deferredError struct {
+	error
+	until time.Time
+}
+
+// Defer marks an error as a reason to attempt a delivery again at until,
+// e.g. the end of the recipient's quiet hours. Deferred attempts aren't
+// counted against the RetryPolicy.
+func Defer(err error, until time.Time) error {
+	return &deferredError{err, until}
+}
+
+// deferredUntil returns when to attempt a delivery again if err was
+// returned by Defer.
+func deferredUntil(err error) (time.Time, bool) {
+	d, ok := err.(*deferredError)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return d.until, true
+}
+
+// ErrSuppressed is returned by a Notifier when the recipient has opted out
+// of alerts. The delivery is logged as suppressed rather than delivered.
+var ErrSuppressed = errors.New("recipient opted out of alerts")
+
+var errNoNotifier = errors.New("no notifier for notification type")
@@ -0,0 +1,501 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/outbox"
+	"github.com/opsee/cats/mailer"
+	"github.com/opsee/cats/preferences"
+	"github.com/opsee/cats/store"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func testAlert(t *testing.T) *Alert {
+	ts := &opsee_types.Timestamp{}
+	ts.Scan(time.Unix(1460000000, 0))
+
+	result := &schema.CheckResult{
+		CheckId:   "check-id",
+		Timestamp: ts,
+		Responses: []*schema.CheckResponse{
+			{
+				Target:  &schema.Target{Id: "i-1"},
+				Passing: false,
+				Reply:   &schema.CheckResponse_HttpResponse{HttpResponse: &schema.HttpResponse{Code: 500}},
+			},
+			{
+				Target:  &schema.Target{Address: "10.0.0.2"},
+				Passing: true,
+			},
+		},
+	}
+
+	event, err := outbox.NewTransitionEvent(&checks.StateTransitionLogEntry{
+		Id:         1,
+		CheckId:    "check-id",
+		CustomerId: "11111111-1111-1111-1111-111111111111",
+		From:       checks.StateFailWait,
+		To:         checks.StateFail,
+	}, &checks.State{FailingCount: 1, ResponseCount: 2}, result)
+	assert.Nil(t, err)
+
+	alert, err := NewAlert(event, "my check")
+	assert.Nil(t, err)
+	return alert
+}
+
+func TestNewAlert(t *testing.T) {
+	alert := testAlert(t)
+
+	assert.Equal(t, "FAIL", alert.State)
+	assert.Equal(t, "FAIL_WAIT", alert.PreviousState)
+	assert.False(t, alert.Passing)
+	assert.Equal(t, int64(1460000000), alert.Timestamp.Unix())
+	assert.Len(t, alert.Responses, 2)
+	assert.Equal(t, "i-1", alert.Responses[0].Target)
+	assert.Equal(t, int32(500), alert.Responses[0].Code)
+	assert.Equal(t, "10.0.0.2", alert.Responses[1].Target)
+}
+
+func TestRoutedNotifications(t *testing.T) {
+	routes := []*store.NotificationRoute{
+		{Id: 1, Selector: "env=prod", Type: "slack_bot", Value: "#prod"},
+		{Id: 2, Selector: "env=prod,team in (payments,billing)", Type: "email", Value: "payments@opsee.co"},
+		{Id: 3, Selector: "team!=payments", Type: "email", Value: "infra@opsee.co"},
+		{Id: 4, Selector: "env=", Type: "webhook", Value: "https://example.com"},
+		{Id: 5, Selector: "env=pr od", Type: "webhook", Value: "https://example.com"},
+	}
+
+	notifications := routedNotifications(routes, map[string]string{"env": "prod", "team": "payments"})
+	assert.Equal(t, []*schema.Notification{
+		{Type: "slack_bot", Value: "#prod"},
+		{Type: "email", Value: "payments@opsee.co"},
+	}, notifications)
+
+	// unlabeled checks only match negative requirements
+	notifications = routedNotifications(routes, nil)
+	assert.Equal(t, []*schema.Notification{{Type: "email", Value: "infra@opsee.co"}}, notifications)
+}
+
+type testNotificationStore struct {
+	store.NotificationStore
+	notifications map[string][]*schema.Notification
+}
+
+func (q *testNotificationStore) GetCheckNotifications(customerId, checkId string) ([]*schema.Notification, error) {
+	return q.notifications[checkId], nil
+}
+
+func (q *testNotificationStore) GetDefaultNotifications(customerId string) ([]*schema.Notification, error) {
+	return []*schema.Notification{{Type: "email", Value: "default@opsee.co"}}, nil
+}
+
+func TestAlertNotifications(t *testing.T) {
+	q := &testNotificationStore{notifications: map[string][]*schema.Notification{
+		"check-1": {{Type: "email", Value: "check-1@opsee.co"}},
+	}}
+	routes := []*store.NotificationRoute{{Id: 1, Selector: "env=prod", Type: "slack_bot", Value: "#prod"}}
+
+	notifications, err := alertNotifications(q, "customer", "check-1", routes, map[string]string{"env": "prod"})
+	assert.NoError(t, err)
+	assert.Equal(t, []*schema.Notification{
+		{Type: "email", Value: "check-1@opsee.co"},
+		{Type: "slack_bot", Value: "#prod"},
+	}, notifications)
+
+	// a matching route doesn't replace the default notifications
+	notifications, err = alertNotifications(q, "customer", "check-2", routes, map[string]string{"env": "prod"})
+	assert.NoError(t, err)
+	assert.Equal(t, []*schema.Notification{
+		{Type: "email", Value: "default@opsee.co"},
+		{Type: "slack_bot", Value: "#prod"},
+	}, notifications)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+	}
+
+	assert.Equal(t, time.Second, policy.Backoff(1))
+	assert.Equal(t, 2*time.Second, policy.Backoff(2))
+	assert.Equal(t, 4*time.Second, policy.Backoff(3))
+	assert.Equal(t, 5*time.Second, policy.Backoff(4))
+	assert.Equal(t, 5*time.Second, policy.Backoff(100))
+}
+
+type errNotifier struct {
+	err error
+}
+
+func (n *errNotifier) Notify(ctx context.Context, target string, alert *Alert) error {
+	return n.err
+}
+
+func TestDeliver(t *testing.T) {
+	notifier := &errNotifier{}
+	r := NewRouter(&RouterConfig{})
+	r.ctx = context.Background()
+	r.Register("email", notifier, RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Second, MaxBackoff: time.Second})
+
+	payload, err := json.Marshal(testAlert(t))
+	assert.Nil(t, err)
+	newDelivery := func() *store.NotificationDelivery {
+		return &store.NotificationDelivery{Type: "email", Value: "dan@opsee.co", Payload: payload, Status: store.DeliveryPending}
+	}
+
+	delivery := newDelivery()
+	r.deliver(delivery)
+	assert.Equal(t, store.DeliveryDelivered, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+
+	notifier.err = ErrSuppressed
+	delivery = newDelivery()
+	r.deliver(delivery)
+	assert.Equal(t, store.DeliverySuppressed, delivery.Status)
+
+	// deferred attempts don't count, so they never fail the delivery
+	until := time.Now().Add(time.Hour)
+	notifier.err = Defer(errors.New("quiet"), until)
+	delivery = newDelivery()
+	for i := 0; i < 3; i++ {
+		r.deliver(delivery)
+	}
+	assert.Equal(t, store.DeliveryPending, delivery.Status)
+	assert.Equal(t, 0, delivery.Attempts)
+	assert.Equal(t, until, delivery.NextAttemptAt)
+
+	notifier.err = errors.New("down")
+	r.deliver(delivery)
+	assert.Equal(t, store.DeliveryPending, delivery.Status)
+	r.deliver(delivery)
+	assert.Equal(t, store.DeliveryFailed, delivery.Status)
+}
+
+func TestEmailNotifierPreferences(t *testing.T) {
+	defer func() { mailer.Preferences = nil }()
+
+	prefs := preferences.Default()
+	mailer.Preferences = func(email string) (*preferences.Preferences, error) {
+		return prefs, nil
+	}
+
+	notifier := &EmailNotifier{}
+	prefs.Categories[preferences.Alerts] = []preferences.Channel{}
+	assert.Equal(t, ErrSuppressed, notifier.Notify(context.Background(), "dan@opsee.co", testAlert(t)))
+
+	// quiet all day but the last minute
+	now := time.Now().UTC()
+	end := now.Add(-time.Minute).Format("15:04")
+	prefs.Categories = map[preferences.Category][]preferences.Channel{}
+	prefs.QuietHours = &preferences.QuietHours{Start: now.Format("15:04"), End: end}
+
+	until, ok := deferredUntil(notifier.Notify(context.Background(), "dan@opsee.co", testAlert(t)))
+	assert.True(t, ok)
+	assert.Equal(t, end, until.UTC().Format("15:04"))
+	assert.True(t, until.After(now))
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	status := http.StatusOK
+	var (
+		received  *WebhookPayload
+		verifyErr error
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		verifyErr = VerifySignature("secret", r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader), body, time.Minute, time.Now())
+		received = &WebhookPayload{}
+		json.Unmarshal(body, received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	notifier := &WebhookNotifier{
+		Secret: func(customerId string) (string, error) {
+			return "secret", nil
+		},
+		LinkBase: "https://app.opsee.com",
+	}
+	alert := testAlert(t)
+
+	assert.Nil(t, notifier.Notify(context.Background(), server.URL, alert))
+	assert.Nil(t, verifyErr)
+	assert.Equal(t, WebhookPayloadVersion, received.Version)
+	assert.Equal(t, "my check", received.Check.Name)
+	assert.Equal(t, "FAIL_WAIT", received.FromState)
+	assert.Equal(t, "FAIL", received.ToState)
+	assert.Equal(t, "https://app.opsee.com/check/check-id/event/1", received.SnapshotURL)
+	assert.Len(t, received.FailingResponses, 1)
+	assert.Equal(t, "i-1", received.FailingResponses[0].Target)
+
+	status = http.StatusInternalServerError
+	err := notifier.Notify(context.Background(), server.URL, alert)
+	assert.NotNil(t, err)
+	assert.False(t, IsPermanent(err))
+
+	status = http.StatusNotFound
+	err = notifier.Notify(context.Background(), server.URL, alert)
+	assert.True(t, IsPermanent(err))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"version":1}`)
+	now := time.Unix(1460000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := Sign("secret", now.Unix(), body)
+
+	assert.Nil(t, VerifySignature("secret", ts, sig, body, time.Minute, now))
+	assert.NotNil(t, VerifySignature("other", ts, sig, body, time.Minute, now))
+	assert.NotNil(t, VerifySignature("secret", ts, sig, []byte(`{"version":2}`), time.Minute, now))
+	// replayed later
+	assert.NotNil(t, VerifySignature("secret", ts, sig, body, time.Minute, now.Add(10*time.Minute)))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(1460000000, 0)
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	failure := errors.New("")
+	assert.Nil(t, breaker.Allow("a"))
+	breaker.Record("a", failure)
+	assert.Nil(t, breaker.Allow("a"))
+	breaker.Record("a", failure)
+
+	assert.Equal(t, ErrCircuitOpen, breaker.Allow("a"))
+	assert.Equal(t, now.Add(time.Minute), breaker.OpenUntil("a"))
+	assert.Nil(t, breaker.Allow("b"))
+	assert.True(t, breaker.OpenUntil("b").IsZero())
+
+	// one trial after the cooldown
+	now = now.Add(time.Minute)
+	assert.Nil(t, breaker.Allow("a"))
+	assert.Equal(t, ErrCircuitOpen, breaker.Allow("a"))
+
+	breaker.Record("a", nil)
+	assert.Nil(t, breaker.Allow("a"))
+	assert.True(t, breaker.OpenUntil("a").IsZero())
+
+	// deliveries to an open circuit are deferred until it lets a call through
+	breaker.Record("a", failure)
+	breaker.Record("a", failure)
+	notifier := &WebhookNotifier{Breaker: breaker}
+	err := notifier.Notify(context.Background(), "a", testAlert(t))
+	until, ok := deferredUntil(err)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(time.Minute), until)
+}
+
+func TestSlackWebhookNotifier(t *testing.T) {
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer server.Close()
+
+	notifier := &SlackWebhookNotifier{}
+	assert.Nil(t, notifier.Notify(context.Background(), server.URL, testAlert(t)))
+	assert.True(t, strings.HasPrefix(body["text"], "Check *my check* is failing (FAIL_WAIT → FAIL)"))
+	assert.Contains(t, body["text"], "• i-1: HTTP 500")
+}
+
+func TestPagerDutyNotifier(t *testing.T) {
+	var event map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&event)
+	}))
+	defer server.Close()
+
+	notifier := &PagerDutyNotifier{URL: server.URL}
+	alert := testAlert(t)
+	assert.Nil(t, notifier.Notify(context.Background(), "service-key", alert))
+	assert.Equal(t, "service-key", event["service_key"])
+	assert.Equal(t, "trigger", event["event_type"])
+	assert.Equal(t, "opsee-check-id", event["incident_key"])
+	assert.True(t, strings.HasPrefix(event["description"].(string), "Check *my check* is failing"))
+
+	alert.Passing = true
+	assert.Nil(t, notifier.Notify(context.Background(), "service-key", alert))
+	assert.Equal(t, "resolve", event["event_type"])
+	assert.Equal(t, "opsee-check-id", event["incident_key"])
+
+	// long descriptions are cut on a character boundary
+	alert.CheckName = strings.Repeat("é", 1024)
+	assert.Nil(t, notifier.Notify(context.Background(), "service-key", alert))
+	description := event["description"].(string)
+	assert.True(t, utf8.ValidString(description))
+	assert.Equal(t, 1024, utf8.RuneCountInString(description))
+	assert.True(t, strings.HasSuffix(description, "é..."))
+}
+
+func TestGovernorStep(t *testing.T) {
+	g := NewGovernor(&GovernorConfig{Rate: 2, Window: time.Minute})
+	start := time.Unix(1460000000, 0)
+	state := &store.NotificationGovernor{WindowStart: start}
+
+	// up to the rate, alerts are sent
+	assert.True(t, g.step(state, start))
+	assert.True(t, g.step(state, start.Add(time.Second)))
+
+	// past it, they are held for the rest of the window
+	assert.False(t, g.step(state, start.Add(2*time.Second)))
+	assert.False(t, g.step(state, start.Add(3*time.Second)))
+	assert.True(t, state.Digesting)
+
+	// a busy window keeps digesting
+	next := start.Add(time.Minute)
+	assert.False(t, g.step(state, next))
+	assert.False(t, g.step(state, next.Add(time.Second)))
+	assert.False(t, g.step(state, next.Add(2*time.Second)))
+
+	// the window after that was busy too
+	next = next.Add(time.Minute)
+	assert.False(t, g.step(state, next))
+	assert.Equal(t, 1, state.WindowCount)
+
+	// a calm window stops digesting
+	next = next.Add(time.Minute)
+	assert.True(t, g.step(state, next))
+	assert.False(t, state.Digesting)
+
+	// so does a window with no alerts at all
+	state.Digesting = true
+	state.WindowCount = 10
+	assert.True(t, g.step(state, next.Add(2*time.Minute)))
+}
+
+// fakeGovernorStore keeps one team's governor and digest entries, and
+// treats sent transitions as having deliveries.
+type fakeGovernorStore struct {
+	store.GovernorStore
+	state   *store.NotificationGovernor
+	entries []*store.DigestEntry
+	sent    map[int64]bool
+}
+
+func (s *fakeGovernorStore) IsCriticalCheck(customerId, checkId string) (bool, error) {
+	return false, nil
+}
+
+func (s *fakeGovernorStore) LockGovernor(customerId string) (*store.NotificationGovernor, error) {
+	return s.state, nil
+}
+
+func (s *fakeGovernorStore) UpdateGovernor(governor *store.NotificationGovernor) error {
+	return nil
+}
+
+func (s *fakeGovernorStore) GetAdmission(customerId string, transitionId int64) (bool, bool, error) {
+	for _, e := range s.entries {
+		if e.TransitionId == transitionId {
+			return true, true, nil
+		}
+	}
+
+	return s.sent[transitionId], false, nil
+}
+
+func (s *fakeGovernorStore) PutDigestEntry(entry *store.DigestEntry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestGovernorAdmitRetries(t *testing.T) {
+	g := NewGovernor(&GovernorConfig{Rate: 1, Window: time.Minute})
+	now := time.Unix(1460000000, 0)
+	governorStore := &fakeGovernorStore{state: &store.NotificationGovernor{WindowStart: now}, sent: map[int64]bool{}}
+
+	admit := func(transitionId int64) bool {
+		send, err := g.Admit(governorStore, &Alert{CustomerId: "customer-id", TransitionId: transitionId}, now)
+		assert.Nil(t, err)
+		if send {
+			governorStore.sent[transitionId] = true
+		}
+		return send
+	}
+
+	assert.True(t, admit(1))
+	assert.False(t, admit(2))
+
+	// retries get the same answer, and aren't counted or held again
+	assert.True(t, admit(1))
+	assert.False(t, admit(2))
+	assert.Equal(t, 2, governorStore.state.WindowCount)
+	assert.Len(t, governorStore.entries, 1)
+}
+
+func TestDigest(t *testing.T) {
+	start := time.Unix(1460000000, 0)
+	entries := []*store.DigestEntry{
+		{Id: 1, CheckId: "a", CheckName: "check a", Passing: false, CreatedAt: start},
+		{Id: 2, CheckId: "b", CheckName: "check b", Passing: false, CreatedAt: start},
+		{Id: 3, CheckId: "a", CheckName: "check a", Passing: true, CreatedAt: start},
+		{Id: 4, CheckId: "c", Passing: false, CreatedAt: start},
+	}
+
+	digest := NewDigest(entries, start.Add(5*time.Minute))
+	assert.Equal(t, []string{"check b", "c"}, digest.Failing)
+	assert.Equal(t, []string{"check a"}, digest.Recovered)
+	assert.Equal(t, 4, digest.Alerts)
+	assert.Equal(t, "2 checks failing, 1 recovered in the last 5 minutes", digest.Text())
+
+	alert := NewDigestAlert("customer", digest)
+	assert.False(t, alert.Passing)
+
+	payload := (&WebhookNotifier{}).Payload(alert)
+	assert.Equal(t, "check.digest", payload.Event)
+	assert.Equal(t, digest, payload.Digest)
+	assert.Equal(t, "", payload.SnapshotURL)
+
+	assert.True(t, strings.HasPrefix(slackText(alert), "2 checks failing, 1 recovered in the last 5 minutes."))
+}
+
+func TestGroupDigestEntries(t *testing.T) {
+	entries := []*store.DigestEntry{
+		{Id: 1, CheckId: "a"},
+		{Id: 2, CheckId: "b"},
+		{Id: 3, CheckId: "orphan"},
+		{Id: 4, CheckId: "a"},
+	}
+
+	ops := &schema.Notification{Type: "email", Value: "ops@example.com"}
+	notifications := map[string][]*schema.Notification{
+		"a": {ops, {Type: "slack_webhook", Value: "https://hooks.slack.com/a"}},
+		"b": {{Type: "email", Value: "ops@example.com"}},
+	}
+
+	calls := 0
+	groups, err := groupDigestEntries(entries, func(checkId string) ([]*schema.Notification, error) {
+		calls++
+		return notifications[checkId], nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	assert.Len(t, groups, 2)
+	assert.Equal(t, ops, groups[0].notification)
+	assert.Equal(t, []*store.DigestEntry{entries[0], entries[1], entries[3]}, groups[0].entries)
+	assert.Equal(t, "slack_webhook", groups[1].notification.Type)
+	assert.Equal(t, []*store.DigestEntry{entries[0], entries[3]}, groups[1].entries)
+
+	_, err = groupDigestEntries(entries, func(checkId string) ([]*schema.Notification, error) {
+		return nil, errors.New("db down")
+	})
+	assert.Error(t, err)
+}
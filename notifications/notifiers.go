@@ -0,0 +1,208 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/opsee/cats/mailer"
+	"github.com/opsee/cats/preferences"
+	"golang.org/x/net/context"
+)
+
+// EmailNotifier sends alerts with the mailer package, using the check-fail,
+// check-pass or check-digest template, unless the recipient has opted out of
+// alert emails. Alerts during the recipient's quiet hours are deferred until
+// they end.
+type EmailNotifier struct{}
+
+func (n *EmailNotifier) Notify(ctx context.Context, target string, alert *Alert) error {
+	template := "check-fail"
+	if alert.Passing {
+		template = "check-pass"
+	}
+
+	vars := map[string]interface{}{
+		"check_id":       alert.CheckId,
+		"check_name":     alert.CheckName,
+		"state":          alert.State,
+		"previous_state": alert.PreviousState,
+		"failing_count":  alert.FailingCount,
+		"response_count": alert.ResponseCount,
+		"timestamp":      alert.Timestamp.Unix(),
+	}
+
+	if alert.Digest != nil {
+		template = "check-digest"
+		vars = map[string]interface{}{
+			"text":            alert.Digest.Text(),
+			"failing":         alert.Digest.Failing,
+			"recovered":       alert.Digest.Recovered,
+			"failing_count":   len(alert.Digest.Failing),
+			"recovered_count": len(alert.Digest.Recovered),
+			"timestamp":       alert.Timestamp.Unix(),
+		}
+	}
+
+	responses, err := mailer.SendCategory(preferences.Alerts, target, "", template, vars)
+	if err == mailer.ErrSuppressed {
+		return ErrSuppressed
+	}
+
+	if quiet, ok := err.(*mailer.QuietError); ok {
+		return Defer(err, quiet.Until)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, r := range responses {
+		if r.Status == "rejected" || r.Status == "invalid" {
+			return Permanent(fmt.Errorf("email to %s was %s: %s", r.Email, r.Status, r.RejectionReason))
+		}
+	}
+
+	return nil
+}
+
+// SlackWebhookNotifier posts a message to a Slack incoming webhook URL.
+type SlackWebhookNotifier struct {
+	Client *http.Client
+}
+
+func (n *SlackWebhookNotifier) Notify(ctx context.Context, target string, alert *Alert) error {
+	body, err := json.Marshal(map[string]string{
+		"text": slackText(alert),
+	})
+	if err != nil {
+		return err
+	}
+
+	return post(ctx, n.Client, target, body)
+}
+
+func slackText(alert *Alert) string {
+	if alert.Digest != nil {
+		text := alert.Digest.Text() + "."
+		if len(alert.Digest.Failing) > 0 {
+			text += "\nFailing: " + strings.Join(alert.Digest.Failing, ", ")
+		}
+		return text
+	}
+
+	status := "failing"
+	if alert.Passing {
+		status = "passing"
+	}
+
+	text := fmt.Sprintf("Check *%s* is %s (%s → %s): %d of %d responses failing.",
+		alert.CheckName, status, alert.PreviousState, alert.State, alert.FailingCount, alert.ResponseCount)
+
+	var failing []string
+	for _, r := range alert.Responses {
+		if r.Passing {
+			continue
+		}
+
+		line := fmt.Sprintf("• %s", r.Target)
+		if r.Error != "" {
+			line += ": " + r.Error
+		} else if r.Code != 0 {
+			line += fmt.Sprintf(": HTTP %d", r.Code)
+		}
+		failing = append(failing, line)
+	}
+
+	if len(failing) > 0 {
+		text += "\n" + strings.Join(failing, "\n")
+	}
+
+	return text
+}
+
+// PagerDutyEventsURL is the PagerDuty generic events API endpoint.
+const PagerDutyEventsURL = "https://events.pagerduty.com/generic/2010-04-15/create_event.json"
+
+// PagerDutyNotifier triggers a PagerDuty incident for a failing check and
+// resolves it when the check passes. The target is the service key.
+type PagerDutyNotifier struct {
+	Client *http.Client
+	// URL is the events API endpoint, PagerDutyEventsURL if empty.
+	URL string
+}
+
+func (n *PagerDutyNotifier) Notify(ctx context.Context, target string, alert *Alert) error {
+	event := map[string]interface{}{
+		"service_key":  target,
+		"event_type":   "trigger",
+		"incident_key": "opsee-" + alert.CheckId,
+		"client":       "Opsee",
+		"details":      alert,
+	}
+
+	switch {
+	case alert.Digest != nil:
+		event["incident_key"] = fmt.Sprintf("opsee-digest-%s-%d", alert.CustomerId, alert.Timestamp.Unix())
+	case alert.Passing:
+		event["event_type"] = "resolve"
+	}
+
+	// descriptions longer than 1024 characters are rejected
+	description := slackText(alert)
+	if runes := []rune(description); len(runes) > 1024 {
+		description = string(runes[:1021]) + "..."
+	}
+	event["description"] = description
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	url := n.URL
+	if url == "" {
+		url = PagerDutyEventsURL
+	}
+
+	return post(ctx, n.Client, url, body)
+}
+
+// post sends a JSON body with any extra headers. Client errors other than
+// rate limiting are permanent; everything else is retried.
+func post(ctx context.Context, client *http.Client, url string, body []byte, headers ...string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("%s returned %s", url, resp.Status)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+
+	return err
+}
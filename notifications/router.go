@@ -0,0 +1,536 @@
+package notifications
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks/labels"
+	"github.com/opsee/cats/checks/outbox"
+	"github.com/opsee/cats/store"
+	log "github.com/opsee/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+var (
+	notificationDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_deliveries",
+		Help: "Total number of notification delivery attempts, by notification type and outcome.",
+	}, []string{"type", "status"})
+)
+
+func init() {
+	prometheus.MustRegister(notificationDeliveries)
+}
+
+type RouterConfig struct {
+	DB           *sqlx.DB
+	BatchSize    int
+	PollInterval time.Duration
+	// Governor, if set, limits the rate of alerts sent to each team.
+	Governor *Governor
+}
+
+type registration struct {
+	notifier Notifier
+	policy   RetryPolicy
+}
+
+// Router fans alerts out to the notifications configured for a check and
+// delivers them with the Notifier registered for each notification type.
+type Router struct {
+	db           *sqlx.DB
+	batchSize    int
+	pollInterval time.Duration
+	governor     *Governor
+	notifiers    map[string]*registration
+	ctx          context.Context
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
+}
+
+func NewRouter(cfg *RouterConfig) *Router {
+	r := &Router{
+		db:           cfg.DB,
+		batchSize:    cfg.BatchSize,
+		pollInterval: cfg.PollInterval,
+		governor:     cfg.Governor,
+		notifiers:    make(map[string]*registration),
+		stopChan:     make(chan struct{}),
+	}
+
+	if r.batchSize < 1 {
+		r.batchSize = 10
+	}
+
+	if r.pollInterval <= 0 {
+		r.pollInterval = time.Second
+	}
+
+	return r
+}
+
+// Register sets the Notifier and RetryPolicy for a notification type, e.g.
+// "email". It must be called before Start.
+func (r *Router) Register(notificationType string, notifier Notifier, policy RetryPolicy) {
+	r.notifiers[notificationType] = &registration{
+		notifier: notifier,
+		policy:   policy,
+	}
+}
+
+// Route records a pending delivery for every notification of the check that
+// transitioned, or the customer's default notifications if it has none, and
+// of the notification routes matching its labels, unless the Governor holds
+// the alert for a digest. It is an outbox.DeliveryFunc for
+// alert entries, and is safe to retry: deliveries are recorded once, and
+// the Governor admits each transition once.
+func (r *Router) Route(ctx context.Context, event *outbox.TransitionEvent) error {
+	logger := log.WithFields(log.Fields{
+		"customer_id":   event.CustomerId,
+		"check_id":      event.CheckId,
+		"transition_id": event.TransitionId,
+	})
+
+	var check struct {
+		Name       string `db:"name"`
+		TargetName string `db:"target_name"`
+	}
+	err := r.db.Get(&check, "SELECT name, COALESCE(target_name, target_id) AS target_name FROM checks WHERE id = $1 AND customer_id = $2", event.CheckId, event.CustomerId)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	checkLabels, err := store.NewCheckStore(r.db).GetCheckLabels(event.CustomerId, []string{event.CheckId})
+	if err != nil {
+		return err
+	}
+
+	alert, err := NewAlert(event, check.Name)
+	if err != nil {
+		return err
+	}
+	alert.TargetName = check.TargetName
+	alert.Labels = checkLabels[event.CheckId]
+
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if r.governor != nil {
+		send, err := r.governor.Admit(store.NewGovernorStore(tx), alert, time.Now())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if !send {
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+
+			logger.Info("Held alert for digest.")
+			return nil
+		}
+	}
+
+	notificationStore := store.NewNotificationStore(tx)
+	routes, err := notificationStore.GetNotificationRoutes(event.CustomerId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	notifications, err := alertNotifications(notificationStore, event.CustomerId, event.CheckId, routes, alert.Labels)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, n := range notifications {
+		err := notificationStore.PutDelivery(&store.NotificationDelivery{
+			TransitionId: event.TransitionId,
+			CheckId:      event.CheckId,
+			CustomerId:   event.CustomerId,
+			Type:         n.Type,
+			Value:        n.Value,
+			Payload:      payload,
+		})
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logger.Infof("Routed alert to %d notifications.", len(notifications))
+	return nil
+}
+
+// alertNotifications returns the notifications for a check's alerts: its
+// own, or the customer's default notifications if it has none, and those of
+// the routes matching its labels.
+func alertNotifications(notificationStore store.NotificationStore, customerId, checkId string, routes []*store.NotificationRoute, checkLabels labels.Labels) ([]*schema.Notification, error) {
+	notifications, err := notificationStore.GetCheckNotifications(customerId, checkId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(notifications) == 0 {
+		notifications, err = notificationStore.GetDefaultNotifications(customerId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(notifications, routedNotifications(routes, checkLabels)...), nil
+}
+
+// routedNotifications returns the notifications of the routes whose
+// selectors match a check's labels. Routes with invalid selectors match
+// nothing.
+func routedNotifications(routes []*store.NotificationRoute, checkLabels labels.Labels) []*schema.Notification {
+	var notifications []*schema.Notification
+
+	for _, route := range routes {
+		selector, err := labels.Parse(route.Selector)
+		if err != nil {
+			log.WithError(err).WithField("route_id", route.Id).Warn("Invalid notification route selector.")
+			continue
+		}
+
+		if selector.Matches(checkLabels) {
+			notifications = append(notifications, &schema.Notification{Type: route.Type, Value: route.Value})
+		}
+	}
+
+	return notifications
+}
+
+// Start starts delivering pending deliveries with ctx.
+func (r *Router) Start(ctx context.Context) {
+	r.ctx = ctx
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+
+		// digests is nil, and never ready, without a governor
+		var digests <-chan time.Time
+		if r.governor != nil {
+			digestTicker := time.NewTicker(r.governor.digestInterval)
+			defer digestTicker.Stop()
+			digests = digestTicker.C
+		}
+
+		for {
+			select {
+			case <-r.stopChan:
+				return
+			case <-r.ctx.Done():
+				return
+			case <-digests:
+				r.flushDigests()
+			case <-ticker.C:
+				for {
+					n, err := r.poll()
+					if err != nil {
+						log.WithError(err).Error("Error polling notification deliveries.")
+					}
+					if err != nil || n < r.batchSize {
+						break
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops delivering and waits for the batch in flight to finish. If ctx
+// is done first, Stop returns ctx.Err().
+func (r *Router) Stop(ctx context.Context) error {
+	close(r.stopChan)
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flushDigests sends the alerts held for each team as a digest.
+func (r *Router) flushDigests() {
+	customerIds, err := store.NewGovernorStore(r.db).GetDigestCustomers()
+	if err != nil {
+		log.WithError(err).Error("Error getting digest customers.")
+		return
+	}
+
+	for _, customerId := range customerIds {
+		if r.ctx.Err() != nil {
+			return
+		}
+
+		if err := r.flushDigest(customerId); err != nil {
+			log.WithError(err).WithField("customer_id", customerId).Error("Error sending notification digest.")
+		}
+	}
+}
+
+// flushDigest records a pending delivery of a team's digest for each
+// notification of the checks it covers, as they would be routed by Route.
+// Each notification's digest has only the alerts of its checks. Entries are deleted once they've been
+// delivered, so that alerts for checks without any notifications wait for
+// the next digest.
+func (r *Router) flushDigest(customerId string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	governorStore := store.NewGovernorStore(tx)
+	if _, err := governorStore.LockGovernor(customerId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	entries, err := governorStore.GetDigestEntries(customerId)
+	if err != nil || len(entries) == 0 {
+		tx.Rollback()
+		return err
+	}
+
+	checkIds := make([]string, len(entries))
+	for i, entry := range entries {
+		checkIds[i] = entry.CheckId
+	}
+
+	checkLabels, err := store.NewCheckStore(tx).GetCheckLabels(customerId, checkIds)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	notificationStore := store.NewNotificationStore(tx)
+	routes, err := notificationStore.GetNotificationRoutes(customerId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	groups, err := groupDigestEntries(entries, func(checkId string) ([]*schema.Notification, error) {
+		return alertNotifications(notificationStore, customerId, checkId, routes, checkLabels[checkId])
+	})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	now := time.Now()
+	delivered := make(map[int64]bool, len(entries))
+	for _, group := range groups {
+		payload, err := json.Marshal(NewDigestAlert(customerId, NewDigest(group.entries, now)))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		err = notificationStore.PutDelivery(&store.NotificationDelivery{
+			DigestId:   group.entries[len(group.entries)-1].Id,
+			CustomerId: customerId,
+			Type:       group.notification.Type,
+			Value:      group.notification.Value,
+			Payload:    payload,
+		})
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		for _, entry := range group.entries {
+			delivered[entry.Id] = true
+		}
+	}
+
+	ids := make([]int64, 0, len(delivered))
+	for _, entry := range entries {
+		if delivered[entry.Id] {
+			ids = append(ids, entry.Id)
+		}
+	}
+
+	if err := governorStore.DeleteDigestEntries(customerId, ids); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.WithField("customer_id", customerId).Infof("Sent digest of %d alerts to %d notifications.", len(ids), len(groups))
+	return nil
+}
+
+// digestGroup is the digest entries sent to a notification.
+type digestGroup struct {
+	notification *schema.Notification
+	entries      []*store.DigestEntry
+}
+
+// groupDigestEntries groups digest entries by the notifications of their
+// checks, as returned by notificationsFor, in the order they're first seen.
+// Entries for checks without notifications aren't in any group.
+func groupDigestEntries(entries []*store.DigestEntry, notificationsFor func(checkId string) ([]*schema.Notification, error)) ([]*digestGroup, error) {
+	var (
+		groups  []*digestGroup
+		byValue = make(map[schema.Notification]*digestGroup)
+		byCheck = make(map[string][]*schema.Notification)
+	)
+
+	for _, entry := range entries {
+		notifications, ok := byCheck[entry.CheckId]
+		if !ok {
+			var err error
+			if notifications, err = notificationsFor(entry.CheckId); err != nil {
+				return nil, err
+			}
+			byCheck[entry.CheckId] = notifications
+		}
+
+		for _, n := range notifications {
+			key := schema.Notification{Type: n.Type, Value: n.Value}
+			group, ok := byValue[key]
+			if !ok {
+				group = &digestGroup{notification: n}
+				byValue[key] = group
+				groups = append(groups, group)
+			}
+
+			// a check with the same notification twice is sent it once
+			if len(group.entries) == 0 || group.entries[len(group.entries)-1] != entry {
+				group.entries = append(group.entries, entry)
+			}
+		}
+	}
+
+	return groups, nil
+}
+
+// poll claims a batch of due deliveries and attempts them, returning how many
+// were attempted.
+func (r *Router) poll() (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	notificationStore := store.NewNotificationStore(tx)
+	deliveries, err := notificationStore.ClaimDeliveries(r.batchSize)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	handled := 0
+	for _, delivery := range deliveries {
+		if r.ctx.Err() != nil {
+			break
+		}
+
+		r.deliver(delivery)
+		if err := notificationStore.UpdateDelivery(delivery); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		handled++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return handled, nil
+}
+
+// deliver attempts a delivery and updates its status, attempts and next
+// attempt time. A deferred attempt doesn't count.
+func (r *Router) deliver(delivery *store.NotificationDelivery) {
+	logger := log.WithFields(log.Fields{
+		"delivery_id":   delivery.Id,
+		"customer_id":   delivery.CustomerId,
+		"check_id":      delivery.CheckId,
+		"transition_id": delivery.TransitionId,
+		"type":          delivery.Type,
+	})
+
+	delivery.Attempts++
+
+	reg, ok := r.notifiers[delivery.Type]
+	if !ok {
+		delivery.Status = store.DeliveryUnsupported
+		delivery.LastError = errNoNotifier.Error()
+		notificationDeliveries.WithLabelValues(delivery.Type, delivery.Status).Inc()
+		logger.Warn("No notifier for notification type.")
+		return
+	}
+
+	alert := &Alert{}
+	err := json.Unmarshal(delivery.Payload, alert)
+	if err == nil {
+		err = reg.notifier.Notify(r.ctx, delivery.Value, alert)
+	} else {
+		err = Permanent(err)
+	}
+
+	if until, ok := deferredUntil(err); ok {
+		delivery.Attempts--
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = until
+		notificationDeliveries.WithLabelValues(delivery.Type, delivery.Status).Inc()
+		logger.WithError(err).Info("Notification delivery deferred.")
+		return
+	}
+
+	switch {
+	case err == nil:
+		delivery.Status = store.DeliveryDelivered
+		delivery.LastError = ""
+	case err == ErrSuppressed:
+		delivery.Status = store.DeliverySuppressed
+		delivery.LastError = err.Error()
+		logger.Info("Notification delivery suppressed by the recipient's preferences.")
+	case IsPermanent(err) || delivery.Attempts >= reg.policy.MaxAttempts:
+		delivery.Status = store.DeliveryFailed
+		delivery.LastError = err.Error()
+		logger.WithError(err).Error("Notification delivery failed.")
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(reg.policy.Backoff(delivery.Attempts))
+		logger.WithError(err).Warn("Notification delivery failed, retrying.")
+	}
+
+	notificationDeliveries.WithLabelValues(delivery.Type, delivery.Status).Inc()
+}
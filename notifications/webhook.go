@@ -0,0 +1,158 @@
+package notifications
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// WebhookPayloadVersion is the version of WebhookPayload. It is bumped
+	// for changes that aren't backwards compatible.
+	WebhookPayloadVersion = 1
+
+	TimestampHeader = "X-Opsee-Timestamp"
+	SignatureHeader = "X-Opsee-Signature"
+
+	signaturePrefix = "v1="
+)
+
+// WebhookPayload is the body of a webhook request.
+type WebhookPayload struct {
+	Version          int                `json:"version"`
+	Event            string             `json:"event"`
+	TransitionId     int64              `json:"transition_id"`
+	CustomerId       string             `json:"customer_id"`
+	Check            WebhookCheck       `json:"check"`
+	FromState        string             `json:"from_state"`
+	ToState          string             `json:"to_state"`
+	Passing          bool               `json:"passing"`
+	Timestamp        time.Time          `json:"timestamp"`
+	FailingResponses []*ResponseSummary `json:"failing_responses"`
+	SnapshotURL      string             `json:"snapshot_url,omitempty"`
+	Digest           *Digest            `json:"digest,omitempty"`
+}
+
+type WebhookCheck struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Sign returns the signature header value for a webhook body sent at
+// timestamp: the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the
+// customer's secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the signature and timestamp headers of a webhook
+// request. Requests with a timestamp more than tolerance from now are
+// rejected to prevent replays.
+func VerifySignature(secret, timestamp, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %s", timestamp)
+	}
+
+	skew := now.Sub(time.Unix(ts, 0))
+	if skew > tolerance || skew < -tolerance {
+		return fmt.Errorf("timestamp %s is outside of tolerance", timestamp)
+	}
+
+	if !hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature)) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	return nil
+}
+
+// WebhookNotifier posts a signed WebhookPayload to a URL. Calls to each URL
+// go through Breaker, if it is set.
+type WebhookNotifier struct {
+	Client *http.Client
+	// Secret returns the customer's signing secret.
+	Secret func(customerId string) (string, error)
+	// Breaker stops requests to endpoints that keep failing.
+	Breaker *CircuitBreaker
+	// LinkBase is the base URL of snapshot links, e.g. https://app.opsee.com
+	LinkBase string
+}
+
+func (n *WebhookNotifier) Payload(alert *Alert) *WebhookPayload {
+	if alert.Digest != nil {
+		return &WebhookPayload{
+			Version:          WebhookPayloadVersion,
+			Event:            "check.digest",
+			CustomerId:       alert.CustomerId,
+			Passing:          alert.Passing,
+			Timestamp:        alert.Timestamp,
+			FailingResponses: []*ResponseSummary{},
+			Digest:           alert.Digest,
+		}
+	}
+
+	payload := &WebhookPayload{
+		Version:      WebhookPayloadVersion,
+		Event:        "check.transition",
+		TransitionId: alert.TransitionId,
+		CustomerId:   alert.CustomerId,
+		Check: WebhookCheck{
+			Id:   alert.CheckId,
+			Name: alert.CheckName,
+		},
+		FromState:        alert.PreviousState,
+		ToState:          alert.State,
+		Passing:          alert.Passing,
+		Timestamp:        alert.Timestamp,
+		FailingResponses: []*ResponseSummary{},
+		SnapshotURL:      fmt.Sprintf("%s/check/%s/event/%d", n.LinkBase, alert.CheckId, alert.TransitionId),
+	}
+
+	for _, r := range alert.Responses {
+		if !r.Passing {
+			payload.FailingResponses = append(payload.FailingResponses, r)
+		}
+	}
+
+	return payload
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, target string, alert *Alert) error {
+	if n.Breaker != nil {
+		if err := n.Breaker.Allow(target); err != nil {
+			return Defer(err, n.Breaker.OpenUntil(target))
+		}
+	}
+
+	secret, err := n.Secret(alert.CustomerId)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(n.Payload(alert))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	err = post(ctx, n.Client, target, body,
+		TimestampHeader, strconv.FormatInt(timestamp, 10),
+		SignatureHeader, Sign(secret, timestamp, body),
+	)
+
+	if n.Breaker != nil {
+		n.Breaker.Record(target, err)
+	}
+
+	return err
+}
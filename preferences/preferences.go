@@ -0,0 +1,193 @@
+// Package preferences is the per-user notification preferences model: which
+// categories of notification a user wants, on which channels, and when they
+// don't want to be disturbed.
+package preferences
+
+import (
+	"fmt"
+	"time"
+)
+
+// Category is a kind of notification a user can opt out of.
+type Category string
+
+const (
+	Alerts      Category = "alerts"
+	Billing     Category = "billing"
+	Product     Category = "product"
+	TeamInvites Category = "team_invites"
+)
+
+// Channel is a way a notification reaches a user. Slack notifications go to
+// team channels rather than to a user, so only Email preferences are
+// enforced for now.
+type Channel string
+
+const (
+	Email Channel = "email"
+	Slack Channel = "slack"
+)
+
+var (
+	Categories = []Category{Alerts, Billing, Product, TeamInvites}
+	Channels   = []Channel{Email, Slack}
+)
+
+// QuietHours is a daily period, in the user's timezone, during which alerts
+// and product notifications are not sent. Start and End are "15:04" times;
+// a period that ends before it starts spans midnight.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Preferences are a user's notification preferences. Categories that aren't
+// set are sent on every channel; a category set to no channels is not sent
+// at all.
+type Preferences struct {
+	Timezone   string                 `json:"timezone,omitempty"`
+	QuietHours *QuietHours            `json:"quiet_hours,omitempty"`
+	Categories map[Category][]Channel `json:"categories,omitempty"`
+}
+
+// Default returns the preferences of a user who hasn't set any: everything,
+// everywhere, any time.
+func Default() *Preferences {
+	return &Preferences{
+		Categories: map[Category][]Channel{},
+	}
+}
+
+// Validate checks that every category, channel, timezone and time is known.
+func (p *Preferences) Validate() error {
+	for category, channels := range p.Categories {
+		if !validCategory(category) {
+			return fmt.Errorf("unknown notification category: %s", category)
+		}
+
+		for _, channel := range channels {
+			if !validChannel(channel) {
+				return fmt.Errorf("unknown notification channel: %s", channel)
+			}
+		}
+	}
+
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone: %s", p.Timezone)
+	}
+
+	if p.QuietHours != nil {
+		if _, err := time.Parse("15:04", p.QuietHours.Start); err != nil {
+			return fmt.Errorf("invalid quiet hours start: %s", p.QuietHours.Start)
+		}
+
+		if _, err := time.Parse("15:04", p.QuietHours.End); err != nil {
+			return fmt.Errorf("invalid quiet hours end: %s", p.QuietHours.End)
+		}
+	}
+
+	return nil
+}
+
+// Wants returns true if the user hasn't opted out of notifications of
+// category on channel, whether or not they're quiet.
+func (p *Preferences) Wants(category Category, channel Channel) bool {
+	channels, ok := p.Categories[category]
+	return !ok || hasChannel(channels, channel)
+}
+
+// Allows returns true if the user wants a notification of category on
+// channel at now.
+func (p *Preferences) Allows(category Category, channel Channel, now time.Time) bool {
+	if !p.Wants(category, channel) {
+		return false
+	}
+
+	if category == Alerts || category == Product {
+		return !p.Quiet(now)
+	}
+
+	return true
+}
+
+// Quiet returns true if now is within the user's quiet hours.
+func (p *Preferences) Quiet(now time.Time) bool {
+	if p.QuietHours == nil {
+		return false
+	}
+
+	// an empty timezone loads as UTC
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	start, err := time.Parse("15:04", p.QuietHours.Start)
+	if err != nil {
+		return false
+	}
+
+	end, err := time.Parse("15:04", p.QuietHours.End)
+	if err != nil {
+		return false
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	if from <= to {
+		return minute >= from && minute < to
+	}
+
+	return minute >= from || minute < to
+}
+
+// QuietUntil returns the end of the user's quiet hours that now is within,
+// or the zero time if now isn't within them.
+func (p *Preferences) QuietUntil(now time.Time) time.Time {
+	if !p.Quiet(now) {
+		return time.Time{}
+	}
+
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	// Quiet has parsed it
+	end, _ := time.Parse("15:04", p.QuietHours.End)
+
+	local := now.In(loc)
+	until := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+
+	return until
+}
+
+func hasChannel(channels []Channel, channel Channel) bool {
+	for _, c := range channels {
+		if c == channel {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validCategory(category Category) bool {
+	for _, c := range Categories {
+		if c == category {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validChannel(channel Channel) bool {
+	return hasChannel(Channels, channel)
+}
@@ -0,0 +1,98 @@
+package preferences
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultAllowsEverything(t *testing.T) {
+	prefs := Default()
+	now := time.Now()
+
+	for _, category := range Categories {
+		for _, channel := range Channels {
+			assert.True(t, prefs.Allows(category, channel, now))
+		}
+	}
+}
+
+func TestAllowsCategories(t *testing.T) {
+	prefs := Default()
+	prefs.Categories[Billing] = []Channel{}
+	prefs.Categories[Alerts] = []Channel{Slack}
+	now := time.Now()
+
+	assert.False(t, prefs.Allows(Billing, Email, now))
+	assert.False(t, prefs.Allows(Alerts, Email, now))
+	assert.True(t, prefs.Allows(Alerts, Slack, now))
+	assert.True(t, prefs.Allows(TeamInvites, Email, now))
+}
+
+func TestQuietHours(t *testing.T) {
+	prefs := Default()
+	prefs.Timezone = "America/Los_Angeles"
+	prefs.QuietHours = &QuietHours{Start: "22:00", End: "07:00"}
+
+	loc, err := time.LoadLocation(prefs.Timezone)
+	assert.NoError(t, err)
+
+	night := time.Date(2016, 4, 7, 23, 30, 0, 0, loc)
+	morning := time.Date(2016, 4, 8, 6, 59, 0, 0, loc)
+	day := time.Date(2016, 4, 8, 7, 0, 0, 0, loc)
+
+	assert.True(t, prefs.Quiet(night))
+	assert.True(t, prefs.Quiet(morning.UTC()))
+	assert.False(t, prefs.Quiet(day))
+
+	// quiet hours hold back alerts and product news, but not billing
+	assert.False(t, prefs.Allows(Alerts, Email, night))
+	assert.False(t, prefs.Allows(Product, Email, night))
+	assert.True(t, prefs.Allows(Billing, Email, night))
+	assert.True(t, prefs.Allows(Alerts, Email, day))
+
+	// quiet hours that span midnight end the next morning
+	assert.True(t, prefs.QuietUntil(night).Equal(time.Date(2016, 4, 8, 7, 0, 0, 0, loc)))
+	assert.True(t, prefs.QuietUntil(morning.UTC()).Equal(day))
+	assert.True(t, prefs.QuietUntil(day).IsZero())
+
+	prefs.QuietHours = &QuietHours{Start: "12:00", End: "13:00"}
+	assert.True(t, prefs.Quiet(time.Date(2016, 4, 8, 12, 30, 0, 0, loc)))
+	assert.True(t, prefs.QuietUntil(time.Date(2016, 4, 8, 12, 30, 0, 0, loc)).Equal(time.Date(2016, 4, 8, 13, 0, 0, 0, loc)))
+	assert.False(t, prefs.Quiet(night))
+}
+
+func TestValidate(t *testing.T) {
+	prefs := Default()
+	assert.NoError(t, prefs.Validate())
+
+	prefs.Categories["spam"] = []Channel{Email}
+	assert.Error(t, prefs.Validate())
+
+	prefs = Default()
+	prefs.Categories[Alerts] = []Channel{"pager"}
+	assert.Error(t, prefs.Validate())
+
+	prefs = Default()
+	prefs.Timezone = "Mars/Olympus_Mons"
+	assert.Error(t, prefs.Validate())
+
+	prefs = Default()
+	prefs.QuietHours = &QuietHours{Start: "25:00", End: "07:00"}
+	assert.Error(t, prefs.Validate())
+}
+
+func TestJSON(t *testing.T) {
+	b := []byte(`{"timezone":"UTC","quiet_hours":{"start":"22:00","end":"07:00"},"categories":{"billing":[]}}`)
+
+	prefs := Default()
+	assert.NoError(t, json.Unmarshal(b, prefs))
+	assert.Equal(t, "22:00", prefs.QuietHours.Start)
+	assert.False(t, prefs.Allows(Billing, Email, time.Now()))
+
+	out, err := json.Marshal(prefs)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(b), string(out))
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/opsee/basic/schema"
+	log "github.com/opsee/logrus"
+	"github.com/opsee/vaper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	trustedKey
+	gatewayRequestKey
+)
+
+var (
+	errUnauthenticated  = grpc.Errorf(codes.Unauthenticated, "a valid token is required")
+	errPermissionDenied = grpc.Errorf(codes.PermissionDenied, "not authorized for this customer")
+)
+
+// NewPrincipalContext returns a context carrying the authenticated user.
+func NewPrincipalContext(ctx context.Context, user *schema.User) context.Context {
+	return context.WithValue(ctx, principalKey, user)
+}
+
+// PrincipalFromContext returns the authenticated user, if any.
+func PrincipalFromContext(ctx context.Context) (*schema.User, bool) {
+	user, ok := ctx.Value(principalKey).(*schema.User)
+	return user, ok && user != nil
+}
+
+// TrustedContext returns a context for calling the service in process, e.g.
+// from a sluice job or pracovnik, which is allowed to act for any customer.
+// It is never created from a request.
+func TrustedContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trustedKey, true)
+}
+
+func isTrusted(ctx context.Context) bool {
+	trusted, _ := ctx.Value(trustedKey).(bool)
+	return trusted
+}
+
+// AuthInterceptor verifies the vaper token in a request's authorization
+// metadata, "Bearer <token>", and adds the user it was issued to to the
+// context as the principal. Requests without a valid token are rejected
+// with codes.Unauthenticated.
+func AuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	user, err := authenticate(ctx)
+	if err != nil {
+		log.WithError(err).WithField("method", info.FullMethod).Warn("rejecting unauthenticated request")
+		return nil, errUnauthenticated
+	}
+
+	return handler(NewPrincipalContext(ctx, user), req)
+}
+
+// AuthStreamInterceptor is AuthInterceptor for streaming RPCs.
+func AuthStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	user, err := authenticate(ss.Context())
+	if err != nil {
+		log.WithError(err).WithField("method", info.FullMethod).Warn("rejecting unauthenticated request")
+		return errUnauthenticated
+	}
+
+	return handler(srv, &principalStream{ss, NewPrincipalContext(ss.Context(), user)})
+}
+
+// principalStream is a ServerStream whose context carries the principal.
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context) (*schema.User, error) {
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		return nil, errUnauthenticated
+	}
+
+	for _, v := range md["authorization"] {
+		if strings.HasPrefix(v, "Bearer ") {
+			return authenticateToken(v)
+		}
+	}
+
+	return nil, errUnauthenticated
+}
+
+// authenticateToken returns the user a "Bearer <token>" authorization value
+// was issued to.
+func authenticateToken(authorization string) (*schema.User, error) {
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, errUnauthenticated
+	}
+
+	tokenString := strings.TrimPrefix(authorization, "Bearer ")
+	if tokenString == "" {
+		return nil, errUnauthenticated
+	}
+
+	token, err := vaper.Unmarshal(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token's claims are named after the user's json fields.
+	b, err := json.Marshal(token)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &schema.User{}
+	if err := json.Unmarshal(b, user); err != nil {
+		return nil, err
+	}
+
+	if user.Id == 0 || user.CustomerId == "" {
+		return nil, errUnauthenticated
+	}
+
+	return user, nil
+}
+
+// authorize returns nil if the caller may act for customerId: trusted
+// callers, Opsee admins, and users of that customer.
+func authorize(ctx context.Context, customerId string) error {
+	if isTrusted(ctx) {
+		return nil
+	}
+
+	user, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return errUnauthenticated
+	}
+
+	if user.IsOpseeAdmin() {
+		return nil
+	}
+
+	if customerId == "" || user.CustomerId != customerId {
+		log.WithFields(log.Fields{
+			"user_id":     user.Id,
+			"customer_id": customerId,
+		}).Warn("denying request for another customer")
+		return errPermissionDenied
+	}
+
+	return nil
+}
+
+// authorizeAdmin returns nil if the caller is trusted or an Opsee admin.
+func authorizeAdmin(ctx context.Context) error {
+	if isTrusted(ctx) {
+		return nil
+	}
+
+	user, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return errUnauthenticated
+	}
+
+	if !user.IsOpseeAdmin() {
+		return errPermissionDenied
+	}
+
+	return nil
+}
+
+// authorizeUser returns nil if the caller is trusted, an Opsee admin, or the
+// user userId.
+func authorizeUser(ctx context.Context, userId int32) error {
+	if isTrusted(ctx) {
+		return nil
+	}
+
+	user, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return errUnauthenticated
+	}
+
+	if user.IsOpseeAdmin() || user.Id == userId {
+		return nil
+	}
+
+	return errPermissionDenied
+}
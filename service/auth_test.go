@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/vaper"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func tokenContext(t *testing.T, user *schema.User, exp time.Duration) context.Context {
+	vaper.Init([]byte("0123456789abcdef"))
+
+	token, err := vaper.New(user, user.Email, time.Now().Add(-time.Second), time.Now().Add(exp)).Marshal()
+	assert.NoError(t, err)
+
+	return metadata.NewContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func intercept(ctx context.Context) (*schema.User, error) {
+	var principal *schema.User
+	_, err := AuthInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		principal, _ = PrincipalFromContext(ctx)
+		return nil, nil
+	})
+
+	return principal, err
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	user := &schema.User{
+		Id:         7,
+		CustomerId: "11111111-1111-1111-1111-111111111111",
+		Email:      "cliff@leaninto.it",
+		Active:     true,
+		Status:     "active",
+	}
+
+	principal, err := intercept(tokenContext(t, user, time.Hour))
+	assert.NoError(t, err)
+	if assert.NotNil(t, principal) {
+		assert.Equal(t, user.Id, principal.Id)
+		assert.Equal(t, user.CustomerId, principal.CustomerId)
+		assert.Equal(t, user.Email, principal.Email)
+	}
+
+	_, err = intercept(context.Background())
+	assert.Equal(t, codes.Unauthenticated, grpc.Code(err))
+
+	_, err = intercept(tokenContext(t, user, -time.Minute))
+	assert.Equal(t, codes.Unauthenticated, grpc.Code(err))
+
+	bogus := metadata.NewContext(context.Background(), metadata.Pairs("authorization", "Bearer nope"))
+	_, err = intercept(bogus)
+	assert.Equal(t, codes.Unauthenticated, grpc.Code(err))
+}
+
+func TestAuthorize(t *testing.T) {
+	customerId := "11111111-1111-1111-1111-111111111111"
+	user := NewPrincipalContext(context.Background(), &schema.User{Id: 7, CustomerId: customerId})
+	admin := NewPrincipalContext(context.Background(), &schema.User{Id: 1, CustomerId: "opsee", Admin: true})
+	trusted := TrustedContext(context.Background())
+
+	assert.NoError(t, authorize(user, customerId))
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(authorize(user, "22222222-2222-2222-2222-222222222222")))
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(authorize(user, "")))
+	assert.NoError(t, authorize(admin, customerId))
+	assert.NoError(t, authorize(trusted, customerId))
+	assert.Equal(t, codes.Unauthenticated, grpc.Code(authorize(context.Background(), customerId)))
+
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(authorizeAdmin(user)))
+	assert.NoError(t, authorizeAdmin(admin))
+
+	assert.NoError(t, authorizeUser(user, 7))
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(authorizeUser(user, 8)))
+	assert.NoError(t, authorizeUser(admin, 8))
+
+	s := &service{}
+	_, err := s.ListNotificationDeliveries(user, &api.ListNotificationDeliveriesRequest{
+		CustomerId: "22222222-2222-2222-2222-222222222222",
+		CheckId:    "check",
+	})
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+}
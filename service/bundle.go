@@ -0,0 +1,210 @@
+package service
+
+import (
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/checks/bundle"
+	"github.com/opsee/cats/store"
+	log "github.com/opsee/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// ExportChecks returns a customer's checks as a bundle document.
+func (s *service) ExportChecks(ctx context.Context, req *api.ExportChecksRequest) (*api.ExportChecksResponse, error) {
+	if req.CustomerId == "" {
+		log.Error("missing customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	checks, err := s.checkStore.GetChecks(&schema.User{CustomerId: req.CustomerId})
+	if err != nil {
+		log.WithError(err).Error("Error getting checks from db.")
+		return nil, err
+	}
+
+	doc, err := bundle.Export(checks).Marshal(req.Format)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	return &api.ExportChecksResponse{Document: doc}, nil
+}
+
+// ImportChecks reconciles a customer's checks with a bundle document. The
+// whole bundle is validated before anything is changed, then its changes are
+// made in one transaction.
+func (s *service) ImportChecks(ctx context.Context, req *api.ImportChecksRequest) (*api.ImportChecksResponse, error) {
+	if req.CustomerId == "" {
+		log.Error("missing customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	b, err := bundle.Unmarshal(req.Document)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid bundle: %s", err)
+	}
+
+	existing, err := s.checkStore.GetChecks(&schema.User{CustomerId: req.CustomerId})
+	if err != nil {
+		log.WithError(err).Error("Error getting checks from db.")
+		return nil, err
+	}
+
+	plan, err := bundle.NewPlan(b, existing)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	changes, err := s.validatedChanges(req.CustomerId, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.DryRun {
+		err := s.checkStore.WithTX(func(checkStore store.CheckStore, notificationStore store.NotificationStore) error {
+			return writeChanges(ctx, checkStore, notificationStore, req.CustomerId, changes)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &api.ImportChecksResponse{Changes: changes.applied(), Unchanged: int32(plan.Unchanged), DryRun: req.DryRun}, nil
+}
+
+// checkChanges are the checks a plan creates, updates and deletes.
+type checkChanges struct {
+	creates []*schema.Check
+	updates []*schema.Check
+	deletes []*bundle.Check
+}
+
+// validatedChanges returns a plan's changes, if every check it writes is
+// valid and every check it creates with an id can be given that id.
+func (s *service) validatedChanges(customerId string, plan *bundle.Plan) (*checkChanges, error) {
+	changes := &checkChanges{
+		creates: make([]*schema.Check, len(plan.Create)),
+		updates: make([]*schema.Check, len(plan.Update)),
+		deletes: plan.Delete,
+	}
+
+	var ids []string
+	for i, c := range plan.Create {
+		changes.creates[i] = c.Check(customerId)
+		if c.Id != "" {
+			ids = append(ids, c.Id)
+		}
+	}
+
+	for i, c := range plan.Update {
+		changes.updates[i] = c.Check(customerId)
+	}
+
+	for _, check := range append(changes.creates, changes.updates...) {
+		if err := store.ValidateCheck(check); err != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, "check %q: %s", check.Name, err)
+		}
+	}
+
+	// the ids of deleted checks, and other customers' checks, aren't reused
+	taken, err := s.checkStore.GetTakenCheckIds(ids)
+	if err != nil {
+		log.WithError(err).Error("Error getting check ids from db.")
+		return nil, err
+	}
+
+	if len(taken) > 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "check id %s is already taken, remove it to create a new check", taken[0])
+	}
+
+	return changes, nil
+}
+
+// writeChanges makes changes with stores in a transaction.
+func writeChanges(ctx context.Context, checkStore store.CheckStore, notificationStore store.NotificationStore, customerId string, changes *checkChanges) error {
+	user := notificationUser(ctx, customerId)
+
+	for _, check := range changes.creates {
+		if err := checkStore.CreateCheck(check); err != nil {
+			log.WithError(err).Errorf("Error creating check: %s", check.Name)
+			return err
+		}
+
+		if err := notificationStore.PutCheckNotifications(user, check.Id, check.Notifications); err != nil {
+			log.WithError(err).Errorf("Error putting check notifications: %s", check.Id)
+			return err
+		}
+	}
+
+	for _, check := range changes.updates {
+		if err := checkStore.UpdateCheck(check); err != nil {
+			log.WithError(err).Errorf("Error updating check: %s", check.Id)
+			return err
+		}
+
+		if err := notificationStore.PutCheckNotifications(user, check.Id, check.Notifications); err != nil {
+			log.WithError(err).Errorf("Error putting check notifications: %s", check.Id)
+			return err
+		}
+	}
+
+	for _, c := range changes.deletes {
+		if err := checkStore.DeleteCheck(customerId, c.Id); err != nil {
+			log.WithError(err).Errorf("Error deleting check: %s", c.Id)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notificationUser is the user that notifications written for a request are
+// attributed to: the requesting user, in the customer written for.
+func notificationUser(ctx context.Context, customerId string) *schema.User {
+	user := &schema.User{CustomerId: customerId}
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		user.Id = principal.Id
+	}
+
+	return user
+}
+
+// checkIds returns the ids of the checks that are created and updated.
+func (c *checkChanges) checkIds() []string {
+	ids := make([]string, 0, len(c.creates)+len(c.updates))
+	for _, check := range append(c.creates, c.updates...) {
+		ids = append(ids, check.Id)
+	}
+
+	return ids
+}
+
+// applied returns the changes as they are reported, with the ids created
+// checks were given once they have been written.
+func (c *checkChanges) applied() []*api.CheckChange {
+	var applied []*api.CheckChange
+
+	for _, check := range c.creates {
+		applied = append(applied, &api.CheckChange{Action: "create", CheckId: check.Id, Name: check.Name})
+	}
+
+	for _, check := range c.updates {
+		applied = append(applied, &api.CheckChange{Action: "update", CheckId: check.Id, Name: check.Name})
+	}
+
+	for _, check := range c.deletes {
+		applied = append(applied, &api.CheckChange{Action: "delete", CheckId: check.Id, Name: check.Name})
+	}
+
+	return applied
+}
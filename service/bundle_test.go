@@ -0,0 +1,89 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/api"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type testBundleCheckStore struct {
+	testCheckStore
+	checks []*schema.Check
+}
+
+func (q *testBundleCheckStore) GetChecks(user *schema.User) ([]*schema.Check, error) {
+	return q.checks, nil
+}
+
+func (q *testBundleCheckStore) GetTakenCheckIds(ids []string) ([]string, error) {
+	taken := []string{}
+	for _, id := range ids {
+		if id == "deleted-id" {
+			taken = append(taken, id)
+		}
+	}
+
+	return taken, nil
+}
+
+func TestImportChecksDryRun(t *testing.T) {
+	customerId := "11111111-1111-1111-1111-111111111111"
+	cs := &testBundleCheckStore{checks: []*schema.Check{
+		{
+			Id:               "check-id",
+			CustomerId:       customerId,
+			ExecutionGroupId: customerId,
+			Name:             "web",
+			Interval:         30,
+			MinFailingCount:  1,
+			MinFailingTime:   90,
+			Target:           &schema.Target{Type: "sg", Id: "sg-123456"},
+			Spec:             &schema.Check_HttpCheck{HttpCheck: &schema.HttpCheck{Protocol: "http", Verb: "GET", Port: 80, Path: "/"}},
+		},
+	}}
+	s := &service{checkStore: cs}
+	ctx := NewPrincipalContext(context.Background(), &schema.User{Id: 7, CustomerId: customerId})
+
+	exported, err := s.ExportChecks(ctx, &api.ExportChecksRequest{CustomerId: customerId})
+	assert.NoError(t, err)
+
+	resp, err := s.ImportChecks(ctx, &api.ImportChecksRequest{CustomerId: customerId, Document: exported.Document, DryRun: true})
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Changes)
+	assert.Equal(t, int32(1), resp.Unchanged)
+
+	doc := []byte(`
+version: 1
+checks:
+- name: api
+  execution_group_id: 11111111-1111-1111-1111-111111111111
+  min_failing_count: 1
+  min_failing_time: 90
+  target: {type: elb, id: api-elb}
+  http: {protocol: https, verb: GET, port: 443, path: /health}
+`)
+	resp, err = s.ImportChecks(ctx, &api.ImportChecksRequest{CustomerId: customerId, Document: doc, DryRun: true})
+	assert.NoError(t, err)
+	assert.True(t, resp.DryRun)
+	assert.Equal(t, []*api.CheckChange{
+		{Action: "create", Name: "api"},
+		{Action: "delete", CheckId: "check-id", Name: "web"},
+	}, resp.Changes)
+
+	// nothing is changed if any check is invalid
+	_, err = s.ImportChecks(ctx, &api.ImportChecksRequest{CustomerId: customerId, Document: []byte("version: 1\nchecks:\n- name: api\n")})
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+
+	// a deleted check's id isn't reused
+	_, err = s.ImportChecks(ctx, &api.ImportChecksRequest{CustomerId: customerId, Document: []byte(strings.Replace(string(doc), "- name: api", "- id: deleted-id\n  name: api", 1))})
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+
+	_, err = s.ImportChecks(ctx, &api.ImportChecksRequest{CustomerId: "22222222-2222-2222-2222-222222222222", Document: doc})
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+}
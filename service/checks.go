@@ -1,15 +1,20 @@
 package service
 
 import (
+	"database/sql"
 	"fmt"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/opsee/basic/schema"
 	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/store"
 	log "github.com/opsee/logrus"
 	opsee_types "github.com/opsee/protobuf/opseeproto/types"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 func (s *service) GetChecks(ctx context.Context, req *opsee.GetChecksRequest) (*opsee.GetChecksResponse, error) {
@@ -18,7 +23,7 @@ func (s *service) GetChecks(ctx context.Context, req *opsee.GetChecksRequest) (*
 
 	if req.Requestor == nil {
 		log.Error("no user in request")
-		return nil, fmt.Errorf("user is required")
+		return nil, grpc.Errorf(codes.InvalidArgument, "user is required")
 	}
 
 	if err := req.Requestor.Validate(); err != nil {
@@ -26,6 +31,10 @@ func (s *service) GetChecks(ctx context.Context, req *opsee.GetChecksRequest) (*
 		return nil, err
 	}
 
+	if err := authorize(ctx, req.Requestor.CustomerId); err != nil {
+		return nil, err
+	}
+
 	agent.AddAttribute("user_email", req.Requestor.Email)
 
 	if req.CheckId != "" {
@@ -52,10 +61,187 @@ func (s *service) GetChecks(ctx context.Context, req *opsee.GetChecksRequest) (*
 	return &opsee.GetChecksResponse{checks}, nil
 }
 
+// ListChecks returns a page of a customer's checks, filtered and sorted in
+// the database.
+func (s *service) ListChecks(ctx context.Context, req *api.ListChecksRequest) (*api.ListChecksResponse, error) {
+	if req.CustomerId == "" {
+		log.Error("missing customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	query := &store.CheckListQuery{
+		State:            req.State,
+		TargetType:       req.TargetType,
+		TargetId:         req.TargetId,
+		Name:             req.Name,
+		ExecutionGroupId: req.ExecutionGroupId,
+		MinFailingCount:  req.MinFailingCount,
+		MaxFailingCount:  req.MaxFailingCount,
+		SortBy:           req.SortBy,
+		Descending:       req.Descending,
+		Cursor:           req.Cursor,
+		Limit:            int(req.Limit),
+		Selector:         req.Selector,
+	}
+
+	if err := query.Validate(); err != nil {
+		log.WithError(err).Error("Invalid check list query.")
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	list, err := s.checkStore.ListChecks(req.CustomerId, query)
+	if err != nil {
+		log.WithError(err).Error("Error listing checks from db.")
+		return nil, err
+	}
+
+	return &api.ListChecksResponse{
+		Checks:     list.Checks,
+		Total:      int32(list.Total),
+		NextCursor: list.NextCursor,
+		Labels:     listedCheckLabels(list.Labels),
+	}, nil
+}
+
+// CreateCheck validates and creates a customer's check and its
+// notifications.
+func (s *service) CreateCheck(ctx context.Context, req *api.CreateCheckRequest) (*api.CheckResponse, error) {
+	check, err := s.checkForWrite(ctx, req.CustomerId, req.Check)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkStore.WithTX(func(checkStore store.CheckStore, notificationStore store.NotificationStore) error {
+		if err := checkStore.CreateCheck(check); err != nil {
+			log.WithError(err).Error("Error creating check in db.")
+			return err
+		}
+
+		if err := notificationStore.PutCheckNotifications(notificationUser(ctx, check.CustomerId), check.Id, check.Notifications); err != nil {
+			log.WithError(err).Errorf("Error putting check notifications in db: %s", check.Id)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.writtenCheck(check)
+}
+
+// UpdateCheck validates and replaces a customer's check and its
+// notifications.
+func (s *service) UpdateCheck(ctx context.Context, req *api.UpdateCheckRequest) (*api.CheckResponse, error) {
+	check, err := s.checkForWrite(ctx, req.CustomerId, req.Check)
+	if err != nil {
+		return nil, err
+	}
+
+	if check.Id == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "check id is required")
+	}
+
+	err = s.checkStore.WithTX(func(checkStore store.CheckStore, notificationStore store.NotificationStore) error {
+		if err := checkStore.UpdateCheck(check); err != nil {
+			if err == sql.ErrNoRows {
+				return grpc.Errorf(codes.NotFound, "no such check")
+			}
+
+			log.WithError(err).Errorf("Error updating check in db: %s", check.Id)
+			return err
+		}
+
+		if err := notificationStore.PutCheckNotifications(notificationUser(ctx, check.CustomerId), check.Id, check.Notifications); err != nil {
+			log.WithError(err).Errorf("Error putting check notifications in db: %s", check.Id)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.writtenCheck(check)
+}
+
+// DeleteCheck deletes a customer's check, or every check matching a
+// selector.
+func (s *service) DeleteCheck(ctx context.Context, req *api.DeleteCheckRequest) (*api.DeleteCheckResponse, error) {
+	if req.CustomerId == "" {
+		log.Error("missing customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	checkIds, err := s.selectedCheckIds(req.CustomerId, req.CheckId, req.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, checkId := range checkIds {
+		if err := s.checkStore.DeleteCheck(req.CustomerId, checkId); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, grpc.Errorf(codes.NotFound, "no such check")
+			}
+
+			log.WithError(err).Errorf("Error deleting check in db: %s", checkId)
+			return nil, err
+		}
+	}
+
+	return &api.DeleteCheckResponse{CheckIds: checkIds}, nil
+}
+
+// checkForWrite authorizes a check write for customerId and validates the
+// check, which is given the customer's id.
+func (s *service) checkForWrite(ctx context.Context, customerId string, check *schema.Check) (*schema.Check, error) {
+	if customerId == "" {
+		log.Error("missing customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, customerId); err != nil {
+		return nil, err
+	}
+
+	if check == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "check is required")
+	}
+
+	check.CustomerId = customerId
+	if err := store.ValidateCheck(check); err != nil {
+		log.WithError(err).Error("Invalid check.")
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	return check, nil
+}
+
+// writtenCheck reads back a check that was just written, with its state.
+func (s *service) writtenCheck(check *schema.Check) (*api.CheckResponse, error) {
+	written, err := s.checkStore.GetCheck(&schema.User{CustomerId: check.CustomerId}, check.Id)
+	if err != nil {
+		log.WithError(err).Errorf("Error getting written check from db: %s", check.Id)
+		return nil, err
+	}
+
+	return &api.CheckResponse{Check: written}, nil
+}
+
 func (s *service) GetCheckCount(ctx context.Context, req *opsee.GetCheckCountRequest) (*opsee.GetCheckCountResponse, error) {
 	if req.User == nil {
 		log.Error("no user in request")
-		return nil, fmt.Errorf("user is required")
+		return nil, grpc.Errorf(codes.InvalidArgument, "user is required")
 	}
 
 	if err := req.User.Validate(); err != nil {
@@ -63,6 +249,10 @@ func (s *service) GetCheckCount(ctx context.Context, req *opsee.GetCheckCountReq
 		return nil, err
 	}
 
+	if err := authorize(ctx, req.User.CustomerId); err != nil {
+		return nil, err
+	}
+
 	count, err := s.checkStore.GetCheckCount(req.User.CustomerId)
 	if err != nil {
 		log.WithError(err).Error("Error getting check count from check store.")
@@ -74,16 +264,40 @@ func (s *service) GetCheckCount(ctx context.Context, req *opsee.GetCheckCountReq
 	}, nil
 }
 
-func (s *service) GetCheckResults(ctx context.Context, req *opsee.GetCheckResultsRequest) (response *opsee.GetCheckResultsResponse, err error) {
-	agent := s.newrelicAgent.StartTransaction("GetChecks", nil, nil)
+// GetCheckResults returns a check's latest result from each of its live
+// bastions. It fails if any bastion's result can't be got, see
+// ListCheckResults for partial results.
+func (s *service) GetCheckResults(ctx context.Context, req *opsee.GetCheckResultsRequest) (*opsee.GetCheckResultsResponse, error) {
+	resp, err := s.ListCheckResults(ctx, &api.ListCheckResultsRequest{
+		CustomerId: req.CustomerId,
+		CheckId:    req.CheckId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &opsee.GetCheckResultsResponse{Results: resp.Results}, nil
+}
+
+// ListCheckResults returns a check's latest result from each of its live
+// bastions, in the order of the bastions. Results are got concurrently, and
+// unless the request is partial, any bastion's error fails the request with
+// every bastion's error: DeadlineExceeded if they all timed out, and
+// otherwise Unavailable.
+func (s *service) ListCheckResults(ctx context.Context, req *api.ListCheckResultsRequest) (*api.ListCheckResultsResponse, error) {
+	agent := s.newrelicAgent.StartTransaction("ListCheckResults", nil, nil)
 	defer agent.End()
 
 	if req.CustomerId == "" {
-		return nil, fmt.Errorf("Request missing CustomerID")
+		return nil, grpc.Errorf(codes.InvalidArgument, "Request missing CustomerID")
 	}
 
 	if req.CheckId == "" {
-		return nil, fmt.Errorf("Request missing CheckID")
+		return nil, grpc.Errorf(codes.InvalidArgument, "Request missing CheckID")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
 	}
 
 	logger := log.WithFields(log.Fields{
@@ -98,44 +312,108 @@ func (s *service) GetCheckResults(ctx context.Context, req *opsee.GetCheckResult
 		return nil, err
 	}
 
+	if req.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
 	defer agent.EndSegment(agent.StartSegment(), "resultStore.GetResultByCheckId")
-	results := make([]*schema.CheckResult, len(bastions))
-	wg := &sync.WaitGroup{}
+	results, errs := s.bastionResults(ctx, bastions, req.CheckId)
+
+	resp := &api.ListCheckResultsResponse{}
+	for i, bastionId := range bastions {
+		if errs[i] != nil {
+			logger.WithField("bastion_id", bastionId).WithError(errs[i]).Error("Error getting result from result store.")
+			resp.Errors = append(resp.Errors, &api.BastionError{BastionId: bastionId, Error: errs[i].Error()})
+			continue
+		}
+
+		resp.Results = append(resp.Results, results[i])
+	}
+
+	if len(resp.Errors) > 0 && !req.Partial {
+		code := codes.DeadlineExceeded
+		for _, err := range errs {
+			if err != nil && err != context.DeadlineExceeded {
+				code = codes.Unavailable
+			}
+		}
+
+		return nil, grpc.Errorf(code, "%s", bastionErrors(resp.Errors))
+	}
+
+	return resp, nil
+}
+
+// bastionResults gets a check's result from each bastion concurrently. A
+// bastion whose result hasn't been got when ctx is done has its error. The
+// result store doesn't take a context, so its calls still run to completion
+// in the background after that, and their results are dropped.
+func (s *service) bastionResults(ctx context.Context, bastions []string, checkId string) ([]*schema.CheckResult, []error) {
+	type bastionResult struct {
+		idx    int
+		result *schema.CheckResult
+		err    error
+	}
+
+	var (
+		results = make([]*schema.CheckResult, len(bastions))
+		errs    = make([]error, len(bastions))
+		done    = make([]bool, len(bastions))
+		// buffered so that results arriving after ctx is done don't block
+		ch = make(chan bastionResult, len(bastions))
+	)
+
 	for i, b := range bastions {
-		wg.Add(1)
 		go func(bastionId string, idx int) {
-			defer wg.Done()
-			var result *schema.CheckResult
-			result, err = s.resultStore.GetResultByCheckId(bastionId, req.CheckId)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"fn":         "GetCheckResults",
-					"check_id":   req.CheckId,
-					"bastion_id": bastionId,
-				}).WithError(err).Error("Error getting result from result store.")
-				return
-			}
-			results[idx] = result
+			result, err := s.resultStore.GetResultByCheckId(bastionId, checkId)
+			ch <- bastionResult{idx: idx, result: result, err: err}
 		}(b, i)
 	}
-	wg.Wait()
-	// We can't return partial results, so we return an error if there was any error.
-	if err != nil {
-		logger.WithError(err).Error("Error getting check result for bastions.")
-		return nil, err
+
+	for range bastions {
+		select {
+		case r := <-ch:
+			results[r.idx], errs[r.idx], done[r.idx] = r.result, r.err, true
+		case <-ctx.Done():
+			for i := range bastions {
+				if !done[i] {
+					errs[i] = ctx.Err()
+				}
+			}
+			return results, errs
+		}
 	}
-	return &opsee.GetCheckResultsResponse{Results: results}, nil
+
+	return results, errs
+}
+
+// bastionErrors are the errors getting a check's results from its bastions.
+type bastionErrors []*api.BastionError
+
+func (e bastionErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, be := range e {
+		msgs[i] = fmt.Sprintf("%s: %s", be.BastionId, be.Error)
+	}
+
+	return fmt.Sprintf("error getting check results from %d bastions: %s", len(e), strings.Join(msgs, "; "))
 }
 
 func (s *service) GetCheckStateTransitions(ctx context.Context, req *opsee.GetCheckStateTransitionsRequest) (response *opsee.GetCheckStateTransitionsResponse, err error) {
 	if req.CustomerId == "" {
 		log.Error("Request missing CheckID")
-		return nil, fmt.Errorf("Request missing CustomerID")
+		return nil, grpc.Errorf(codes.InvalidArgument, "Request missing CustomerID")
 	}
 
 	if req.CheckId == "" {
 		log.Error("Request missing CheckID")
-		return nil, fmt.Errorf("Request missing CheckID")
+		return nil, grpc.Errorf(codes.InvalidArgument, "Request missing CheckID")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
 	}
 
 	logger := log.WithFields(log.Fields{
@@ -170,38 +448,38 @@ func (s *service) GetCheckStateTransitions(ctx context.Context, req *opsee.GetCh
 	}
 
 	if req.AbsoluteStartTime == nil {
-		err := fmt.Errorf("Request missing AbsoluteStartTime")
+		err := grpc.Errorf(codes.InvalidArgument, "Request missing AbsoluteStartTime")
 		logger.WithError(err).Error("Invalid request.")
 		return nil, err
 	}
 
 	if req.AbsoluteEndTime == nil {
-		err := fmt.Errorf("Request missing AbsoluteEndTime")
+		err := grpc.Errorf(codes.InvalidArgument, "Request missing AbsoluteEndTime")
 		logger.WithError(err).Error("Invalid request.")
 		return nil, err
 	}
 
 	st, err := req.AbsoluteStartTime.Value()
 	if err != nil {
-		err := fmt.Errorf("Invalid AbsoluteStartTime")
+		err := grpc.Errorf(codes.InvalidArgument, "Invalid AbsoluteStartTime")
 		log.WithError(err).Error("Invalid request.")
 		return nil, err
 	}
 	et, err := req.AbsoluteEndTime.Value()
 	if err != nil {
-		err := fmt.Errorf("Invalid AbsoluteEndTime")
+		err := grpc.Errorf(codes.InvalidArgument, "Invalid AbsoluteEndTime")
 		logger.WithError(err).Error("Invalid request.")
 		return nil, err
 	}
 	ast, aok := st.(time.Time)
 	if !aok {
-		err := fmt.Errorf("invalid AbsoluteStartTime")
+		err := grpc.Errorf(codes.InvalidArgument, "invalid AbsoluteStartTime")
 		logger.WithError(err).Error("Invalid request.")
 		return nil, err
 	}
 	aet, eok := et.(time.Time)
 	if !eok {
-		err := fmt.Errorf("invalid AbsoluteEndTime")
+		err := grpc.Errorf(codes.InvalidArgument, "invalid AbsoluteEndTime")
 		logger.WithError(err).Error("Invalid request.")
 		return nil, err
 	}
@@ -238,7 +516,7 @@ func (s *service) GetCheckSnapshot(ctx context.Context, req *opsee.GetCheckSnaps
 	user := req.Requestor
 	if user == nil {
 		log.Error("Request requires a user.")
-		return nil, fmt.Errorf("Request requires a user.")
+		return nil, grpc.Errorf(codes.InvalidArgument, "Request requires a user.")
 	}
 
 	if err := user.Validate(); err != nil {
@@ -252,6 +530,12 @@ func (s *service) GetCheckSnapshot(ctx context.Context, req *opsee.GetCheckSnaps
 		return nil, fmt.Errorf("Error getting check snapshot.")
 	}
 
+	// snapshots are looked up by check id alone, so the snapshot's customer
+	// is the one to authorize rather than the requestor's
+	if err := authorize(ctx, ss.CustomerId); err != nil {
+		return nil, err
+	}
+
 	resp := &opsee.GetCheckSnapshotResponse{
 		Check: ss,
 	}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+
+	newrelic "github.com/newrelic/go-agent"
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/checks/labels"
+	"github.com/opsee/cats/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type testWriteCheckStore struct {
+	testCheckStore
+	written       *schema.Check
+	notifications testNotificationStore
+}
+
+func (q *testWriteCheckStore) CreateCheck(check *schema.Check) error {
+	check.Id = "new-check-id"
+	q.written = check
+	return nil
+}
+
+func (q *testWriteCheckStore) UpdateCheck(check *schema.Check) error {
+	return sql.ErrNoRows
+}
+
+func (q *testWriteCheckStore) GetCheck(user *schema.User, checkId string) (*schema.Check, error) {
+	return q.written, nil
+}
+
+func (q *testWriteCheckStore) WithTX(txfun func(store.CheckStore, store.NotificationStore) error) error {
+	return txfun(q, &q.notifications)
+}
+
+type testNotificationStore struct {
+	store.NotificationStore
+	notifications map[string][]*schema.Notification
+}
+
+func (q *testNotificationStore) PutCheckNotifications(user *schema.User, checkId string, notifications []*schema.Notification) error {
+	if q.notifications == nil {
+		q.notifications = make(map[string][]*schema.Notification)
+	}
+	q.notifications[checkId] = notifications
+	return nil
+}
+
+func TestCheckWrites(t *testing.T) {
+	customerId := "11111111-1111-1111-1111-111111111111"
+	cs := &testWriteCheckStore{}
+	s := &service{checkStore: cs}
+	ctx := NewPrincipalContext(context.Background(), &schema.User{Id: 7, CustomerId: customerId})
+
+	check := &schema.Check{
+		Name:             "check",
+		ExecutionGroupId: customerId,
+		Target:           &schema.Target{Type: "sg", Id: "sg-123456"},
+		Spec:             &schema.Check_HttpCheck{HttpCheck: &schema.HttpCheck{Path: "/", Protocol: "http", Port: 80, Verb: "GET"}},
+		Notifications:    []*schema.Notification{{Type: "email", Value: "dan@opsee.co"}},
+	}
+
+	_, err := s.CreateCheck(ctx, &api.CreateCheckRequest{CustomerId: "22222222-2222-2222-2222-222222222222", Check: check})
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+
+	_, err = s.CreateCheck(ctx, &api.CreateCheckRequest{CustomerId: customerId, Check: &schema.Check{Name: "check"}})
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+
+	resp, err := s.CreateCheck(ctx, &api.CreateCheckRequest{CustomerId: customerId, Check: check})
+	assert.NoError(t, err)
+	assert.Equal(t, "new-check-id", resp.Check.Id)
+	assert.Equal(t, customerId, resp.Check.CustomerId)
+	assert.Equal(t, check.Notifications, cs.notifications.notifications["new-check-id"])
+
+	_, err = s.UpdateCheck(ctx, &api.UpdateCheckRequest{CustomerId: customerId, Check: check})
+	assert.Equal(t, codes.NotFound, grpc.Code(err))
+
+	_, err = s.DeleteCheck(ctx, &api.DeleteCheckRequest{CustomerId: customerId})
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+}
+
+type testResultsCheckStore struct {
+	testCheckStore
+}
+
+func (q *testResultsCheckStore) GetLiveBastions(customerId, checkId string) ([]string, error) {
+	return []string{"bastion-1", "bastion-2", "bastion-3", "bastion-4"}, nil
+}
+
+type testTimeoutCheckStore struct {
+	testCheckStore
+}
+
+func (q *testTimeoutCheckStore) GetLiveBastions(customerId, checkId string) ([]string, error) {
+	return []string{"bastion-1", "bastion-4"}, nil
+}
+
+// testResultStore has a result for bastion-1 and bastion-3, and blocks
+// getting bastion-4's until released.
+type testResultStore struct {
+	release chan struct{}
+}
+
+func (s *testResultStore) GetResultByCheckId(bastionId, checkId string) (*schema.CheckResult, error) {
+	switch bastionId {
+	case "bastion-1", "bastion-3":
+		return &schema.CheckResult{BastionId: bastionId, CheckId: checkId}, nil
+	case "bastion-4":
+		<-s.release
+	}
+
+	return nil, errors.New("NoSuchKey")
+}
+
+func (s *testResultStore) PutResult(result *schema.CheckResult) error { return nil }
+func (s *testResultStore) GetCheckSnapshot(transitionId int64, checkId string) (*schema.Check, error) {
+	return nil, nil
+}
+func (s *testResultStore) PutCheckSnapshot(transitionId int64, check *schema.Check) error {
+	return nil
+}
+func (s *testResultStore) GetCheckSnapshotLabels(transitionId int64, checkId string) (labels.Labels, error) {
+	return nil, nil
+}
+func (s *testResultStore) PutCheckSnapshotLabels(transitionId int64, checkId string, l labels.Labels) error {
+	return nil
+}
+
+// testNewrelicApp records nothing. The vendored agent can't be made without
+// a New Relic beta token.
+type testNewrelicApp struct {
+	newrelic.Application
+}
+
+func (testNewrelicApp) StartTransaction(name string, w http.ResponseWriter, r *http.Request) newrelic.Transaction {
+	return testNewrelicTransaction{}
+}
+
+type testNewrelicTransaction struct {
+	newrelic.Transaction
+}
+
+func (testNewrelicTransaction) End() error                                   { return nil }
+func (testNewrelicTransaction) StartSegment() newrelic.Token                 { return 0 }
+func (testNewrelicTransaction) EndSegment(token newrelic.Token, name string) {}
+
+func TestListCheckResults(t *testing.T) {
+	customerId := "11111111-1111-1111-1111-111111111111"
+
+	rs := &testResultStore{release: make(chan struct{})}
+
+	s := &service{checkStore: &testResultsCheckStore{}, resultStore: rs, newrelicAgent: testNewrelicApp{}}
+	ctx := NewPrincipalContext(context.Background(), &schema.User{Id: 7, CustomerId: customerId})
+
+	resp, err := s.ListCheckResults(ctx, &api.ListCheckResultsRequest{CustomerId: customerId, CheckId: "check-id", Partial: true, TimeoutMs: 50})
+	assert.NoError(t, err)
+	assert.Equal(t, []*schema.CheckResult{
+		{BastionId: "bastion-1", CheckId: "check-id"},
+		{BastionId: "bastion-3", CheckId: "check-id"},
+	}, resp.Results)
+	assert.Equal(t, []*api.BastionError{
+		{BastionId: "bastion-2", Error: "NoSuchKey"},
+		{BastionId: "bastion-4", Error: context.DeadlineExceeded.Error()},
+	}, resp.Errors)
+
+	// without partial, every bastion's error fails the request
+	close(rs.release)
+	_, err = s.GetCheckResults(ctx, &opsee.GetCheckResultsRequest{CustomerId: customerId, CheckId: "check-id"})
+	assert.Equal(t, codes.Unavailable, grpc.Code(err))
+	assert.Equal(t, "error getting check results from 2 bastions: bastion-2: NoSuchKey; bastion-4: NoSuchKey", grpc.ErrorDesc(err))
+
+	// timing out on every bastion that fails is a deadline
+	s.checkStore = &testTimeoutCheckStore{}
+	rs.release = make(chan struct{})
+	defer close(rs.release)
+	_, err = s.ListCheckResults(ctx, &api.ListCheckResultsRequest{CustomerId: customerId, CheckId: "check-id", TimeoutMs: 50})
+	assert.Equal(t, codes.DeadlineExceeded, grpc.Code(err))
+}
@@ -0,0 +1,684 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/basic/tp"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/servicer"
+	log "github.com/opsee/logrus"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// gatewayRoute is a JSON-over-HTTP route for a Cats RPC. Requests are
+// authenticated with the same vaper bearer tokens as gRPC requests, and the
+// RPC does its own validation and authorization.
+type gatewayRoute struct {
+	method  string
+	path    string
+	summary string
+	// query is the query parameters the route reads, for the OpenAPI
+	// document. Every route also reads customer_id, see requestor.
+	query []string
+	// body and response are zero values of the request body and response
+	// types, for the OpenAPI document.
+	body     interface{}
+	response interface{}
+	// status is the HTTP status of a successful response.
+	status int
+	// request builds the RPC request.
+	request func(*gatewayRequest) (interface{}, error)
+	// call makes the RPC.
+	call func(context.Context, interface{}) (interface{}, error)
+}
+
+// gatewayRequest is an authenticated HTTP request to a gateway route.
+type gatewayRequest struct {
+	user   *schema.User
+	params httprouter.Params
+	query  url.Values
+	body   io.Reader
+}
+
+// requestor returns the user the request is made as. Opsee admins may act
+// for another customer by setting customer_id.
+func (r *gatewayRequest) requestor() *schema.User {
+	user := *r.user
+	if customerId := r.query.Get("customer_id"); customerId != "" {
+		user.CustomerId = customerId
+	}
+
+	return &user
+}
+
+func (r *gatewayRequest) customerId() string {
+	return r.requestor().CustomerId
+}
+
+func (r *gatewayRequest) decode(v interface{}) error {
+	if err := json.NewDecoder(r.body).Decode(v); err != nil && err != io.EOF {
+		return grpc.Errorf(codes.InvalidArgument, "malformed request body: %s", err)
+	}
+
+	return nil
+}
+
+func (r *gatewayRequest) int64Param(name string) (int64, error) {
+	i, err := strconv.ParseInt(r.params.ByName(name), 10, 64)
+	if err != nil {
+		return 0, grpc.Errorf(codes.InvalidArgument, "invalid %s: %s", name, r.params.ByName(name))
+	}
+
+	return i, nil
+}
+
+func (r *gatewayRequest) int32Param(name string) (int32, error) {
+	i, err := strconv.ParseInt(r.params.ByName(name), 10, 32)
+	if err != nil {
+		return 0, grpc.Errorf(codes.InvalidArgument, "invalid %s: %s", name, r.params.ByName(name))
+	}
+
+	return int32(i), nil
+}
+
+func (r *gatewayRequest) int32Query(name string) (int32, error) {
+	v := r.query.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return 0, grpc.Errorf(codes.InvalidArgument, "invalid %s: %s", name, v)
+	}
+
+	return int32(i), nil
+}
+
+func (r *gatewayRequest) timeQuery(name string) (*opsee_types.Timestamp, error) {
+	v := r.query.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid %s, expected an RFC 3339 time: %s", name, v)
+	}
+
+	ts := &opsee_types.Timestamp{}
+	if err := ts.Scan(t); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+func (s *service) gatewayRoutes() []*gatewayRoute {
+	return []*gatewayRoute{
+		{
+			method:   "GET",
+			path:     "/checks",
+			summary:  "List checks.",
+			response: opsee.GetChecksResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				return &opsee.GetChecksRequest{Requestor: r.requestor()}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.GetChecks(ctx, req.(*opsee.GetChecksRequest))
+			},
+		},
+		{
+			method:   "GET",
+			path:     "/checks/:check_id",
+			summary:  "Get a check.",
+			response: opsee.GetChecksResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				return &opsee.GetChecksRequest{
+					Requestor: r.requestor(),
+					CheckId:   r.params.ByName("check_id"),
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.GetChecks(ctx, req.(*opsee.GetChecksRequest))
+			},
+		},
+		{
+			method:   "POST",
+			path:     "/checks",
+			summary:  "Create a check.",
+			body:     schema.Check{},
+			response: api.CheckResponse{},
+			status:   http.StatusCreated,
+			request: func(r *gatewayRequest) (interface{}, error) {
+				check := &schema.Check{}
+				if err := r.decode(check); err != nil {
+					return nil, err
+				}
+
+				return &api.CreateCheckRequest{CustomerId: r.customerId(), Check: check}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.CreateCheck(ctx, req.(*api.CreateCheckRequest))
+			},
+		},
+		{
+			method:   "PUT",
+			path:     "/checks/:check_id",
+			summary:  "Replace a check and its notifications. Changing its failing thresholds resets its state.",
+			body:     schema.Check{},
+			response: api.CheckResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				check := &schema.Check{}
+				if err := r.decode(check); err != nil {
+					return nil, err
+				}
+				check.Id = r.params.ByName("check_id")
+
+				return &api.UpdateCheckRequest{CustomerId: r.customerId(), Check: check}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.UpdateCheck(ctx, req.(*api.UpdateCheckRequest))
+			},
+		},
+		{
+			method:   "DELETE",
+			path:     "/checks/:check_id",
+			summary:  "Delete a check.",
+			response: api.DeleteCheckResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				return &api.DeleteCheckRequest{
+					CustomerId: r.customerId(),
+					CheckId:    r.params.ByName("check_id"),
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.DeleteCheck(ctx, req.(*api.DeleteCheckRequest))
+			},
+		},
+		{
+			method:   "PATCH",
+			path:     "/checks/:check_id/labels",
+			summary:  "Set and remove a check's labels.",
+			body:     api.UpdateCheckLabelsRequest{},
+			response: api.UpdateCheckLabelsResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				req := &api.UpdateCheckLabelsRequest{}
+				if err := r.decode(req); err != nil {
+					return nil, err
+				}
+				req.CustomerId = r.customerId()
+				req.CheckId = r.params.ByName("check_id")
+				req.Selector = ""
+
+				return req, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.UpdateCheckLabels(ctx, req.(*api.UpdateCheckLabelsRequest))
+			},
+		},
+		{
+			method:   "GET",
+			path:     "/templates",
+			summary:  "List check templates.",
+			response: api.ListCheckTemplatesResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				return &api.ListCheckTemplatesRequest{CustomerId: r.customerId()}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.ListCheckTemplates(ctx, req.(*api.ListCheckTemplatesRequest))
+			},
+		},
+		{
+			method:   "POST",
+			path:     "/templates",
+			summary:  "Create a check template and a check for each target it selects.",
+			body:     api.PutCheckTemplateRequest{},
+			response: api.PutCheckTemplateResponse{},
+			status:   http.StatusCreated,
+			request: func(r *gatewayRequest) (interface{}, error) {
+				req := &api.PutCheckTemplateRequest{}
+				if err := r.decode(req); err != nil {
+					return nil, err
+				}
+				req.CustomerId = r.customerId()
+				if req.Template != nil {
+					req.Template.Id = ""
+				}
+
+				return req, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.PutCheckTemplate(ctx, req.(*api.PutCheckTemplateRequest))
+			},
+		},
+		{
+			method:   "PUT",
+			path:     "/templates/:template_id",
+			summary:  "Replace a check template and update the checks made from it, e.g. to re-sync them with new targets, or with prune, to delete those of targets that are gone.",
+			body:     api.PutCheckTemplateRequest{},
+			response: api.PutCheckTemplateResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				req := &api.PutCheckTemplateRequest{}
+				if err := r.decode(req); err != nil {
+					return nil, err
+				}
+				req.CustomerId = r.customerId()
+				if req.Template == nil {
+					req.Template = &api.CheckTemplate{}
+				}
+				req.Template.Id = r.params.ByName("template_id")
+
+				return req, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.PutCheckTemplate(ctx, req.(*api.PutCheckTemplateRequest))
+			},
+		},
+		{
+			method:   "DELETE",
+			path:     "/templates/:template_id",
+			summary:  "Delete a check template and, unless keep_checks is true, the checks made from it.",
+			query:    []string{"keep_checks"},
+			response: api.DeleteCheckTemplateResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				return &api.DeleteCheckTemplateRequest{
+					CustomerId: r.customerId(),
+					TemplateId: r.params.ByName("template_id"),
+					KeepChecks: r.query.Get("keep_checks") == "true",
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.DeleteCheckTemplate(ctx, req.(*api.DeleteCheckTemplateRequest))
+			},
+		},
+		{
+			method:   "GET",
+			path:     "/checks/:check_id/results",
+			summary:  "Get a check's latest results. With partial=true, bastions whose results can't be got are listed in errors instead of failing the request.",
+			query:    []string{"partial", "timeout_ms"},
+			response: api.ListCheckResultsResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				timeout, err := r.int32Query("timeout_ms")
+				if err != nil {
+					return nil, err
+				}
+
+				return &api.ListCheckResultsRequest{
+					CustomerId: r.customerId(),
+					CheckId:    r.params.ByName("check_id"),
+					Partial:    r.query.Get("partial") == "true",
+					TimeoutMs:  timeout,
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.ListCheckResults(ctx, req.(*api.ListCheckResultsRequest))
+			},
+		},
+		{
+			method:   "GET",
+			path:     "/checks/:check_id/transitions",
+			summary:  "List a check's state transitions between start and end, RFC 3339 times, or get the one transition_id.",
+			query:    []string{"start", "end", "transition_id"},
+			response: opsee.GetCheckStateTransitionsResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				start, err := r.timeQuery("start")
+				if err != nil {
+					return nil, err
+				}
+
+				end, err := r.timeQuery("end")
+				if err != nil {
+					return nil, err
+				}
+
+				var transitionId int64
+				if v := r.query.Get("transition_id"); v != "" {
+					transitionId, err = strconv.ParseInt(v, 10, 64)
+					if err != nil {
+						return nil, grpc.Errorf(codes.InvalidArgument, "invalid transition_id: %s", v)
+					}
+				}
+
+				return &opsee.GetCheckStateTransitionsRequest{
+					CustomerId:        r.customerId(),
+					CheckId:           r.params.ByName("check_id"),
+					AbsoluteStartTime: start,
+					AbsoluteEndTime:   end,
+					StateTransitionId: transitionId,
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.GetCheckStateTransitions(ctx, req.(*opsee.GetCheckStateTransitionsRequest))
+			},
+		},
+		{
+			method:   "GET",
+			path:     "/checks/:check_id/snapshots/:transition_id",
+			summary:  "Get the check snapshot taken at a state transition.",
+			response: opsee.GetCheckSnapshotResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				transitionId, err := r.int64Param("transition_id")
+				if err != nil {
+					return nil, err
+				}
+
+				return &opsee.GetCheckSnapshotRequest{
+					Requestor:    r.requestor(),
+					CheckId:      r.params.ByName("check_id"),
+					TransitionId: transitionId,
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.GetCheckSnapshot(ctx, req.(*opsee.GetCheckSnapshotRequest))
+			},
+		},
+		{
+			method:   "GET",
+			path:     "/check_count",
+			summary:  "Count checks.",
+			response: opsee.GetCheckCountResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				return &opsee.GetCheckCountRequest{User: r.requestor()}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.GetCheckCount(ctx, req.(*opsee.GetCheckCountRequest))
+			},
+		},
+		{
+			method:   "POST",
+			path:     "/teams",
+			summary:  "Create a team. Opsee admins only, unless the team exists.",
+			body:     opsee.CreateTeamRequest{},
+			response: opsee.CreateTeamResponse{},
+			status:   http.StatusCreated,
+			request: func(r *gatewayRequest) (interface{}, error) {
+				req := &opsee.CreateTeamRequest{}
+				if err := r.decode(req); err != nil {
+					return nil, err
+				}
+				req.Requestor = r.requestor()
+
+				return req, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.CreateTeam(ctx, req.(*opsee.CreateTeamRequest))
+			},
+		},
+		{
+			method:   "GET",
+			path:     "/teams/:team_id",
+			summary:  "Get a team.",
+			response: opsee.GetTeamResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				return &opsee.GetTeamRequest{
+					Requestor: r.requestor(),
+					Team:      &schema.Team{Id: r.params.ByName("team_id")},
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.GetTeam(ctx, req.(*opsee.GetTeamRequest))
+			},
+		},
+		{
+			method:   "PUT",
+			path:     "/teams/:team_id",
+			summary:  "Update a team.",
+			body:     opsee.UpdateTeamRequest{},
+			response: opsee.UpdateTeamResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				req := &opsee.UpdateTeamRequest{}
+				if err := r.decode(req); err != nil {
+					return nil, err
+				}
+				if req.Team == nil {
+					req.Team = &schema.Team{}
+				}
+				req.Requestor = r.requestor()
+				req.Team.Id = r.params.ByName("team_id")
+
+				return req, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.UpdateTeam(ctx, req.(*opsee.UpdateTeamRequest))
+			},
+		},
+		{
+			method:   "DELETE",
+			path:     "/teams/:team_id",
+			summary:  "Delete a team.",
+			response: opsee.DeleteTeamResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				return &opsee.DeleteTeamRequest{
+					Requestor: r.requestor(),
+					Team:      &schema.Team{Id: r.params.ByName("team_id")},
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.DeleteTeam(ctx, req.(*opsee.DeleteTeamRequest))
+			},
+		},
+		{
+			method:   "GET",
+			path:     "/users",
+			summary:  "List users. Opsee admins only.",
+			query:    []string{"page", "per_page"},
+			response: opsee.ListUsersResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				page, err := r.int32Query("page")
+				if err != nil {
+					return nil, err
+				}
+
+				perPage, err := r.int32Query("per_page")
+				if err != nil {
+					return nil, err
+				}
+
+				return &opsee.ListUsersRequest{
+					Requestor: r.requestor(),
+					Page:      page,
+					PerPage:   perPage,
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.ListUsers(ctx, req.(*opsee.ListUsersRequest))
+			},
+		},
+		{
+			method:   "POST",
+			path:     "/users",
+			summary:  "Invite a user to the requestor's team.",
+			body:     opsee.InviteUserRequest{},
+			response: opsee.InviteUserResponse{},
+			status:   http.StatusCreated,
+			request: func(r *gatewayRequest) (interface{}, error) {
+				req := &opsee.InviteUserRequest{}
+				if err := r.decode(req); err != nil {
+					return nil, err
+				}
+				req.Requestor = r.requestor()
+
+				return req, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.InviteUser(ctx, req.(*opsee.InviteUserRequest))
+			},
+		},
+		{
+			method:   "GET",
+			path:     "/users/:user_id",
+			summary:  "Get a user.",
+			response: opsee.GetUserResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				id, err := r.int32Param("user_id")
+				if err != nil {
+					return nil, err
+				}
+
+				return &opsee.GetUserRequest{
+					Requestor: r.requestor(),
+					Id:        id,
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.GetUser(ctx, req.(*opsee.GetUserRequest))
+			},
+		},
+		{
+			method:   "PUT",
+			path:     "/users/:user_id",
+			summary:  "Update a user's email, name, password, status or permissions.",
+			body:     opsee.UpdateUserRequest{},
+			response: opsee.UserTokenResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				id, err := r.int32Param("user_id")
+				if err != nil {
+					return nil, err
+				}
+
+				req := &opsee.UpdateUserRequest{}
+				if err := r.decode(req); err != nil {
+					return nil, err
+				}
+				req.Requestor = r.requestor()
+				req.User = &schema.User{Id: id}
+
+				return req, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.UpdateUser(ctx, req.(*opsee.UpdateUserRequest))
+			},
+		},
+		{
+			method:   "DELETE",
+			path:     "/users/:user_id",
+			summary:  "Delete a user.",
+			response: opsee.DeleteUserResponse{},
+			request: func(r *gatewayRequest) (interface{}, error) {
+				id, err := r.int32Param("user_id")
+				if err != nil {
+					return nil, err
+				}
+
+				return &opsee.DeleteUserRequest{
+					Requestor: r.requestor(),
+					User:      &schema.User{Id: id},
+				}, nil
+			},
+			call: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.DeleteUser(ctx, req.(*opsee.DeleteUserRequest))
+			},
+		},
+	}
+}
+
+// registerGateway adds the gateway routes, and the OpenAPI document
+// describing them at /openapi.json, to router.
+func (s *service) registerGateway(router *tp.Router) {
+	routes := s.gatewayRoutes()
+	for _, route := range routes {
+		router.Handle(route.method, route.path, []tp.DecodeFunc{s.httpLogger(), s.gatewayDecoder(route)}, s.gatewayHandler(route))
+	}
+
+	document := newOpenAPIDocument(routes)
+	router.Handle("GET", "/openapi.json", []tp.DecodeFunc{s.httpLogger()}, func(ctx context.Context) (interface{}, int, error) {
+		return document, http.StatusOK, nil
+	})
+}
+
+// gatewayDecoder authenticates the request and builds the route's RPC
+// request from it.
+func (s *service) gatewayDecoder(route *gatewayRoute) tp.DecodeFunc {
+	return func(ctx context.Context, rw http.ResponseWriter, r *http.Request, p httprouter.Params) (context.Context, int, error) {
+		user, err := authenticateToken(r.Header.Get("Authorization"))
+		if err != nil {
+			log.WithError(err).WithField("path", r.URL.Path).Warn("rejecting unauthenticated request")
+			return ctx, http.StatusUnauthorized, gatewayError(errUnauthenticated)
+		}
+
+		req, err := route.request(&gatewayRequest{
+			user:   user,
+			params: p,
+			query:  r.URL.Query(),
+			body:   r.Body,
+		})
+		if err != nil {
+			return ctx, httpStatus(err), gatewayError(err)
+		}
+
+		ctx = NewPrincipalContext(ctx, user)
+		return context.WithValue(ctx, gatewayRequestKey, req), 0, nil
+	}
+}
+
+func (s *service) gatewayHandler(route *gatewayRoute) tp.HandleFunc {
+	return func(ctx context.Context) (interface{}, int, error) {
+		response, err := route.call(ctx, ctx.Value(gatewayRequestKey))
+		if err != nil {
+			return nil, httpStatus(err), gatewayError(err)
+		}
+
+		status := route.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		return response, status, nil
+	}
+}
+
+// gatewayError returns err without its gRPC code, as the message of the
+// JSON error body. The tp router replaces the message of internal errors.
+func gatewayError(err error) error {
+	return errors.New(grpc.ErrorDesc(err))
+}
+
+// httpStatus maps an RPC error to an HTTP status.
+func httpStatus(err error) int {
+	switch err {
+	case servicer.UserNotFound, servicer.TeamNotFound:
+		return http.StatusNotFound
+	}
+
+	if e, ok := err.(*opsee_types.Error); ok && e.ErrorCode == "PermissionsError" {
+		return http.StatusForbidden
+	}
+
+	switch grpc.Code(err) {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/basic/tp"
+	"github.com/opsee/cats/servicer"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func gatewayGet(t *testing.T, h http.Handler, path, authorization string) (int, []byte) {
+	req, err := http.NewRequest("GET", "https://cats"+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w.Code, w.Body.Bytes()
+}
+
+func TestGateway(t *testing.T) {
+	user := &schema.User{
+		Id:         7,
+		CustomerId: "11111111-1111-1111-1111-111111111111",
+		Email:      "cliff@leaninto.it",
+		Name:       "cliff",
+		Active:     true,
+		Verified:   true,
+		Status:     "active",
+	}
+
+	md, _ := metadata.FromContext(tokenContext(t, user, time.Hour))
+	authorization := md["authorization"][0]
+
+	s := &service{checkStore: &testCheckStore{}}
+	h := s.NewHandler()
+
+	status, body := gatewayGet(t, h, "/check_count", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	msg := &tp.MessageResponse{}
+	assert.NoError(t, json.Unmarshal(body, msg))
+	assert.Equal(t, "a valid token is required", msg.Message)
+
+	status, body = gatewayGet(t, h, "/check_count", authorization)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"count": 2}`, string(body))
+
+	status, body = gatewayGet(t, h, "/check_count?customer_id=22222222-2222-2222-2222-222222222222", authorization)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.NoError(t, json.Unmarshal(body, msg))
+	assert.Equal(t, "not authorized for this customer", msg.Message)
+
+	status, _ = gatewayGet(t, h, "/checks/check-id/transitions?start=yesterday", authorization)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// user ids are int32, so one that overflows is rejected rather than wrapped
+	status, _ = gatewayGet(t, h, "/users/4294967297", authorization)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, body = gatewayGet(t, h, "/openapi.json", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	doc := struct {
+		Paths       map[string]map[string]interface{} `json:"paths"`
+		Definitions map[string]interface{}            `json:"definitions"`
+	}{}
+	assert.NoError(t, json.Unmarshal(body, &doc))
+	assert.Contains(t, doc.Paths, "/checks/{check_id}/snapshots/{transition_id}")
+	assert.Contains(t, doc.Paths["/users/{user_id}"], "put")
+	assert.Contains(t, doc.Paths["/users/{user_id}"], "delete")
+	assert.Contains(t, doc.Definitions, "service.GetChecksResponse")
+	assert.Contains(t, doc.Definitions, "schema.Team")
+}
+
+func TestHTTPStatus(t *testing.T) {
+	for err, status := range map[error]int{
+		grpc.Errorf(codes.InvalidArgument, "bad"):        http.StatusBadRequest,
+		grpc.Errorf(codes.NotFound, "missing"):           http.StatusNotFound,
+		grpc.Errorf(codes.AlreadyExists, "exists"):       http.StatusConflict,
+		grpc.Errorf(codes.ResourceExhausted, "slow"):     http.StatusTooManyRequests,
+		errUnauthenticated:                               http.StatusUnauthorized,
+		errPermissionDenied:                              http.StatusForbidden,
+		servicer.UserNotFound:                            http.StatusNotFound,
+		opsee_types.NewPermissionsError("same team"):     http.StatusForbidden,
+		errors.New("pq: connection refused"):             http.StatusInternalServerError,
+		grpc.Errorf(codes.Unknown, "something happened"): http.StatusInternalServerError,
+	} {
+		assert.Equal(t, status, httpStatus(err), err.Error())
+	}
+}
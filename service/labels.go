@@ -0,0 +1,198 @@
+package service
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/checks/labels"
+	"github.com/opsee/cats/store"
+	log "github.com/opsee/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// notificationRouteTypes are the notification types a route may send to.
+var notificationRouteTypes = map[string]bool{
+	"email":         true,
+	"pagerduty":     true,
+	"slack_bot":     true,
+	"slack_webhook": true,
+	"webhook":       true,
+}
+
+// UpdateCheckLabels sets and removes labels on a check, or on every check
+// matching a selector.
+func (s *service) UpdateCheckLabels(ctx context.Context, req *api.UpdateCheckLabelsRequest) (*api.UpdateCheckLabelsResponse, error) {
+	if req.CustomerId == "" {
+		log.Error("missing customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	set := labels.Labels(req.Set)
+	if err := set.Validate(); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	for _, k := range req.Remove {
+		if err := labels.ValidateKey(k); err != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+		}
+	}
+
+	checkIds, err := s.selectedCheckIds(req.CustomerId, req.CheckId, req.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkStore.UpdateCheckLabels(req.CustomerId, checkIds, set, req.Remove); err != nil {
+		log.WithError(err).Error("Error updating check labels in db.")
+		return nil, err
+	}
+
+	byCheck, err := s.checkStore.GetCheckLabels(req.CustomerId, checkIds)
+	if err != nil {
+		log.WithError(err).Error("Error getting check labels from db.")
+		return nil, err
+	}
+
+	resp := &api.UpdateCheckLabelsResponse{}
+	for _, checkId := range checkIds {
+		resp.Labels = append(resp.Labels, &api.CheckLabels{CheckId: checkId, Labels: byCheck[checkId]})
+	}
+
+	return resp, nil
+}
+
+// selectedCheckIds returns checkId if it is set and is one of the customer's
+// checks, or else the ids of the customer's checks matching selector. One of
+// them is required.
+func (s *service) selectedCheckIds(customerId, checkId, selector string) ([]string, error) {
+	if checkId != "" && selector != "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "only one of check_id and selector may be set")
+	}
+
+	if checkId != "" {
+		if _, err := s.checkStore.GetCheck(&schema.User{CustomerId: customerId}, checkId); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, grpc.Errorf(codes.NotFound, "no such check")
+			}
+
+			log.WithError(err).Errorf("Error getting check from db: %s", checkId)
+			return nil, err
+		}
+
+		return []string{checkId}, nil
+	}
+
+	if selector == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "check_id or selector is required")
+	}
+
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	checkIds, err := s.checkStore.GetCheckIds(customerId, sel)
+	if err != nil {
+		log.WithError(err).Error("Error selecting checks from db.")
+		return nil, err
+	}
+
+	return checkIds, nil
+}
+
+// listedCheckLabels returns the labels of a page of listed checks.
+func listedCheckLabels(byCheck map[string]labels.Labels) []*api.CheckLabels {
+	checkIds := make([]string, 0, len(byCheck))
+	for checkId := range byCheck {
+		checkIds = append(checkIds, checkId)
+	}
+	sort.Strings(checkIds)
+
+	checkLabels := make([]*api.CheckLabels, len(checkIds))
+	for i, checkId := range checkIds {
+		checkLabels[i] = &api.CheckLabels{CheckId: checkId, Labels: byCheck[checkId]}
+	}
+
+	return checkLabels
+}
+
+// NotificationRoutes lists a customer's notification routes, after creating,
+// updating or deleting one.
+func (s *service) NotificationRoutes(ctx context.Context, req *api.NotificationRoutesRequest) (*api.NotificationRoutesResponse, error) {
+	if req.CustomerId == "" {
+		log.Error("missing customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	if req.Put != nil {
+		// an empty selector matches every check, which is never what a
+		// route is for
+		if strings.TrimSpace(req.Put.Selector) == "" {
+			return nil, grpc.Errorf(codes.InvalidArgument, "notification route selector is required")
+		}
+
+		if _, err := labels.Parse(req.Put.Selector); err != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+		}
+
+		if !notificationRouteTypes[req.Put.Type] || req.Put.Value == "" {
+			return nil, grpc.Errorf(codes.InvalidArgument, "invalid notification route: type %q, value %q", req.Put.Type, req.Put.Value)
+		}
+
+		route := &store.NotificationRoute{
+			Id:         req.Put.Id,
+			CustomerId: req.CustomerId,
+			Selector:   req.Put.Selector,
+			Type:       req.Put.Type,
+			Value:      req.Put.Value,
+		}
+
+		if err := s.notificationStore.PutNotificationRoute(route); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, grpc.Errorf(codes.NotFound, "no such notification route")
+			}
+
+			log.WithError(err).Error("Error putting notification route in db.")
+			return nil, err
+		}
+	}
+
+	if req.DeleteId != 0 {
+		if err := s.notificationStore.DeleteNotificationRoute(req.CustomerId, req.DeleteId); err != nil {
+			log.WithError(err).Error("Error deleting notification route in db.")
+			return nil, err
+		}
+	}
+
+	routes, err := s.notificationStore.GetNotificationRoutes(req.CustomerId)
+	if err != nil {
+		log.WithError(err).Error("Error getting notification routes from db.")
+		return nil, err
+	}
+
+	resp := &api.NotificationRoutesResponse{}
+	for _, r := range routes {
+		resp.Routes = append(resp.Routes, &api.NotificationRoute{
+			Id:       r.Id,
+			Selector: r.Selector,
+			Type:     r.Type,
+			Value:    r.Value,
+		})
+	}
+
+	return resp, nil
+}
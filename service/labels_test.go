@@ -0,0 +1,109 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/checks/labels"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type testLabelCheckStore struct {
+	testCheckStore
+	labels map[string]labels.Labels
+}
+
+func (q *testLabelCheckStore) GetCheck(user *schema.User, checkId string) (*schema.Check, error) {
+	if _, ok := q.labels[checkId]; !ok || user.CustomerId != "11111111-1111-1111-1111-111111111111" {
+		return nil, sql.ErrNoRows
+	}
+
+	return &schema.Check{Id: checkId, CustomerId: user.CustomerId}, nil
+}
+
+func (q *testLabelCheckStore) GetCheckIds(customerId string, selector labels.Selector) ([]string, error) {
+	var ids []string
+	for _, id := range []string{"check-1", "check-2"} {
+		if selector.Matches(q.labels[id]) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (q *testLabelCheckStore) UpdateCheckLabels(customerId string, checkIds []string, set labels.Labels, remove []string) error {
+	for _, id := range checkIds {
+		for k, v := range set {
+			q.labels[id][k] = v
+		}
+		for _, k := range remove {
+			delete(q.labels[id], k)
+		}
+	}
+
+	return nil
+}
+
+func (q *testLabelCheckStore) GetCheckLabels(customerId string, checkIds []string) (map[string]labels.Labels, error) {
+	return q.labels, nil
+}
+
+func TestUpdateCheckLabels(t *testing.T) {
+	customerId := "11111111-1111-1111-1111-111111111111"
+	cs := &testLabelCheckStore{labels: map[string]labels.Labels{
+		"check-1": {"env": "prod", "team": "payments"},
+		"check-2": {"env": "staging"},
+	}}
+	s := &service{checkStore: cs}
+	ctx := NewPrincipalContext(context.Background(), &schema.User{Id: 7, CustomerId: customerId})
+
+	resp, err := s.UpdateCheckLabels(ctx, &api.UpdateCheckLabelsRequest{
+		CustomerId: customerId,
+		Selector:   "env=prod",
+		Set:        map[string]string{"tier": "web"},
+		Remove:     []string{"team"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*api.CheckLabels{
+		{CheckId: "check-1", Labels: map[string]string{"env": "prod", "tier": "web"}},
+	}, resp.Labels)
+
+	for _, req := range []*api.UpdateCheckLabelsRequest{
+		{CustomerId: customerId},
+		{CustomerId: customerId, CheckId: "check-1", Selector: "env=prod"},
+		{CustomerId: customerId, Selector: "env=pr od"},
+		{CustomerId: customerId, CheckId: "check-1", Set: map[string]string{"Bad Key": "x"}},
+	} {
+		_, err = s.UpdateCheckLabels(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, grpc.Code(err), req.String())
+	}
+
+	// check ids must be the customer's checks
+	_, err = s.UpdateCheckLabels(ctx, &api.UpdateCheckLabelsRequest{CustomerId: customerId, CheckId: "check-3", Set: map[string]string{"tier": "web"}})
+	assert.Equal(t, codes.NotFound, grpc.Code(err))
+
+	_, err = s.UpdateCheckLabels(ctx, &api.UpdateCheckLabelsRequest{CustomerId: "22222222-2222-2222-2222-222222222222", CheckId: "check-1"})
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+}
+
+func TestNotificationRoutesValidation(t *testing.T) {
+	customerId := "11111111-1111-1111-1111-111111111111"
+	s := &service{}
+	ctx := NewPrincipalContext(context.Background(), &schema.User{Id: 7, CustomerId: customerId})
+
+	for _, route := range []*api.NotificationRoute{
+		{Type: "email", Value: "cliff@leaninto.it"},
+		{Selector: " ", Type: "email", Value: "cliff@leaninto.it"},
+		{Selector: "env=pr od", Type: "email", Value: "cliff@leaninto.it"},
+		{Selector: "env=prod", Type: "carrier-pigeon", Value: "coo"},
+	} {
+		_, err := s.NotificationRoutes(ctx, &api.NotificationRoutesRequest{CustomerId: customerId, Put: route})
+		assert.Equal(t, codes.InvalidArgument, grpc.Code(err), route.String())
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"github.com/opsee/cats/api"
+	log "github.com/opsee/logrus"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *service) ListNotificationDeliveries(ctx context.Context, req *api.ListNotificationDeliveriesRequest) (*api.ListNotificationDeliveriesResponse, error) {
+	if req.CustomerId == "" || req.CheckId == "" {
+		log.Error("missing customer_id or check_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id and check_id are required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	deliveries, err := s.notificationStore.GetDeliveries(req.CustomerId, req.CheckId, req.Type, limit)
+	if err != nil {
+		log.WithError(err).Error("Error getting notification deliveries from db.")
+		return nil, err
+	}
+
+	resp := &api.ListNotificationDeliveriesResponse{
+		Deliveries: make([]*api.NotificationDelivery, len(deliveries)),
+	}
+
+	for i, d := range deliveries {
+		delivery := &api.NotificationDelivery{
+			Id:            d.Id,
+			TransitionId:  d.TransitionId,
+			CheckId:       d.CheckId,
+			Type:          d.Type,
+			Value:         d.Value,
+			Status:        d.Status,
+			Attempts:      int32(d.Attempts),
+			LastError:     d.LastError,
+			CreatedAt:     &opsee_types.Timestamp{},
+			UpdatedAt:     &opsee_types.Timestamp{},
+			NextAttemptAt: &opsee_types.Timestamp{},
+		}
+		delivery.CreatedAt.Scan(d.CreatedAt)
+		delivery.UpdatedAt.Scan(d.UpdatedAt)
+		delivery.NextAttemptAt.Scan(d.NextAttemptAt)
+
+		resp.Deliveries[i] = delivery
+	}
+
+	return resp, nil
+}
+
+func (s *service) GetWebhookSecret(ctx context.Context, req *api.GetWebhookSecretRequest) (*api.GetWebhookSecretResponse, error) {
+	if req.CustomerId == "" {
+		log.Error("no customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	var (
+		secret string
+		err    error
+	)
+	if req.Rotate {
+		secret, err = s.notificationStore.RotateWebhookSecret(req.CustomerId)
+	} else {
+		secret, err = s.notificationStore.GetWebhookSecret(req.CustomerId)
+	}
+
+	if err != nil {
+		log.WithError(err).Error("Error getting webhook secret from db.")
+		return nil, err
+	}
+
+	return &api.GetWebhookSecretResponse{
+		Secret: secret,
+	}, nil
+}
+
+func (s *service) CriticalChecks(ctx context.Context, req *api.CriticalChecksRequest) (*api.CriticalChecksResponse, error) {
+	if req.CustomerId == "" {
+		log.Error("no customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	if req.CheckId != "" {
+		if err := s.governorStore.PutCriticalCheck(req.CustomerId, req.CheckId, req.Critical); err != nil {
+			log.WithError(err).Error("Error updating critical checks in db.")
+			return nil, err
+		}
+	}
+
+	checkIds, err := s.governorStore.GetCriticalChecks(req.CustomerId)
+	if err != nil {
+		log.WithError(err).Error("Error getting critical checks from db.")
+		return nil, err
+	}
+
+	return &api.CriticalChecksResponse{
+		CheckIds: checkIds,
+	}, nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opsee/basic/tp"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timestampType     = reflect.TypeOf(opsee_types.Timestamp{})
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// newOpenAPIDocument returns an OpenAPI (Swagger 2.0) document describing
+// the gateway routes. Request and response schemas are derived from the
+// messages' json struct tags, which is how the gateway encodes them.
+func newOpenAPIDocument(routes []*gatewayRoute) map[string]interface{} {
+	definitions := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+
+	errorSchema := schemaOf(reflect.TypeOf(tp.MessageResponse{}), definitions)
+
+	for _, route := range routes {
+		var (
+			parameters []interface{}
+			segments   = strings.Split(route.path, "/")
+		)
+
+		for i, segment := range segments {
+			if !strings.HasPrefix(segment, ":") {
+				continue
+			}
+
+			name := strings.TrimPrefix(segment, ":")
+			segments[i] = "{" + name + "}"
+			parameters = append(parameters, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"type":     "string",
+			})
+		}
+
+		for _, name := range append(route.query, "customer_id") {
+			parameters = append(parameters, map[string]interface{}{
+				"name": name,
+				"in":   "query",
+				"type": "string",
+			})
+		}
+
+		if route.body != nil {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     "body",
+				"in":       "body",
+				"required": true,
+				"schema":   schemaOf(reflect.TypeOf(route.body), definitions),
+			})
+		}
+
+		status := route.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		p := strings.Join(segments, "/")
+		if _, ok := paths[p]; !ok {
+			paths[p] = make(map[string]interface{})
+		}
+
+		paths[p][strings.ToLower(route.method)] = map[string]interface{}{
+			"summary":    route.summary,
+			"parameters": parameters,
+			"responses": map[string]interface{}{
+				strconv.Itoa(status): map[string]interface{}{
+					"description": http.StatusText(status),
+					"schema":      schemaOf(reflect.TypeOf(route.response), definitions),
+				},
+				"default": map[string]interface{}{
+					"description": "An error, with its HTTP status.",
+					"schema":      errorSchema,
+				},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"swagger": "2.0",
+		"info": map[string]interface{}{
+			"title":       "Cats",
+			"description": "JSON gateway for the Cats gRPC service. Requests are authenticated with an Opsee token, \"Authorization: Bearer <token>\".",
+			"version":     "1",
+		},
+		"consumes": []string{"application/json"},
+		"produces": []string{"application/json"},
+		"securityDefinitions": map[string]interface{}{
+			"token": map[string]interface{}{
+				"type": "apiKey",
+				"name": "Authorization",
+				"in":   "header",
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"token": []string{}},
+		},
+		"paths":       paths,
+		"definitions": definitions,
+	}
+}
+
+// schemaOf returns the JSON schema of t, adding named structs to
+// definitions and referring to them.
+func schemaOf(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timestampType || t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	// types with their own encoding can't be described by their fields
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), definitions)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), definitions)}
+	case reflect.Struct:
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		ref := map[string]interface{}{"$ref": "#/definitions/" + name}
+		if _, ok := definitions[name]; ok {
+			return ref
+		}
+
+		// added before its fields, which may refer to it
+		definition := map[string]interface{}{"type": "object"}
+		definitions[name] = definition
+		definition["properties"] = propertiesOf(t, definitions)
+
+		return ref
+	}
+
+	// interfaces, e.g. protobuf oneofs
+	return map[string]interface{}{}
+}
+
+func propertiesOf(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				for k, v := range propertiesOf(field.Type, definitions) {
+					properties[k] = v
+				}
+				continue
+			}
+			name = field.Name
+		}
+
+		properties[name] = schemaOf(field.Type, definitions)
+	}
+
+	return properties
+}
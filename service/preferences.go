@@ -0,0 +1,116 @@
+package service
+
+import (
+	"database/sql"
+
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/preferences"
+	log "github.com/opsee/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *service) GetNotificationPreferences(ctx context.Context, req *api.GetNotificationPreferencesRequest) (*api.NotificationPreferencesResponse, error) {
+	if req.UserId == 0 {
+		log.Error("no user_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "user_id is required")
+	}
+
+	if err := authorizeUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	prefs, err := s.preferenceStore.GetPreferences(int(req.UserId))
+	if err != nil {
+		log.WithError(err).Error("Error getting notification preferences from db.")
+		return nil, err
+	}
+
+	return &api.NotificationPreferencesResponse{
+		Preferences: toApiPreferences(prefs),
+	}, nil
+}
+
+func (s *service) UpdateNotificationPreferences(ctx context.Context, req *api.UpdateNotificationPreferencesRequest) (*api.NotificationPreferencesResponse, error) {
+	if req.UserId == 0 || req.Preferences == nil {
+		log.Error("missing user_id or preferences in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "user_id and preferences are required")
+	}
+
+	if err := authorizeUser(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	prefs := fromApiPreferences(req.Preferences)
+	if err := prefs.Validate(); err != nil {
+		log.WithError(err).Error("Invalid notification preferences.")
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	if err := s.preferenceStore.PutPreferences(int(req.UserId), prefs); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, grpc.Errorf(codes.NotFound, "no such user")
+		}
+
+		log.WithError(err).Error("Error updating notification preferences in db.")
+		return nil, err
+	}
+
+	return &api.NotificationPreferencesResponse{
+		Preferences: toApiPreferences(prefs),
+	}, nil
+}
+
+func toApiPreferences(prefs *preferences.Preferences) *api.NotificationPreferences {
+	out := &api.NotificationPreferences{
+		Timezone:   prefs.Timezone,
+		Categories: []*api.CategoryPreference{},
+	}
+
+	if prefs.QuietHours != nil {
+		out.QuietHoursStart = prefs.QuietHours.Start
+		out.QuietHoursEnd = prefs.QuietHours.End
+	}
+
+	// in a stable order
+	for _, category := range preferences.Categories {
+		channels, ok := prefs.Categories[category]
+		if !ok {
+			continue
+		}
+
+		cp := &api.CategoryPreference{
+			Category: string(category),
+			Channels: make([]string, len(channels)),
+		}
+		for i, c := range channels {
+			cp.Channels[i] = string(c)
+		}
+		out.Categories = append(out.Categories, cp)
+	}
+
+	return out
+}
+
+func fromApiPreferences(in *api.NotificationPreferences) *preferences.Preferences {
+	prefs := preferences.Default()
+	prefs.Timezone = in.Timezone
+
+	if in.QuietHoursStart != "" || in.QuietHoursEnd != "" {
+		prefs.QuietHours = &preferences.QuietHours{
+			Start: in.QuietHoursStart,
+			End:   in.QuietHoursEnd,
+		}
+	}
+
+	for _, cp := range in.Categories {
+		channels := make([]preferences.Channel, len(cp.Channels))
+		for i, c := range cp.Channels {
+			channels[i] = preferences.Channel(c)
+		}
+		prefs.Categories[preferences.Category(cp.Category)] = channels
+	}
+
+	return prefs
+}
@@ -3,13 +3,17 @@ package service
 import (
 	"crypto/tls"
 	"net/http"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
 	newrelic "github.com/newrelic/go-agent"
 	"github.com/opsee/basic/grpcutil"
 	opsee "github.com/opsee/basic/service"
 	"github.com/opsee/basic/tp"
+	"github.com/opsee/cats/api"
 	"github.com/opsee/cats/checks/results"
+	"github.com/opsee/cats/checks/watch"
 	"github.com/opsee/cats/store"
 	sluice "github.com/opsee/gmunch/client"
 	log "github.com/opsee/logrus"
@@ -18,12 +22,16 @@ import (
 )
 
 type service struct {
-	httpServer    *http.Server
-	checkStore    store.CheckStore
-	teamStore     store.TeamStore
-	resultStore   results.Store
-	sluiceClient  sluice.Client
-	newrelicAgent newrelic.Application
+	httpServer        *http.Server
+	checkStore        store.CheckStore
+	teamStore         store.TeamStore
+	notificationStore store.NotificationStore
+	governorStore     store.GovernorStore
+	preferenceStore   store.PreferenceStore
+	resultStore       results.Store
+	sluiceClient      sluice.Client
+	newrelicAgent     newrelic.Application
+	watchHub          *watch.Hub
 }
 
 func New(pgConn string, resultStore results.Store, newrelicAgent newrelic.Application) (*service, error) {
@@ -40,21 +48,43 @@ func New(pgConn string, resultStore results.Store, newrelicAgent newrelic.Applic
 	}
 
 	svc := &service{
-		checkStore:    store.NewCheckStore(db),
-		teamStore:     store.NewTeamStore(db),
-		resultStore:   resultStore,
-		sluiceClient:  sluiceClient,
-		newrelicAgent: newrelicAgent,
+		checkStore:        store.NewCheckStore(db),
+		teamStore:         store.NewTeamStore(db),
+		notificationStore: store.NewNotificationStore(db),
+		governorStore:     store.NewGovernorStore(db),
+		preferenceStore:   store.NewPreferenceStore(db),
+		resultStore:       resultStore,
+		sluiceClient:      sluiceClient,
+		newrelicAgent:     newrelicAgent,
 	}
 
 	return svc, nil
 }
 
+// ListenForWatches listens on pgConn for the check state transitions that
+// WatchCheckStates streams. Until it is called, watches are unavailable, so
+// that only the API server holds a listener connection.
+func (s *service) ListenForWatches(pgConn string) error {
+	listener := pq.NewListener(pgConn, time.Second, time.Minute, nil)
+	if err := listener.Listen(watch.Channel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	s.watchHub = watch.NewHub(watch.DefaultBufferSize)
+	go s.watchHub.Listen(context.Background(), listener)
+	return nil
+}
+
 // http / grpc multiplexer for http health checks
 func (s *service) StartMux(addr, certfile, certkeyfile string) error {
 	// The grpc service
-	server := grpc.NewServer()
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(AuthInterceptor),
+		grpc.StreamInterceptor(AuthStreamInterceptor),
+	)
 	opsee.RegisterCatsServer(server, s)
+	api.RegisterCatsApiServer(server, s)
 	log.Infof("starting cats service at %s", addr)
 
 	s.httpServer = &http.Server{
@@ -67,10 +97,11 @@ func (s *service) StartMux(addr, certfile, certkeyfile string) error {
 }
 
 // Returns a new service http.Handler for testing. Sets up an opsee/tp router, which comes with a basic health endpoint for free.
-// It's going to have at least another endpoint for stripe webhooks
+// It serves the stripe webhook and the JSON gateway to the grpc service.
 func (s *service) NewHandler() http.Handler {
 	router := tp.NewHTTPRouter(context.Background())
 	router.Handle("POST", "/hooks/stripe", []tp.DecodeFunc{s.httpLogger(), s.stripeHookDecoder()}, s.stripeHookHandler())
+	s.registerGateway(router)
 	return router
 }
 
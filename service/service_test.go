@@ -1,12 +1,15 @@
 package service
 
 import (
+	"database/sql"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/opsee/basic/schema"
 	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/labels"
+	"github.com/opsee/cats/checks/templates"
 	"github.com/opsee/cats/store"
 	"github.com/opsee/cats/testutil"
 	"github.com/spf13/viper"
@@ -77,6 +80,10 @@ func (q *testTeamStore) List(page, perPage int) ([]*schema.Team, store.ListMeta,
 
 type testCheckStore struct{}
 
+func (q *testCheckStore) WithTX(txfun func(store.CheckStore, store.NotificationStore) error) error {
+	return txfun(q, nil)
+}
+
 func (q *testCheckStore) GetAndLockState(customerId, checkId string) (*checks.State, error) {
 	return nil, nil
 }
@@ -89,6 +96,9 @@ func (q *testCheckStore) GetMemo(checkId, bastionId string) (*checks.ResultMemo,
 func (q *testCheckStore) CreateStateTransitionLogEntry(checkId, customerId string, fromState, toState checks.StateId) (*checks.StateTransitionLogEntry, error) {
 	return nil, nil
 }
+func (q *testCheckStore) CreateStateTransitionLogEntryAt(checkId, customerId string, fromState, toState checks.StateId, createdAt time.Time) (*checks.StateTransitionLogEntry, error) {
+	return nil, nil
+}
 func (q *testCheckStore) GetLiveBastions(customerId, checkId string) ([]string, error) {
 	return []string{}, nil
 }
@@ -101,8 +111,60 @@ func (q *testCheckStore) GetCheckStateTransitionLogEntry(checkId, customerId str
 func (q *testCheckStore) GetCheck(user *schema.User, checkId string) (*schema.Check, error) {
 	return nil, nil
 }
+func (q *testCheckStore) GetCheckSpec(customerId, checkId string) (interface{}, error) {
+	return nil, nil
+}
 func (q *testCheckStore) GetChecks(user *schema.User) ([]*schema.Check, error) { return nil, nil }
-func (q *testCheckStore) GetCheckCount(customerId string) (int32, error)       { return int32(2), nil }
+func (q *testCheckStore) CreateCheck(check *schema.Check) error                { return nil }
+func (q *testCheckStore) UpdateCheck(check *schema.Check) error                { return nil }
+func (q *testCheckStore) DeleteCheck(customerId, checkId string) error         { return nil }
+func (q *testCheckStore) GetTakenCheckIds(ids []string) ([]string, error) {
+	return []string{}, nil
+}
+func (q *testCheckStore) ListChecks(customerId string, query *store.CheckListQuery) (*store.CheckList, error) {
+	return &store.CheckList{}, nil
+}
+func (q *testCheckStore) GetCheckLabels(customerId string, checkIds []string) (map[string]labels.Labels, error) {
+	return map[string]labels.Labels{}, nil
+}
+func (q *testCheckStore) PutCheckLabels(customerId, checkId string, l labels.Labels) error {
+	return nil
+}
+func (q *testCheckStore) UpdateCheckLabels(customerId string, checkIds []string, set labels.Labels, remove []string) error {
+	return nil
+}
+func (q *testCheckStore) GetCheckIds(customerId string, selector labels.Selector) ([]string, error) {
+	return nil, nil
+}
+func (q *testCheckStore) GetCheckTemplates(customerId string) ([]*templates.Template, error) {
+	return nil, nil
+}
+func (q *testCheckStore) GetCheckTemplate(customerId, templateId string) (*templates.Template, error) {
+	return nil, sql.ErrNoRows
+}
+func (q *testCheckStore) PutCheckTemplate(t *templates.Template) error { return nil }
+func (q *testCheckStore) DeleteCheckTemplate(customerId, templateId string) error {
+	return nil
+}
+func (q *testCheckStore) GetTemplateCheckIds(customerId, templateId string) ([]string, error) {
+	return nil, nil
+}
+func (q *testCheckStore) GetDerivedCheckIds(customerId string) ([]string, error) {
+	return nil, nil
+}
+func (q *testCheckStore) SetCheckTemplate(customerId, templateId string, checkIds []string) error {
+	return nil
+}
+func (q *testCheckStore) GetCheckCount(customerId string) (int32, error) { return int32(2), nil }
+func (q *testCheckStore) GetCheckStates(customerId string) ([]*checks.State, error) {
+	return nil, nil
+}
+func (q *testCheckStore) GetRedactionRules(customerId, checkId string) ([]*checks.RedactionRule, error) {
+	return nil, nil
+}
+func (q *testCheckStore) GetCustomerRedactionRules(customerId string) ([]*checks.RedactionRule, error) {
+	return nil, nil
+}
 
 func TestMain(m *testing.M) {
 	viper.SetEnvPrefix("cats")
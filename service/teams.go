@@ -1,20 +1,20 @@
 package service
 
 import (
-	"fmt"
-
 	"github.com/opsee/basic/schema"
 	opsee "github.com/opsee/basic/service"
 	"github.com/opsee/cats/store"
 	"github.com/opsee/cats/subscriptions"
 	log "github.com/opsee/logrus"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 // Fetches team, including users and invites.
 func (s *service) GetTeam(ctx context.Context, req *opsee.GetTeamRequest) (*opsee.GetTeamResponse, error) {
 	if req.Team == nil {
-		return nil, fmt.Errorf("invalid request, missing team")
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid request, missing team")
 	}
 
 	var (
@@ -33,7 +33,11 @@ func (s *service) GetTeam(ctx context.Context, req *opsee.GetTeamRequest) (*opse
 	}
 
 	if t == nil {
-		return nil, fmt.Errorf("no such team")
+		return nil, grpc.Errorf(codes.NotFound, "no such team")
+	}
+
+	if err := authorize(ctx, t.Id); err != nil {
+		return nil, err
 	}
 
 	if err := subscriptions.Get(t); err != nil {
@@ -51,7 +55,7 @@ func (s *service) CreateTeam(ctx context.Context, req *opsee.CreateTeamRequest)
 	team := req.Team
 
 	if team == nil {
-		return nil, fmt.Errorf("invalid request, missing team")
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid request, missing team")
 	}
 
 	if err := team.Validate(); err != nil {
@@ -59,11 +63,20 @@ func (s *service) CreateTeam(ctx context.Context, req *opsee.CreateTeamRequest)
 	}
 
 	if req.Requestor == nil {
-		return nil, fmt.Errorf("invalid request, missing requestor")
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid request, missing requestor")
 	}
 
 	if req.Requestor.Email == "" {
-		return nil, fmt.Errorf("invalid request, missing requestor email")
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid request, missing requestor email")
+	}
+
+	// new customers are created by Opsee, not by their users
+	authErr := authorizeAdmin(ctx)
+	if team.Id != "" {
+		authErr = authorize(ctx, team.Id)
+	}
+	if authErr != nil {
+		return nil, authErr
 	}
 
 	if team.SubscriptionPlan == "" {
@@ -104,13 +117,17 @@ func (s *service) CreateTeam(ctx context.Context, req *opsee.CreateTeamRequest)
 // token is present in the request.
 func (s *service) UpdateTeam(ctx context.Context, req *opsee.UpdateTeamRequest) (*opsee.UpdateTeamResponse, error) {
 	if req.Team == nil {
-		return nil, fmt.Errorf("invalid request, missing team")
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid request, missing team")
 	}
 
 	if err := req.Team.Validate(); err != nil {
 		return nil, err
 	}
 
+	if err := authorize(ctx, req.Team.Id); err != nil {
+		return nil, err
+	}
+
 	var (
 		currentTeam *schema.Team
 		err         error
@@ -123,7 +140,7 @@ func (s *service) UpdateTeam(ctx context.Context, req *opsee.UpdateTeamRequest)
 		}
 
 		if currentTeam == nil {
-			return fmt.Errorf("no team found")
+			return grpc.Errorf(codes.NotFound, "no team found")
 		}
 
 		checkCount, err := s.checkStore.GetCheckCount(req.Team.Id)
@@ -170,11 +187,15 @@ func (s *service) UpdateTeam(ctx context.Context, req *opsee.UpdateTeamRequest)
 // Soft deletes a team and cancels their stripe subscription if present.
 func (s *service) DeleteTeam(ctx context.Context, req *opsee.DeleteTeamRequest) (*opsee.DeleteTeamResponse, error) {
 	if req.Team == nil {
-		return nil, fmt.Errorf("invalid request, missing team")
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid request, missing team")
 	}
 
 	if req.Team.Id == "" {
-		return nil, fmt.Errorf("invalid request, missing team id")
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid request, missing team id")
+	}
+
+	if err := authorize(ctx, req.Team.Id); err != nil {
+		return nil, err
 	}
 
 	currentTeam, err := s.teamStore.Get(req.Team.Id)
@@ -22,12 +22,14 @@ func TestCreateUpdateDeleteTeam(t *testing.T) {
 		checkStore: cs,
 	}
 
+	ctx := TrustedContext(context.Background())
+
 	team := &schema.Team{
 		Name:             "http://www.customink.com/team/bowling-team-names",
 		SubscriptionPlan: "beta",
 	}
 
-	resp, err := s.CreateTeam(context.Background(), &opsee.CreateTeamRequest{
+	resp, err := s.CreateTeam(ctx, &opsee.CreateTeamRequest{
 		Requestor: &schema.User{
 			Email: "testin@opsee.com",
 		},
@@ -46,7 +48,7 @@ func TestCreateUpdateDeleteTeam(t *testing.T) {
 	team.SubscriptionPlan = "team_monthly"
 	team.SubscriptionQuantity = 5
 
-	res, err := s.UpdateTeam(context.Background(), &opsee.UpdateTeamRequest{
+	res, err := s.UpdateTeam(ctx, &opsee.UpdateTeamRequest{
 		Requestor: &schema.User{},
 		Team:      team,
 	})
@@ -55,7 +57,7 @@ func TestCreateUpdateDeleteTeam(t *testing.T) {
 	assert.Equal("team_monthly", res.Team.SubscriptionPlan)
 	assert.EqualValues(2, res.Team.SubscriptionQuantity)
 
-	_, err = s.DeleteTeam(context.Background(), &opsee.DeleteTeamRequest{
+	_, err = s.DeleteTeam(ctx, &opsee.DeleteTeamRequest{
 		Team: team,
 	})
 	assert.NoError(err)
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"database/sql"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/checks/bundle"
+	"github.com/opsee/cats/checks/templates"
+	"github.com/opsee/cats/store"
+	log "github.com/opsee/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// PutCheckTemplate creates or replaces a check template, then creates,
+// updates and deletes the checks made from it so that there is one for each
+// target it selects. Only pruning deletes the checks of targets that are
+// only known from the template's own checks. Every check is validated before
+// anything is changed, then the template and its checks are written in one
+// transaction.
+func (s *service) PutCheckTemplate(ctx context.Context, req *api.PutCheckTemplateRequest) (*api.PutCheckTemplateResponse, error) {
+	if req.CustomerId == "" {
+		log.Error("missing customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	if req.Template == nil || req.Template.Check == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "template and its check are required")
+	}
+
+	tmpl := &templates.Template{
+		Id:         req.Template.Id,
+		CustomerId: req.CustomerId,
+		Name:       req.Template.Name,
+		Check:      bundle.FromCheck(req.Template.Check),
+		Targets:    templates.TargetSelector{Type: req.Template.TargetType, Ids: req.Template.TargetIds},
+	}
+	tmpl.Check.Id = ""
+	tmpl.Check.Target = bundle.Target{}
+
+	if err := tmpl.Validate(); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	if tmpl.Id != "" {
+		if _, err := s.checkStore.GetCheckTemplate(req.CustomerId, tmpl.Id); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, grpc.Errorf(codes.NotFound, "no such check template")
+			}
+
+			log.WithError(err).Errorf("Error getting check template from db: %s", tmpl.Id)
+			return nil, err
+		}
+	}
+
+	existing, err := s.checkStore.GetChecks(&schema.User{CustomerId: req.CustomerId})
+	if err != nil {
+		log.WithError(err).Error("Error getting checks from db.")
+		return nil, err
+	}
+
+	var derivedIds []string
+	if tmpl.Id != "" {
+		if derivedIds, err = s.checkStore.GetTemplateCheckIds(req.CustomerId, tmpl.Id); err != nil {
+			log.WithError(err).Errorf("Error getting check template's checks from db: %s", tmpl.Id)
+			return nil, err
+		}
+	}
+
+	// Targets are known from the checks that weren't made from templates, so
+	// that checks made for a target don't keep it known once it's gone, and
+	// unless pruning, from this template's own checks, so that editing a
+	// template doesn't delete them. A selector's ids are selected whether or
+	// not they're known, so any check may name them.
+	known := knownTargets(existing, req.Targets)
+	if len(tmpl.Targets.Ids) == 0 {
+		allDerivedIds, err := s.checkStore.GetDerivedCheckIds(req.CustomerId)
+		if err != nil {
+			log.WithError(err).Error("Error getting checks made from templates from db.")
+			return nil, err
+		}
+
+		others := withoutChecks(existing, allDerivedIds)
+		if !req.Prune {
+			others = append(others, derivedChecks(existing, derivedIds)...)
+		}
+
+		known = knownTargets(others, req.Targets)
+	}
+
+	plan, err := tmpl.NewPlan(tmpl.Targets.Select(known), derivedChecks(existing, derivedIds))
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	changes, err := s.validatedChanges(req.CustomerId, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	// in a dry run, created checks have no ids
+	checkIds := changes.checkIds()
+	if !req.DryRun {
+		err := s.checkStore.WithTX(func(checkStore store.CheckStore, notificationStore store.NotificationStore) error {
+			if err := checkStore.PutCheckTemplate(tmpl); err != nil {
+				log.WithError(err).Error("Error putting check template in db.")
+				return err
+			}
+
+			if err := writeChanges(ctx, checkStore, notificationStore, req.CustomerId, changes); err != nil {
+				return err
+			}
+
+			if err := checkStore.SetCheckTemplate(req.CustomerId, tmpl.Id, changes.checkIds()); err != nil {
+				log.WithError(err).Errorf("Error setting check template in db: %s", tmpl.Id)
+				return err
+			}
+
+			ids, err := checkStore.GetTemplateCheckIds(req.CustomerId, tmpl.Id)
+			if err != nil {
+				log.WithError(err).Errorf("Error getting check template's checks from db: %s", tmpl.Id)
+				return err
+			}
+
+			checkIds = ids
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &api.PutCheckTemplateResponse{Changes: changes.applied(), Unchanged: int32(plan.Unchanged), DryRun: req.DryRun}
+	resp.Template = apiCheckTemplate(tmpl, checkIds)
+	return resp, nil
+}
+
+// ListCheckTemplates returns a customer's check templates.
+func (s *service) ListCheckTemplates(ctx context.Context, req *api.ListCheckTemplatesRequest) (*api.ListCheckTemplatesResponse, error) {
+	if req.CustomerId == "" {
+		log.Error("missing customer_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	tmpls, err := s.checkStore.GetCheckTemplates(req.CustomerId)
+	if err != nil {
+		log.WithError(err).Error("Error getting check templates from db.")
+		return nil, err
+	}
+
+	resp := &api.ListCheckTemplatesResponse{}
+	for _, tmpl := range tmpls {
+		checkIds, err := s.checkStore.GetTemplateCheckIds(req.CustomerId, tmpl.Id)
+		if err != nil {
+			log.WithError(err).Errorf("Error getting check template's checks from db: %s", tmpl.Id)
+			return nil, err
+		}
+
+		resp.Templates = append(resp.Templates, apiCheckTemplate(tmpl, checkIds))
+	}
+
+	return resp, nil
+}
+
+// DeleteCheckTemplate deletes a check template, and unless asked to keep
+// them, the checks made from it, in one transaction.
+func (s *service) DeleteCheckTemplate(ctx context.Context, req *api.DeleteCheckTemplateRequest) (*api.DeleteCheckTemplateResponse, error) {
+	if req.CustomerId == "" || req.TemplateId == "" {
+		log.Error("missing customer_id or template_id in request")
+		return nil, grpc.Errorf(codes.InvalidArgument, "customer_id and template_id are required")
+	}
+
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.checkStore.GetCheckTemplate(req.CustomerId, req.TemplateId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, grpc.Errorf(codes.NotFound, "no such check template")
+		}
+
+		log.WithError(err).Errorf("Error getting check template from db: %s", req.TemplateId)
+		return nil, err
+	}
+
+	resp := &api.DeleteCheckTemplateResponse{}
+	err := s.checkStore.WithTX(func(checkStore store.CheckStore, notificationStore store.NotificationStore) error {
+		if !req.KeepChecks {
+			checkIds, err := checkStore.GetTemplateCheckIds(req.CustomerId, req.TemplateId)
+			if err != nil {
+				log.WithError(err).Errorf("Error getting check template's checks from db: %s", req.TemplateId)
+				return err
+			}
+
+			for _, checkId := range checkIds {
+				if err := checkStore.DeleteCheck(req.CustomerId, checkId); err != nil && err != sql.ErrNoRows {
+					log.WithError(err).Errorf("Error deleting check in db: %s", checkId)
+					return err
+				}
+			}
+			resp.CheckIds = checkIds
+		}
+
+		if err := checkStore.DeleteCheckTemplate(req.CustomerId, req.TemplateId); err != nil && err != sql.ErrNoRows {
+			log.WithError(err).Errorf("Error deleting check template in db: %s", req.TemplateId)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// knownTargets are the targets of a customer's checks, and the others the
+// request knows of. Cats has no other inventory of a customer's targets.
+func knownTargets(checks []*schema.Check, others []*schema.Target) []bundle.Target {
+	var known []bundle.Target
+	for _, check := range checks {
+		if check.Target != nil {
+			known = append(known, bundle.Target{Type: check.Target.Type, Id: check.Target.Id, Name: check.Target.Name})
+		}
+	}
+
+	for _, t := range others {
+		if t != nil {
+			known = append(known, bundle.Target{Type: t.Type, Id: t.Id, Name: t.Name})
+		}
+	}
+
+	return known
+}
+
+// withoutChecks returns the checks that don't have one of ids.
+func withoutChecks(checks []*schema.Check, ids []string) []*schema.Check {
+	derived := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		derived[id] = true
+	}
+
+	var others []*schema.Check
+	for _, check := range checks {
+		if !derived[check.Id] {
+			others = append(others, check)
+		}
+	}
+
+	return others
+}
+
+// derivedChecks returns the checks with ids.
+func derivedChecks(checks []*schema.Check, ids []string) []*schema.Check {
+	derived := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		derived[id] = true
+	}
+
+	var matched []*schema.Check
+	for _, check := range checks {
+		if derived[check.Id] {
+			matched = append(matched, check)
+		}
+	}
+
+	return matched
+}
+
+func apiCheckTemplate(tmpl *templates.Template, checkIds []string) *api.CheckTemplate {
+	check := tmpl.Check.Check(tmpl.CustomerId)
+	check.Target = nil
+
+	return &api.CheckTemplate{
+		Id:         tmpl.Id,
+		Name:       tmpl.Name,
+		Check:      check,
+		TargetType: tmpl.Targets.Type,
+		TargetIds:  tmpl.Targets.Ids,
+		CheckIds:   checkIds,
+	}
+}
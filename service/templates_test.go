@@ -0,0 +1,155 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/checks/templates"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type testTemplateCheckStore struct {
+	testCheckStore
+	checks []*schema.Check
+}
+
+func (q *testTemplateCheckStore) GetChecks(user *schema.User) ([]*schema.Check, error) {
+	return q.checks, nil
+}
+
+func (q *testTemplateCheckStore) GetCheckTemplate(customerId, templateId string) (*templates.Template, error) {
+	if templateId != "template-id" {
+		return nil, sql.ErrNoRows
+	}
+
+	return &templates.Template{Id: templateId, CustomerId: customerId}, nil
+}
+
+func (q *testTemplateCheckStore) GetTemplateCheckIds(customerId, templateId string) ([]string, error) {
+	return []string{"derived-web"}, nil
+}
+
+func (q *testTemplateCheckStore) GetDerivedCheckIds(customerId string) ([]string, error) {
+	return []string{"derived-web"}, nil
+}
+
+func TestPutCheckTemplateDryRun(t *testing.T) {
+	customerId := "11111111-1111-1111-1111-111111111111"
+	spec := func() *schema.Check {
+		return &schema.Check{
+			Name:             "{{.Target.Name}} health",
+			ExecutionGroupId: customerId,
+			MinFailingCount:  1,
+			MinFailingTime:   90,
+			Spec:             &schema.Check_HttpCheck{HttpCheck: &schema.HttpCheck{Protocol: "http", Verb: "GET", Port: 80, Path: "/health"}},
+		}
+	}
+
+	derived := spec()
+	derived.Id = "derived-web"
+	derived.CustomerId = customerId
+	derived.Name = "web health"
+	derived.Interval = 30
+	derived.Target = &schema.Target{Type: "elb", Id: "web-elb", Name: "web"}
+
+	cs := &testTemplateCheckStore{checks: []*schema.Check{
+		derived,
+		{Id: "by-hand", Name: "api", Target: &schema.Target{Type: "elb", Id: "api-elb", Name: "api"}},
+		{Id: "sg", Name: "sg", Target: &schema.Target{Type: "sg", Id: "sg-123456"}},
+	}}
+	s := &service{checkStore: cs}
+	ctx := NewPrincipalContext(context.Background(), &schema.User{Id: 7, CustomerId: customerId})
+
+	resp, err := s.PutCheckTemplate(ctx, &api.PutCheckTemplateRequest{
+		CustomerId: customerId,
+		Template:   &api.CheckTemplate{Name: "elb health", Check: spec(), TargetType: "elb"},
+		Targets:    []*schema.Target{{Type: "elb", Id: "new-elb", Name: "new"}},
+		DryRun:     true,
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.DryRun)
+	assert.Equal(t, []*api.CheckChange{
+		{Action: "create", Name: "api health"},
+		{Action: "create", Name: "new health"},
+	}, resp.Changes)
+
+	// the template's checks are kept in sync with it
+	resp, err = s.PutCheckTemplate(ctx, &api.PutCheckTemplateRequest{
+		CustomerId: customerId,
+		Template:   &api.CheckTemplate{Id: "template-id", Name: "elb health", Check: spec(), TargetType: "elb"},
+		Targets:    []*schema.Target{{Type: "elb", Id: "web-elb", Name: "web"}},
+		DryRun:     true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*api.CheckChange{{Action: "create", Name: "api health"}}, resp.Changes)
+	assert.Equal(t, int32(1), resp.Unchanged)
+
+	// editing the template without its targets keeps its checks
+	edited := spec()
+	edited.GetHttpCheck().Port = 8080
+	resp, err = s.PutCheckTemplate(ctx, &api.PutCheckTemplateRequest{
+		CustomerId: customerId,
+		Template:   &api.CheckTemplate{Id: "template-id", Name: "elb health", Check: edited, TargetType: "elb"},
+		DryRun:     true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*api.CheckChange{
+		{Action: "create", Name: "api health"},
+		{Action: "update", CheckId: "derived-web", Name: "web health"},
+	}, resp.Changes)
+
+	// pruning forgets targets known only from the checks made from templates
+	resp, err = s.PutCheckTemplate(ctx, &api.PutCheckTemplateRequest{
+		CustomerId: customerId,
+		Template:   &api.CheckTemplate{Id: "template-id", Name: "elb health", Check: spec(), TargetType: "elb"},
+		Prune:      true,
+		DryRun:     true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*api.CheckChange{
+		{Action: "create", Name: "api health"},
+		{Action: "delete", CheckId: "derived-web", Name: "web health"},
+	}, resp.Changes)
+
+	resp, err = s.PutCheckTemplate(ctx, &api.PutCheckTemplateRequest{
+		CustomerId: customerId,
+		Template:   &api.CheckTemplate{Id: "template-id", Name: "elb health", Check: edited, TargetType: "elb", TargetIds: []string{"web-elb"}},
+		DryRun:     true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*api.CheckChange{{Action: "update", CheckId: "derived-web", Name: "web health"}}, resp.Changes)
+
+	resp, err = s.PutCheckTemplate(ctx, &api.PutCheckTemplateRequest{
+		CustomerId: customerId,
+		Template:   &api.CheckTemplate{Id: "template-id", Name: "elb health", Check: spec(), TargetType: "elb", TargetIds: []string{"api-elb"}},
+		DryRun:     true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*api.CheckChange{
+		{Action: "create", Name: "api health"},
+		{Action: "delete", CheckId: "derived-web", Name: "web health"},
+	}, resp.Changes)
+
+	_, err = s.PutCheckTemplate(ctx, &api.PutCheckTemplateRequest{
+		CustomerId: customerId,
+		Template:   &api.CheckTemplate{Name: "elb health", Check: spec()},
+	})
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+
+	_, err = s.PutCheckTemplate(ctx, &api.PutCheckTemplateRequest{
+		CustomerId: customerId,
+		Template:   &api.CheckTemplate{Id: "nope", Name: "elb health", Check: spec(), TargetType: "elb"},
+	})
+	assert.Equal(t, codes.NotFound, grpc.Code(err))
+
+	_, err = s.PutCheckTemplate(ctx, &api.PutCheckTemplateRequest{
+		CustomerId: "22222222-2222-2222-2222-222222222222",
+		Template:   &api.CheckTemplate{Name: "elb health", Check: spec(), TargetType: "elb"},
+	})
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+}
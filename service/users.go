@@ -3,7 +3,6 @@ package service
 import (
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/opsee/basic/schema"
@@ -11,6 +10,8 @@ import (
 	"github.com/opsee/cats/servicer"
 	opsee_types "github.com/opsee/protobuf/opseeproto/types"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 func (s *service) GetUser(ctx context.Context, req *opsee.GetUserRequest) (*opsee.GetUserResponse, error) {
@@ -20,21 +21,28 @@ func (s *service) GetUser(ctx context.Context, req *opsee.GetUserRequest) (*opse
 	)
 
 	if req.CustomerId != "" {
-		user, err = servicer.GetUserCustID(req.CustomerId)
-		if err != nil {
+		if err := authorize(ctx, req.CustomerId); err != nil {
 			return nil, err
 		}
+
+		user, err = servicer.GetUserCustID(req.CustomerId)
 	} else if req.Email != "" {
 		user, err = servicer.GetUserEmail(req.Email)
-		if err != nil {
-			return nil, err
-		}
-
 	} else {
 		user, err = servicer.GetUser(int(req.Id))
-		if err != nil {
-			return nil, err
-		}
+	}
+
+	// a missing user and another customer's user look the same to anyone
+	// who can't see every customer, so ids and emails can't be probed
+	if err == servicer.UserNotFound && authorizeAdmin(ctx) != nil {
+		return nil, errPermissionDenied
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, user.CustomerId); err != nil {
+		return nil, err
 	}
 
 	toke, err := json.Marshal(user)
@@ -49,6 +57,10 @@ func (s *service) GetUser(ctx context.Context, req *opsee.GetUserRequest) (*opse
 }
 
 func (s *service) ListUsers(ctx context.Context, req *opsee.ListUsersRequest) (*opsee.ListUsersResponse, error) {
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
 	users, err := servicer.ListUsers(int(req.PerPage), int(req.Page))
 	if err != nil {
 		return nil, err
@@ -65,7 +77,20 @@ func (s *service) ListUsers(ctx context.Context, req *opsee.ListUsersRequest) (*
 // Delete a user
 // TODO(dan) This also needs to delete the users subscription.
 func (s *service) DeleteUser(ctx context.Context, req *opsee.DeleteUserRequest) (*opsee.DeleteUserResponse, error) {
-	err := servicer.DeleteUser(int(req.User.Id))
+	if req.User == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid request, missing user")
+	}
+
+	user, err := servicer.GetUser(int(req.User.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authorize(ctx, user.CustomerId); err != nil {
+		return nil, err
+	}
+
+	err = servicer.DeleteUser(int(req.User.Id))
 	if err != nil {
 		return nil, err
 	}
@@ -77,11 +102,19 @@ func (s *service) DeleteUser(ctx context.Context, req *opsee.DeleteUserRequest)
 
 // Update a user's email, name, or password
 func (s *service) UpdateUser(ctx context.Context, req *opsee.UpdateUserRequest) (*opsee.UserTokenResponse, error) {
+	if req.Requestor == nil || req.User == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid request, missing requestor or user")
+	}
+
 	user, err := servicer.GetUser(int(req.User.Id))
 	if err != nil {
 		return nil, err
 	}
 
+	if err := authorize(ctx, user.CustomerId); err != nil {
+		return nil, err
+	}
+
 	if !req.Requestor.IsOpseeAdmin() && req.Requestor.CustomerId != user.CustomerId {
 		return nil, opsee_types.NewPermissionsError("must be on same team")
 	}
@@ -119,13 +152,21 @@ func (s *service) UpdateUser(ctx context.Context, req *opsee.UpdateUserRequest)
 }
 
 func (s *service) InviteUser(ctx context.Context, req *opsee.InviteUserRequest) (*opsee.InviteUserResponse, error) {
+	if req.Requestor == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid request, missing requestor")
+	}
+
+	if err := authorize(ctx, req.Requestor.CustomerId); err != nil {
+		return nil, err
+	}
+
 	// TODO(dan) this could be used as a side-channel to find valid email addresses maybe
 	user, err := servicer.GetUserEmail(req.Email)
 	if err != nil && err != servicer.UserNotFound {
 		return nil, err
 	}
 	if user != nil {
-		return nil, fmt.Errorf("user exists with this email address")
+		return nil, grpc.Errorf(codes.AlreadyExists, "user exists with this email address")
 	}
 
 	teamName := ""
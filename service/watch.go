@@ -0,0 +1,96 @@
+package service
+
+import (
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/watch"
+	log "github.com/opsee/logrus"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var errWatchEvicted = grpc.Errorf(codes.ResourceExhausted, "check state watch fell behind, watch again to resynchronize")
+
+// WatchCheckStates streams a snapshot of a customer's check states, then
+// their transitions as they are committed. The watch is subscribed before
+// the snapshot is read, so no transition is missed in between. A client
+// that doesn't keep up is disconnected with codes.ResourceExhausted.
+func (s *service) WatchCheckStates(req *api.WatchCheckStatesRequest, stream api.CatsApi_WatchCheckStatesServer) error {
+	if req.CustomerId == "" {
+		log.Error("missing customer_id in request")
+		return grpc.Errorf(codes.InvalidArgument, "customer_id is required")
+	}
+
+	ctx := stream.Context()
+	if err := authorize(ctx, req.CustomerId); err != nil {
+		return err
+	}
+
+	if s.watchHub == nil {
+		return grpc.Errorf(codes.Unavailable, "check state watches are not available")
+	}
+
+	sub := s.watchHub.Subscribe(req.CustomerId)
+	defer s.watchHub.Unsubscribe(sub)
+
+	states, err := s.checkStore.GetCheckStates(req.CustomerId)
+	if err != nil {
+		log.WithError(err).Error("Error getting check states from db.")
+		return err
+	}
+
+	for _, state := range states {
+		if err := stream.Send(snapshotEvent(state)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-sub.C:
+			if !ok {
+				if sub.Evicted() {
+					return errWatchEvicted
+				}
+				return nil
+			}
+
+			if err := stream.Send(transitionEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func snapshotEvent(state *checks.State) *api.CheckStateEvent {
+	event := &api.CheckStateEvent{
+		CheckId:       state.CheckId,
+		State:         state.Id.String(),
+		FailingCount:  state.FailingCount,
+		ResponseCount: state.ResponseCount,
+		Timestamp:     &opsee_types.Timestamp{},
+		Snapshot:      true,
+	}
+	event.Timestamp.Scan(state.LastUpdated)
+
+	return event
+}
+
+func transitionEvent(e *watch.Event) *api.CheckStateEvent {
+	event := &api.CheckStateEvent{
+		CheckId:       e.CheckId,
+		State:         e.To.String(),
+		FromState:     e.From.String(),
+		TransitionId:  e.TransitionId,
+		FailingCount:  e.FailingCount,
+		ResponseCount: e.ResponseCount,
+		Timestamp:     &opsee_types.Timestamp{},
+	}
+	event.Timestamp.Scan(e.CreatedAt)
+
+	return event
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/api"
+	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/watch"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type testWatchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *api.CheckStateEvent
+}
+
+func (s *testWatchStream) Context() context.Context { return s.ctx }
+
+func (s *testWatchStream) Send(event *api.CheckStateEvent) error {
+	s.sent <- event
+	return nil
+}
+
+type testWatchCheckStore struct {
+	testCheckStore
+}
+
+func (q *testWatchCheckStore) GetCheckStates(customerId string) ([]*checks.State, error) {
+	return []*checks.State{{CheckId: "check", CustomerId: customerId, Id: checks.StateOK}}, nil
+}
+
+func TestWatchCheckStates(t *testing.T) {
+	customerId := "11111111-1111-1111-1111-111111111111"
+	hub := watch.NewHub(1)
+	s := &service{checkStore: &testWatchCheckStore{}, watchHub: hub}
+
+	ctx, cancel := context.WithCancel(NewPrincipalContext(context.Background(), &schema.User{Id: 7, CustomerId: customerId}))
+	defer cancel()
+
+	stream := &testWatchStream{ctx: ctx, sent: make(chan *api.CheckStateEvent)}
+
+	err := s.WatchCheckStates(&api.WatchCheckStatesRequest{}, stream)
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+
+	err = s.WatchCheckStates(&api.WatchCheckStatesRequest{CustomerId: "22222222-2222-2222-2222-222222222222"}, stream)
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+
+	done := make(chan error)
+	go func() {
+		done <- s.WatchCheckStates(&api.WatchCheckStatesRequest{CustomerId: customerId}, stream)
+	}()
+
+	// the snapshot is read after subscribing
+	event := <-stream.sent
+	assert.True(t, event.Snapshot)
+	assert.Equal(t, checks.StateOK.String(), event.State)
+
+	hub.Publish(&watch.Event{TransitionId: 1, CheckId: "check", CustomerId: customerId, From: checks.StateOK, To: checks.StateFailWait})
+	event = <-stream.sent
+	assert.False(t, event.Snapshot)
+	assert.Equal(t, int64(1), event.TransitionId)
+	assert.Equal(t, checks.StateOK.String(), event.FromState)
+	assert.Equal(t, checks.StateFailWait.String(), event.State)
+
+	// nothing is receiving, so the watch's buffer fills and it is evicted
+	hub.Publish(&watch.Event{TransitionId: 2, CustomerId: customerId})
+	hub.Publish(&watch.Event{TransitionId: 3, CustomerId: customerId})
+	hub.Publish(&watch.Event{TransitionId: 4, CustomerId: customerId})
+
+	go func() {
+		for range stream.sent {
+		}
+	}()
+
+	assert.Equal(t, codes.ResourceExhausted, grpc.Code(<-done))
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/mailer"
+	"github.com/opsee/cats/preferences"
 	log "github.com/opsee/logrus"
 )
 
@@ -29,6 +31,11 @@ func CreateActiveInvite(teamName, senderEmail, customerId, email string, perms *
 
 	// send an email, create a lead and notify slack here!
 	go func() {
+		if !mailer.Allowed(preferences.TeamInvites, signup.Email) {
+			log.WithField("email", signup.Email).Info("not sending team invitation, recipient opted out")
+			return
+		}
+
 		mergeVars := map[string]interface{}{
 			"signup_id":    fmt.Sprint(signup.Id),
 			"signup_token": VerificationToken(fmt.Sprintf("%d", signup.Id)),
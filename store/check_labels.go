@@ -0,0 +1,140 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/opsee/cats/checks/labels"
+)
+
+// GetCheckLabels returns the labels of checks, by check id. Checks without
+// labels are left out.
+func (q *checkStore) GetCheckLabels(customerId string, checkIds []string) (map[string]labels.Labels, error) {
+	byCheck := make(map[string]labels.Labels)
+	if len(checkIds) == 0 {
+		return byCheck, nil
+	}
+
+	rows := []struct {
+		CheckId string `db:"check_id"`
+		Key     string `db:"key"`
+		Value   string `db:"value"`
+	}{}
+	if err := selectIn(q, &rows, "SELECT check_id, key, value FROM check_labels WHERE customer_id = ? AND check_id IN (?)", customerId, checkIds); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if byCheck[row.CheckId] == nil {
+			byCheck[row.CheckId] = make(labels.Labels)
+		}
+		byCheck[row.CheckId][row.Key] = row.Value
+	}
+
+	return byCheck, nil
+}
+
+// PutCheckLabels replaces a check's labels.
+func (q *checkStore) PutCheckLabels(customerId, checkId string, l labels.Labels) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+
+	return q.withTx(func(q *checkStore) error {
+		if _, err := q.Exec("DELETE FROM check_labels WHERE customer_id = $1 AND check_id = $2", customerId, checkId); err != nil {
+			return err
+		}
+
+		return q.setCheckLabels(customerId, []string{checkId}, l)
+	})
+}
+
+// UpdateCheckLabels sets and removes labels on checks, leaving their other
+// labels alone.
+func (q *checkStore) UpdateCheckLabels(customerId string, checkIds []string, set labels.Labels, remove []string) error {
+	if err := set.Validate(); err != nil {
+		return err
+	}
+
+	if len(checkIds) == 0 {
+		return nil
+	}
+
+	return q.withTx(func(q *checkStore) error {
+		if len(remove) > 0 {
+			query, args, err := sqlx.In("DELETE FROM check_labels WHERE customer_id = ? AND check_id IN (?) AND key IN (?)", customerId, checkIds, remove)
+			if err != nil {
+				return err
+			}
+
+			if _, err := q.Exec(q.Rebind(query), args...); err != nil {
+				return err
+			}
+		}
+
+		return q.setCheckLabels(customerId, checkIds, set)
+	})
+}
+
+func (q *checkStore) setCheckLabels(customerId string, checkIds []string, l labels.Labels) error {
+	for _, checkId := range checkIds {
+		for k, v := range l {
+			_, err := q.Exec(
+				`INSERT INTO check_labels (check_id, customer_id, key, value) VALUES ($1, $2, $3, $4)
+				 ON CONFLICT (check_id, key) DO UPDATE SET value = $4`,
+				checkId, customerId, k, v,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetCheckIds returns the ids of a customer's checks whose labels match
+// selector.
+func (q *checkStore) GetCheckIds(customerId string, selector labels.Selector) ([]string, error) {
+	where, args := selectorClauses(selector)
+	where = append([]string{"customer_id = ?", "deleted = false"}, where...)
+
+	var ids []string
+	err := sqlx.Select(q, &ids, q.Rebind("SELECT id FROM checks WHERE "+strings.Join(where, " AND ")+" ORDER BY id"), append([]interface{}{customerId}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// selectorClauses returns the conditions on checks, with ? placeholders,
+// that match a selector.
+func selectorClauses(selector labels.Selector) ([]string, []interface{}) {
+	var (
+		where []string
+		args  []interface{}
+	)
+
+	for _, r := range selector {
+		exists := "EXISTS"
+		if r.Operator == labels.DoesNotExist || r.Operator == labels.NotEquals || r.Operator == labels.NotIn {
+			exists = "NOT EXISTS"
+		}
+
+		clause := "SELECT 1 FROM check_labels WHERE check_labels.check_id = checks.id AND check_labels.customer_id = checks.customer_id AND check_labels.key = ?"
+		args = append(args, r.Key)
+
+		if len(r.Values) > 0 {
+			clause += " AND check_labels.value IN (?" + strings.Repeat(", ?", len(r.Values)-1) + ")"
+			for _, v := range r.Values {
+				args = append(args, v)
+			}
+		}
+
+		where = append(where, fmt.Sprintf("%s (%s)", exists, clause))
+	}
+
+	return where, args
+}
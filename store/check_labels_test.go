@@ -0,0 +1,43 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/opsee/cats/checks/labels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	withCheckFixtures(func(cs CheckStore) {
+		customerId := "11111111-1111-1111-1111-111111111111"
+
+		prod, web := newTestCheck(), newTestCheck()
+		assert.NoError(cs.CreateCheck(prod))
+		assert.NoError(cs.CreateCheck(web))
+
+		assert.NoError(cs.PutCheckLabels(customerId, prod.Id, labels.Labels{"env": "prod", "team": "payments"}))
+		assert.NoError(cs.PutCheckLabels(customerId, web.Id, labels.Labels{"env": "staging", "team": "infra"}))
+		assert.Error(cs.PutCheckLabels(customerId, web.Id, labels.Labels{"env": "pr od"}))
+
+		selector, err := labels.Parse("env=prod,team!=infra")
+		assert.NoError(err)
+		ids, err := cs.GetCheckIds(customerId, selector)
+		assert.NoError(err)
+		assert.Equal([]string{prod.Id}, ids)
+
+		assert.NoError(cs.UpdateCheckLabels(customerId, []string{prod.Id, web.Id}, labels.Labels{"tier": "web"}, []string{"team"}))
+
+		byCheck, err := cs.GetCheckLabels(customerId, []string{prod.Id, web.Id})
+		assert.NoError(err)
+		assert.Equal(labels.Labels{"env": "prod", "tier": "web"}, byCheck[prod.Id])
+		assert.Equal(labels.Labels{"env": "staging", "tier": "web"}, byCheck[web.Id])
+
+		list, err := cs.ListChecks(customerId, &CheckListQuery{Selector: "env in (staging)"})
+		assert.NoError(err)
+		assert.Len(list.Checks, 1)
+		assert.Equal(web.Id, list.Checks[0].Id)
+		assert.Equal(byCheck[web.Id], list.Labels[web.Id])
+	})
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks/labels"
+)
+
+const (
+	SortByName           = "name"
+	SortByState          = "state"
+	SortByLastTransition = "last_transition"
+
+	DefaultCheckListLimit = 100
+	MaxCheckListLimit     = 1000
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// checkSortColumns are the expressions checks are sorted by, and the
+	// type their cursor values are cast to.
+	checkSortColumns = map[string]struct{ expr, cast string }{
+		SortByName:           {"checks.name", "text"},
+		SortByState:          {"COALESCE(check_states.state_name, 'INVALID')", "text"},
+		SortByLastTransition: {"COALESCE(check_states.time_entered, 'epoch'::timestamptz)", "timestamptz"},
+	}
+)
+
+// CheckListQuery filters, sorts and pages a customer's checks. Zero values
+// don't filter.
+type CheckListQuery struct {
+	State      string
+	TargetType string
+	TargetId   string
+	// Name matches checks whose name contains it, ignoring case.
+	Name             string
+	ExecutionGroupId string
+	MinFailingCount  int32
+	// MaxFailingCount, if positive, is the most failing responses a check
+	// may have.
+	MaxFailingCount int32
+	// Selector is a label selector, e.g. "env=prod,team!=infra".
+	Selector string
+	// SortBy is SortByName, the default, SortByState or
+	// SortByLastTransition. Ties are broken by check id.
+	SortBy     string
+	Descending bool
+	// Cursor is the NextCursor of the previous page, or empty for the
+	// first page.
+	Cursor string
+	Limit  int
+}
+
+// CheckList is a page of checks.
+type CheckList struct {
+	Checks []*schema.Check
+	// Total is the number of checks matching the query's filters, on all
+	// pages.
+	Total      int
+	NextCursor string
+	// Labels are the labels of the checks on the page, by check id.
+	Labels map[string]labels.Labels
+}
+
+// checkCursor is the position after the last check of a page.
+type checkCursor struct {
+	SortBy  string `json:"s"`
+	Value   string `json:"v"`
+	CheckId string `json:"i"`
+}
+
+// Validate checks the sort key and cursor, and defaults the limit.
+func (cq *CheckListQuery) Validate() error {
+	if cq.SortBy == "" {
+		cq.SortBy = SortByName
+	}
+
+	if _, ok := checkSortColumns[cq.SortBy]; !ok {
+		return fmt.Errorf("invalid sort key: %s", cq.SortBy)
+	}
+
+	if cq.Limit <= 0 {
+		cq.Limit = DefaultCheckListLimit
+	}
+
+	if cq.Limit > MaxCheckListLimit {
+		cq.Limit = MaxCheckListLimit
+	}
+
+	if _, err := labels.Parse(cq.Selector); err != nil {
+		return err
+	}
+
+	if cq.Cursor != "" {
+		cursor, err := decodeCheckCursor(cq.Cursor)
+		if err != nil {
+			return err
+		}
+
+		if cursor.SortBy != cq.SortBy {
+			return ErrInvalidCursor
+		}
+	}
+
+	return nil
+}
+
+func decodeCheckCursor(s string) (*checkCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &checkCursor{}
+	if err := json.Unmarshal(b, cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+func (c *checkCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ListChecks returns a page of a customer's checks matching query.
+func (q *checkStore) ListChecks(customerId string, query *CheckListQuery) (*CheckList, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	sort := checkSortColumns[query.SortBy]
+
+	var (
+		where = []string{"checks.customer_id = ?", "checks.deleted = false"}
+		args  = []interface{}{customerId}
+	)
+
+	if query.State != "" {
+		where = append(where, "COALESCE(check_states.state_name, 'INVALID') = ?")
+		args = append(args, strings.ToUpper(query.State))
+	}
+
+	if query.TargetType != "" {
+		where = append(where, "checks.target_type = ?")
+		args = append(args, query.TargetType)
+	}
+
+	if query.TargetId != "" {
+		where = append(where, "checks.target_id = ?")
+		args = append(args, query.TargetId)
+	}
+
+	if query.Name != "" {
+		where = append(where, "checks.name ILIKE ?")
+		args = append(args, "%"+escapeLike(query.Name)+"%")
+	}
+
+	if query.ExecutionGroupId != "" {
+		where = append(where, "checks.execution_group_id = ?")
+		args = append(args, query.ExecutionGroupId)
+	}
+
+	if query.MinFailingCount > 0 {
+		where = append(where, "COALESCE(check_states.failing_count, 0) >= ?")
+		args = append(args, query.MinFailingCount)
+	}
+
+	if query.MaxFailingCount > 0 {
+		where = append(where, "COALESCE(check_states.failing_count, 0) <= ?")
+		args = append(args, query.MaxFailingCount)
+	}
+
+	selector, _ := labels.Parse(query.Selector)
+	selectorWhere, selectorArgs := selectorClauses(selector)
+	where = append(where, selectorWhere...)
+	args = append(args, selectorArgs...)
+
+	from := " FROM checks LEFT OUTER JOIN check_states ON (checks.id = check_states.check_id) WHERE " + strings.Join(where, " AND ")
+
+	list := &CheckList{}
+	if err := sqlx.Get(q, &list.Total, q.Rebind("SELECT count(*)"+from), args...); err != nil {
+		return nil, err
+	}
+
+	order, direction := "ASC", ">"
+	if query.Descending {
+		order, direction = "DESC", "<"
+	}
+
+	if query.Cursor != "" {
+		cursor, _ := decodeCheckCursor(query.Cursor)
+		from += fmt.Sprintf(" AND (%s, checks.id) %s (?::%s, ?)", sort.expr, direction, sort.cast)
+		args = append(args, cursor.Value, cursor.CheckId)
+	}
+
+	// one more than the limit, to tell if there is another page
+	dbcs := []struct {
+		dbCheck
+		SortValue string `db:"sort_value"`
+	}{}
+	err := sqlx.Select(q, &dbcs, q.Rebind(fmt.Sprintf(
+		"SELECT id, COALESCE(interval, 30) AS interval, checks.customer_id, name, execution_group_id, min_failing_count, min_failing_time, COALESCE(target_name, '') AS target_name, target_type, target_id, COALESCE(state_name, 'INVALID') AS state_name, (%s)::text AS sort_value%s ORDER BY %s %s, checks.id %s LIMIT %d",
+		sort.expr, from, sort.expr, order, order, query.Limit+1,
+	)), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dbcs) > query.Limit {
+		dbcs = dbcs[:query.Limit]
+		last := dbcs[len(dbcs)-1]
+		list.NextCursor = (&checkCursor{
+			SortBy:  query.SortBy,
+			Value:   last.SortValue,
+			CheckId: last.Check.Id,
+		}).encode()
+	}
+
+	list.Checks = make([]*schema.Check, len(dbcs))
+	for i, c := range dbcs {
+		check := c.Check
+		check.Target = c.Target
+		list.Checks[i] = check
+	}
+
+	if err := getChecksDetails(q, list.Checks); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(list.Checks))
+	for i, check := range list.Checks {
+		ids[i] = check.Id
+	}
+
+	list.Labels, err = q.GetCheckLabels(customerId, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckListQueryValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	query := &CheckListQuery{}
+	assert.NoError(query.Validate())
+	assert.Equal(SortByName, query.SortBy)
+	assert.Equal(DefaultCheckListLimit, query.Limit)
+
+	query = &CheckListQuery{Limit: 5000}
+	assert.NoError(query.Validate())
+	assert.Equal(MaxCheckListLimit, query.Limit)
+
+	assert.Error((&CheckListQuery{SortBy: "customer_id"}).Validate())
+	assert.Equal(ErrInvalidCursor, (&CheckListQuery{Cursor: "nope!"}).Validate())
+
+	cursor := (&checkCursor{SortBy: SortByState, Value: "OK", CheckId: "check-id-1"}).encode()
+	assert.NoError((&CheckListQuery{SortBy: SortByState, Cursor: cursor}).Validate())
+
+	// a cursor is only good for the sort it came from
+	assert.Equal(ErrInvalidCursor, (&CheckListQuery{SortBy: SortByName, Cursor: cursor}).Validate())
+
+	decoded, err := decodeCheckCursor(cursor)
+	assert.NoError(err)
+	assert.Equal("check-id-1", decoded.CheckId)
+}
+
+func TestListChecks(t *testing.T) {
+	assert := assert.New(t)
+
+	withCheckFixtures(func(cs CheckStore) {
+		customerId := testutil.Checks["1"].CustomerId
+
+		err := cs.PutState(&checks.State{
+			CheckId:       testutil.Checks["2"].Id,
+			CustomerId:    customerId,
+			Id:            checks.StateFail,
+			State:         checks.StateFail.String(),
+			TimeEntered:   time.Now(),
+			LastUpdated:   time.Now(),
+			FailingCount:  3,
+			ResponseCount: 3,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		list, err := cs.ListChecks(customerId, &CheckListQuery{Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(2, list.Total)
+		assert.Len(list.Checks, 1)
+		assert.Equal("check 1", list.Checks[0].Name)
+		assert.NotEmpty(list.NextCursor)
+
+		list, err = cs.ListChecks(customerId, &CheckListQuery{Limit: 1, Cursor: list.NextCursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(list.Checks, 1)
+		assert.Equal("check 2", list.Checks[0].Name)
+		assert.Empty(list.NextCursor)
+
+		list, err = cs.ListChecks(customerId, &CheckListQuery{SortBy: SortByState, Descending: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(list.Checks, 2)
+		assert.Equal("check 2", list.Checks[0].Name)
+
+		for _, query := range []*CheckListQuery{
+			{State: "fail"},
+			{TargetType: "elb-2"},
+			{TargetId: "target-id-2"},
+			{Name: "K 2"},
+			{MinFailingCount: 1},
+		} {
+			list, err = cs.ListChecks(customerId, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(1, list.Total)
+			if assert.Len(list.Checks, 1) {
+				assert.Equal(testutil.Checks["2"].Id, list.Checks[0].Id)
+			}
+		}
+
+		list, err = cs.ListChecks(customerId, &CheckListQuery{MaxFailingCount: 1, Name: "check"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(1, list.Total)
+
+		list, err = cs.ListChecks(customerId, &CheckListQuery{Name: "100%"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(0, list.Total)
+	})
+}
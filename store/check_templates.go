@@ -0,0 +1,145 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/opsee/cats/checks/bundle"
+	"github.com/opsee/cats/checks/templates"
+)
+
+type dbCheckTemplate struct {
+	Id         string `db:"id"`
+	CustomerId string `db:"customer_id"`
+	Name       string `db:"name"`
+	Spec       []byte `db:"spec"`
+}
+
+// checkTemplateSpec is how a template's check and target selector are kept
+// in the spec column.
+type checkTemplateSpec struct {
+	Check   *bundle.Check            `json:"check"`
+	Targets templates.TargetSelector `json:"targets"`
+}
+
+func (t *dbCheckTemplate) template() (*templates.Template, error) {
+	spec := &checkTemplateSpec{}
+	if err := json.Unmarshal(t.Spec, spec); err != nil {
+		return nil, err
+	}
+
+	return &templates.Template{
+		Id:         t.Id,
+		CustomerId: t.CustomerId,
+		Name:       t.Name,
+		Check:      spec.Check,
+		Targets:    spec.Targets,
+	}, nil
+}
+
+// GetCheckTemplates returns a customer's check templates, by name.
+func (q *checkStore) GetCheckTemplates(customerId string) ([]*templates.Template, error) {
+	var rows []*dbCheckTemplate
+	err := sqlx.Select(q, &rows, "SELECT id, customer_id, name, spec FROM check_templates WHERE customer_id = $1 AND deleted = false ORDER BY name, id", customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpls := make([]*templates.Template, len(rows))
+	for i, row := range rows {
+		if tmpls[i], err = row.template(); err != nil {
+			return nil, err
+		}
+	}
+
+	return tmpls, nil
+}
+
+// GetCheckTemplate returns a customer's check template, or sql.ErrNoRows if
+// it doesn't exist.
+func (q *checkStore) GetCheckTemplate(customerId, templateId string) (*templates.Template, error) {
+	row := &dbCheckTemplate{}
+	err := sqlx.Get(q, row, "SELECT id, customer_id, name, spec FROM check_templates WHERE id = $1 AND customer_id = $2 AND deleted = false", templateId, customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return row.template()
+}
+
+// PutCheckTemplate creates a template, filling in its id, or replaces it if
+// it has one. Replacing a template that doesn't exist returns sql.ErrNoRows.
+func (q *checkStore) PutCheckTemplate(t *templates.Template) error {
+	spec, err := json.Marshal(&checkTemplateSpec{Check: t.Check, Targets: t.Targets})
+	if err != nil {
+		return err
+	}
+
+	if t.Id == "" {
+		return sqlx.Get(q, &t.Id, "INSERT INTO check_templates (customer_id, name, spec) VALUES ($1, $2, $3) RETURNING id", t.CustomerId, t.Name, spec)
+	}
+
+	return sqlx.Get(q, &t.Id, "UPDATE check_templates SET name = $3, spec = $4 WHERE id = $1 AND customer_id = $2 AND deleted = false RETURNING id", t.Id, t.CustomerId, t.Name, spec)
+}
+
+// DeleteCheckTemplate deletes a template. Checks made from it are kept, and
+// no longer point at it.
+func (q *checkStore) DeleteCheckTemplate(customerId, templateId string) error {
+	return q.withTx(func(q *checkStore) error {
+		res, err := q.Exec("UPDATE check_templates SET deleted = true WHERE id = $1 AND customer_id = $2 AND deleted = false", templateId, customerId)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return sql.ErrNoRows
+		}
+
+		_, err = q.Exec("UPDATE checks SET template_id = NULL WHERE template_id = $1 AND customer_id = $2", templateId, customerId)
+		return err
+	})
+}
+
+// GetTemplateCheckIds returns the ids of the checks made from a template.
+func (q *checkStore) GetTemplateCheckIds(customerId, templateId string) ([]string, error) {
+	ids := []string{}
+	err := sqlx.Select(q, &ids, "SELECT id FROM checks WHERE template_id = $1 AND customer_id = $2 AND deleted = false ORDER BY id", templateId, customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GetDerivedCheckIds returns the ids of a customer's checks made from any
+// template.
+func (q *checkStore) GetDerivedCheckIds(customerId string) ([]string, error) {
+	ids := []string{}
+	err := sqlx.Select(q, &ids, "SELECT id FROM checks WHERE template_id IS NOT NULL AND customer_id = $1 AND deleted = false ORDER BY id", customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// SetCheckTemplate records that checks were made from a template.
+func (q *checkStore) SetCheckTemplate(customerId, templateId string, checkIds []string) error {
+	if len(checkIds) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In("UPDATE checks SET template_id = ? WHERE customer_id = ? AND id IN (?)", templateId, customerId, checkIds)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(q.Rebind(query), args...)
+	return err
+}
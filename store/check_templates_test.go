@@ -0,0 +1,57 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/opsee/cats/checks/bundle"
+	"github.com/opsee/cats/checks/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTemplates(t *testing.T) {
+	assert := assert.New(t)
+
+	withCheckFixtures(func(cs CheckStore) {
+		customerId := "11111111-1111-1111-1111-111111111111"
+
+		tmpl := &templates.Template{
+			CustomerId: customerId,
+			Name:       "elb health",
+			Check:      &bundle.Check{Name: "{{.Target.Name}} health", Http: &bundle.HttpSpec{Protocol: "http", Verb: "GET", Port: 80, Path: "/"}},
+			Targets:    templates.TargetSelector{Type: "elb"},
+		}
+		assert.NoError(cs.PutCheckTemplate(tmpl))
+		assert.NotEmpty(tmpl.Id)
+
+		tmpl.Targets.Ids = []string{"web-elb"}
+		assert.NoError(cs.PutCheckTemplate(tmpl))
+
+		got, err := cs.GetCheckTemplate(customerId, tmpl.Id)
+		assert.NoError(err)
+		assert.Equal(tmpl, got)
+
+		check := newTestCheck()
+		assert.NoError(cs.CreateCheck(check))
+		assert.NoError(cs.SetCheckTemplate(customerId, tmpl.Id, []string{check.Id}))
+
+		ids, err := cs.GetTemplateCheckIds(customerId, tmpl.Id)
+		assert.NoError(err)
+		assert.Equal([]string{check.Id}, ids)
+
+		list, err := cs.GetCheckTemplates(customerId)
+		assert.NoError(err)
+		assert.Len(list, 1)
+
+		assert.NoError(cs.DeleteCheckTemplate(customerId, tmpl.Id))
+		assert.Equal(sql.ErrNoRows, cs.DeleteCheckTemplate(customerId, tmpl.Id))
+
+		_, err = cs.GetCheckTemplate(customerId, tmpl.Id)
+		assert.Equal(sql.ErrNoRows, err)
+
+		// the check is kept, and no longer made from the template
+		ids, err = cs.GetTemplateCheckIds(customerId, tmpl.Id)
+		assert.NoError(err)
+		assert.Empty(ids)
+	})
+}
@@ -0,0 +1,317 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/opsee/basic/schema"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+)
+
+// relationships are the assertion relationships the relationship_type enum
+// allows.
+var relationships = map[string]bool{
+	"equal":       true,
+	"notEqual":    true,
+	"empty":       true,
+	"notEmpty":    true,
+	"contain":     true,
+	"notContain":  true,
+	"regExp":      true,
+	"greaterThan": true,
+	"lessThan":    true,
+}
+
+// ValidateCheck checks that a check can be written: it has a name, a
+// customer, an execution group, a target of a known type, a valid spec and
+// valid assertions.
+func ValidateCheck(check *schema.Check) error {
+	if check == nil {
+		return errors.New("check is required")
+	}
+
+	if check.CustomerId == "" {
+		return errors.New("check is missing customer_id")
+	}
+
+	if check.Name == "" {
+		return errors.New("check is missing name")
+	}
+
+	if check.ExecutionGroupId == "" {
+		return errors.New("check is missing execution_group_id")
+	}
+
+	if check.MinFailingCount < 0 || check.MinFailingTime < 0 || check.Interval < 0 {
+		return errors.New("check thresholds and interval can't be negative")
+	}
+
+	if check.Target == nil {
+		return errors.New("check is missing target")
+	}
+
+	if check.Target.Id == "" {
+		return errors.New("check target is missing id")
+	}
+
+	// schema.Target.Validate binary searches an unsorted list
+	validTarget := false
+	for _, t := range schema.TargetTypes {
+		if t == check.Target.Type {
+			validTarget = true
+			break
+		}
+	}
+
+	if !validTarget {
+		return fmt.Errorf("invalid target type: %s", check.Target.Type)
+	}
+
+	if _, err := checkSpecJSON(check); err != nil {
+		return err
+	}
+
+	for _, a := range check.Assertions {
+		if a.Key == "" {
+			return errors.New("assertion is missing key")
+		}
+
+		if !relationships[a.Relationship] {
+			return fmt.Errorf("invalid assertion relationship: %s", a.Relationship)
+		}
+	}
+
+	return nil
+}
+
+// checkSpecJSON validates a check's spec, from Spec or CheckSpec, and returns
+// it as the {"type_url": "", "value": {}} json stored in checks.check_spec.
+func checkSpecJSON(check *schema.Check) ([]byte, error) {
+	spec := check.Spec
+	if spec == nil && check.CheckSpec != nil {
+		typed, err := opsee_types.UnmarshalAny(check.CheckSpec)
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := typed.(type) {
+		case *schema.HttpCheck:
+			spec = &schema.Check_HttpCheck{HttpCheck: t}
+		case *schema.CloudWatchCheck:
+			spec = &schema.Check_CloudwatchCheck{CloudwatchCheck: t}
+		}
+	}
+
+	var (
+		typeUrl string
+		value   interface{}
+	)
+
+	switch t := spec.(type) {
+	case *schema.Check_HttpCheck:
+		h := t.HttpCheck
+		if h == nil || h.Path == "" || h.Protocol == "" || h.Port == 0 || h.Verb == "" {
+			return nil, errors.New("http check spec requires path, protocol, port and verb")
+		}
+
+		for _, header := range h.Headers {
+			if header.Name == "" {
+				return nil, errors.New("http check spec header is missing name")
+			}
+		}
+
+		typeUrl, value = "HttpCheck", h
+	case *schema.Check_CloudwatchCheck:
+		c := t.CloudwatchCheck
+		if c == nil || len(c.Metrics) == 0 {
+			return nil, errors.New("cloudwatch check spec requires metrics")
+		}
+
+		for _, m := range c.Metrics {
+			if m.Namespace == "" || m.Name == "" {
+				return nil, errors.New("cloudwatch check spec metric requires namespace and name")
+			}
+		}
+
+		typeUrl, value = "CloudWatchCheck", c
+	default:
+		return nil, errors.New("check is missing spec")
+	}
+
+	return json.Marshal(struct {
+		TypeUrl string      `json:"type_url"`
+		Value   interface{} `json:"value"`
+	}{typeUrl, value})
+}
+
+// withTx runs txfun with a store in a transaction, or with this store if it
+// is already in one.
+func (q *checkStore) withTx(txfun func(*checkStore) error) error {
+	db, ok := q.Ext.(*sqlx.DB)
+	if !ok {
+		return txfun(q)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if err := txfun(&checkStore{tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// WithTX runs txfun with a check store and a notification store in one
+// transaction, which is rolled back if txfun returns an error.
+func (q *checkStore) WithTX(txfun func(CheckStore, NotificationStore) error) error {
+	return q.withTx(func(q *checkStore) error {
+		return txfun(q, NewNotificationStore(q.Ext))
+	})
+}
+
+// GetTakenCheckIds returns those of ids that are used by a check of any
+// customer, deleted or not, so that a new check can't be given them.
+func (q *checkStore) GetTakenCheckIds(ids []string) ([]string, error) {
+	taken := []string{}
+	if len(ids) == 0 {
+		return taken, nil
+	}
+
+	if err := selectIn(q, &taken, "SELECT id FROM checks WHERE id IN (?) ORDER BY id", ids); err != nil {
+		return nil, err
+	}
+
+	return taken, nil
+}
+
+// CreateCheck validates and inserts a check and its assertions. A check
+// without an id is given one.
+func (q *checkStore) CreateCheck(check *schema.Check) error {
+	if err := ValidateCheck(check); err != nil {
+		return err
+	}
+
+	spec, err := checkSpecJSON(check)
+	if err != nil {
+		return err
+	}
+
+	return q.withTx(func(q *checkStore) error {
+		err := sqlx.Get(
+			q,
+			&check.Id,
+			`INSERT INTO checks (id, interval, customer_id, name, execution_group_id, min_failing_count, min_failing_time, target_name, target_type, target_id, check_spec)
+			 VALUES (COALESCE(NULLIF($1, ''), uuid_generate_v4()::text), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+			check.Id, checkInterval(check), check.CustomerId, check.Name, check.ExecutionGroupId, check.MinFailingCount, check.MinFailingTime,
+			check.Target.Name, check.Target.Type, check.Target.Id, string(spec),
+		)
+		if err != nil {
+			return err
+		}
+
+		return putAssertions(q, check)
+	})
+}
+
+// UpdateCheck validates and replaces a check and its assertions. If its
+// failing thresholds change, its state and result memos are reset, so it
+// starts over as OK. It returns sql.ErrNoRows if the check doesn't exist or
+// was deleted.
+func (q *checkStore) UpdateCheck(check *schema.Check) error {
+	if err := ValidateCheck(check); err != nil {
+		return err
+	}
+
+	spec, err := checkSpecJSON(check)
+	if err != nil {
+		return err
+	}
+
+	return q.withTx(func(q *checkStore) error {
+		old := &schema.Check{}
+		err := sqlx.Get(q, old, "SELECT min_failing_count, min_failing_time FROM checks WHERE id = $1 AND customer_id = $2 AND deleted = false FOR UPDATE", check.Id, check.CustomerId)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.Exec(
+			`UPDATE checks SET interval = $3, name = $4, execution_group_id = $5, min_failing_count = $6, min_failing_time = $7,
+			 target_name = $8, target_type = $9, target_id = $10, check_spec = $11, updated_at = now() WHERE id = $1 AND customer_id = $2`,
+			check.Id, check.CustomerId, checkInterval(check), check.Name, check.ExecutionGroupId, check.MinFailingCount, check.MinFailingTime,
+			check.Target.Name, check.Target.Type, check.Target.Id, string(spec),
+		)
+		if err != nil {
+			return err
+		}
+
+		if old.MinFailingCount != check.MinFailingCount || old.MinFailingTime != check.MinFailingTime {
+			if err := resetCheckState(q, check.CustomerId, check.Id); err != nil {
+				return err
+			}
+		}
+
+		return putAssertions(q, check)
+	})
+}
+
+// DeleteCheck marks a check deleted. It returns sql.ErrNoRows if the check
+// doesn't exist or was already deleted.
+func (q *checkStore) DeleteCheck(customerId, checkId string) error {
+	res, err := q.Exec("UPDATE checks SET deleted = true, updated_at = now() WHERE id = $1 AND customer_id = $2 AND deleted = false", checkId, customerId)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func checkInterval(check *schema.Check) int32 {
+	if check.Interval == 0 {
+		return 30
+	}
+
+	return check.Interval
+}
+
+func putAssertions(q sqlx.Ext, check *schema.Check) error {
+	if _, err := q.Exec("DELETE FROM assertions WHERE check_id = $1 AND customer_id = $2", check.Id, check.CustomerId); err != nil {
+		return err
+	}
+
+	for _, a := range check.Assertions {
+		_, err := q.Exec(
+			"INSERT INTO assertions (check_id, customer_id, key, value, relationship, operand) VALUES ($1, $2, $3, $4, $5, $6)",
+			check.Id, check.CustomerId, a.Key, a.Value, a.Relationship, a.Operand,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func resetCheckState(q sqlx.Ext, customerId, checkId string) error {
+	if _, err := q.Exec("DELETE FROM check_states WHERE check_id = $1 AND customer_id = $2", checkId, customerId); err != nil {
+		return err
+	}
+
+	_, err := q.Exec("DELETE FROM check_state_memos WHERE check_id = $1 AND customer_id = $2", checkId, customerId)
+	return err
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/checks"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCheck() *schema.Check {
+	return &schema.Check{
+		CustomerId:       "11111111-1111-1111-1111-111111111111",
+		ExecutionGroupId: "11111111-1111-1111-1111-111111111111",
+		Name:             "new check",
+		MinFailingCount:  1,
+		MinFailingTime:   90,
+		Target: &schema.Target{
+			Name: "new-target",
+			Type: "sg",
+			Id:   "sg-123456",
+		},
+		Spec: &schema.Check_HttpCheck{HttpCheck: &schema.HttpCheck{
+			Path:     "/health",
+			Protocol: "http",
+			Port:     80,
+			Verb:     "GET",
+		}},
+		Assertions: []*schema.Assertion{
+			{Key: "code", Relationship: "equal", Operand: "200"},
+		},
+	}
+}
+
+func TestValidateCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(ValidateCheck(newTestCheck()))
+
+	check := newTestCheck()
+	check.Target.Type = "ecs_service"
+	assert.NoError(ValidateCheck(check))
+
+	check.Target.Type = "elb-1"
+	assert.Error(ValidateCheck(check))
+
+	check = newTestCheck()
+	check.GetHttpCheck().Port = 0
+	assert.Error(ValidateCheck(check))
+
+	check = newTestCheck()
+	check.Spec = &schema.Check_CloudwatchCheck{CloudwatchCheck: &schema.CloudWatchCheck{}}
+	assert.Error(ValidateCheck(check))
+
+	check = newTestCheck()
+	check.Spec = nil
+	assert.Error(ValidateCheck(check))
+
+	check = newTestCheck()
+	check.Assertions[0].Relationship = "sortOf"
+	assert.Error(ValidateCheck(check))
+
+	spec, err := checkSpecJSON(newTestCheck())
+	assert.NoError(err)
+
+	// it is read back the way checks are
+	typed, err := schema.UnmarshalCrappyCheckSpecAnyJSON(spec)
+	assert.NoError(err)
+	assert.Equal("/health", typed.(*schema.HttpCheck).Path)
+}
+
+func TestCheckWrites(t *testing.T) {
+	assert := assert.New(t)
+
+	withCheckFixtures(func(cs CheckStore) {
+		user := &schema.User{CustomerId: "11111111-1111-1111-1111-111111111111"}
+
+		check := newTestCheck()
+		assert.NoError(cs.CreateCheck(check))
+		assert.NotEmpty(check.Id)
+
+		created, err := cs.GetCheck(user, check.Id)
+		assert.NoError(err)
+		assert.Equal("new check", created.Name)
+		assert.Equal("/health", created.GetHttpCheck().Path)
+		assert.Len(created.Assertions, 1)
+
+		assert.NoError(cs.PutState(&checks.State{
+			CheckId:     check.Id,
+			CustomerId:  check.CustomerId,
+			Id:          checks.StateFail,
+			State:       checks.StateFail.String(),
+			TimeEntered: time.Now(),
+			LastUpdated: time.Now(),
+		}))
+
+		// the state is kept while the thresholds are
+		check.Name = "renamed check"
+		check.Assertions = nil
+		assert.NoError(cs.UpdateCheck(check))
+
+		updated, err := cs.GetCheck(user, check.Id)
+		assert.NoError(err)
+		assert.Equal("renamed check", updated.Name)
+		assert.Len(updated.Assertions, 0)
+		assert.Equal(checks.StateFail.String(), updated.State)
+
+		check.MinFailingCount = 2
+		assert.NoError(cs.UpdateCheck(check))
+
+		updated, err = cs.GetCheck(user, check.Id)
+		assert.NoError(err)
+		assert.Equal(int32(2), updated.MinFailingCount)
+		assert.Equal("INVALID", updated.State)
+
+		assert.NoError(cs.DeleteCheck(check.CustomerId, check.Id))
+		assert.Equal(sql.ErrNoRows, cs.DeleteCheck(check.CustomerId, check.Id))
+		assert.Equal(sql.ErrNoRows, cs.UpdateCheck(check))
+
+		_, err = cs.GetCheck(user, check.Id)
+		assert.Equal(sql.ErrNoRows, err)
+	})
+}
@@ -2,7 +2,9 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -15,6 +17,12 @@ type dbCheck struct {
 	*schema.Target
 }
 
+type dbRedactionRule struct {
+	*checks.RedactionRule
+	HeadersJSON      []byte `db:"headers"`
+	BodyPatternsJSON []byte `db:"body_patterns"`
+}
+
 type checkStore struct {
 	sqlx.Ext
 }
@@ -132,8 +140,14 @@ func (q *checkStore) GetLiveBastions(customerID, checkID string) (bastions []str
 // CreateStateTransitionLogEntry creates and stores a StateTransitionLogEntry, returning the created
 // log entry or an error.
 func (q *checkStore) CreateStateTransitionLogEntry(checkId, customerId string, fromState, toState checks.StateId) (*checks.StateTransitionLogEntry, error) {
+	return q.CreateStateTransitionLogEntryAt(checkId, customerId, fromState, toState, time.Now())
+}
+
+// CreateStateTransitionLogEntryAt creates a transition log entry for a
+// transition that happened at createdAt, e.g. when replaying results.
+func (q *checkStore) CreateStateTransitionLogEntryAt(checkId, customerId string, fromState, toState checks.StateId, createdAt time.Time) (*checks.StateTransitionLogEntry, error) {
 	var logEntryID int
-	err := q.QueryRowx("INSERT INTO check_state_transitions (check_id, customer_id, from_state, to_state) VALUES ($1, $2, $3, $4) RETURNING id", checkId, customerId, fromState, toState).Scan(&logEntryID)
+	err := q.QueryRowx("INSERT INTO check_state_transitions (check_id, customer_id, from_state, to_state, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id", checkId, customerId, fromState, toState, createdAt).Scan(&logEntryID)
 	if err != nil {
 		return nil, err
 	}
@@ -156,6 +170,19 @@ func (q *checkStore) GetCheckCount(customerId string) (int32, error) {
 	return count, nil
 }
 
+// GetCheckStates returns the current state of each of a customer's checks
+// that have one.
+func (q *checkStore) GetCheckStates(customerId string) ([]*checks.State, error) {
+	var states []*checks.State
+
+	err := sqlx.Select(q, &states, "SELECT states.check_id, states.customer_id, states.state_id, states.state_name, states.time_entered, states.last_updated, states.failing_count, states.response_count FROM check_states AS states JOIN checks ON (checks.id = states.check_id) WHERE states.customer_id = $1 AND checks.deleted = false", customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
 // GetStateTransitionLogEntries returns state transition log entries between a start and end time
 // log entry or an error.
 func (q *checkStore) GetCheckStateTransitionLogEntries(checkId, customerId string, from, to time.Time) ([]*checks.StateTransitionLogEntry, error) {
@@ -181,6 +208,56 @@ func (q *checkStore) GetCheckStateTransitionLogEntry(checkId, customerId string,
 	return entry, nil
 }
 
+// GetCheckSpec returns the typed spec (*schema.HttpCheck or
+// *schema.CloudWatchCheck) of a check that hasn't been deleted. Checks without
+// a spec return a nil spec.
+func (q *checkStore) GetCheckSpec(customerId, checkId string) (interface{}, error) {
+	var specStr sql.NullString
+	err := sqlx.Get(q, &specStr, "SELECT check_spec FROM checks WHERE id=$1 AND customer_id=$2 AND deleted=false", checkId, customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !specStr.Valid {
+		return nil, nil
+	}
+
+	return schema.UnmarshalCrappyCheckSpecAnyJSON([]byte(specStr.String))
+}
+
+// GetRedactionRules returns the redaction rules that apply to a check: those
+// scoped to the check itself and those scoped to the whole customer.
+func (q *checkStore) GetRedactionRules(customerId, checkId string) ([]*checks.RedactionRule, error) {
+	return q.selectRedactionRules("SELECT id, customer_id, COALESCE(check_id, '') AS check_id, headers, body_patterns, max_body_size FROM redaction_rules WHERE customer_id = $1 AND (check_id IS NULL OR check_id = $2)", customerId, checkId)
+}
+
+// GetCustomerRedactionRules returns all of a customer's redaction rules,
+// whatever their scope.
+func (q *checkStore) GetCustomerRedactionRules(customerId string) ([]*checks.RedactionRule, error) {
+	return q.selectRedactionRules("SELECT id, customer_id, COALESCE(check_id, '') AS check_id, headers, body_patterns, max_body_size FROM redaction_rules WHERE customer_id = $1", customerId)
+}
+
+func (q *checkStore) selectRedactionRules(query string, args ...interface{}) ([]*checks.RedactionRule, error) {
+	var dbrs []*dbRedactionRule
+	err := sqlx.Select(q, &dbrs, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*checks.RedactionRule, len(dbrs))
+	for i, r := range dbrs {
+		if err := json.Unmarshal(r.HeadersJSON, &r.Headers); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(r.BodyPatternsJSON, &r.BodyPatterns); err != nil {
+			return nil, err
+		}
+		rules[i] = r.RedactionRule
+	}
+
+	return rules, nil
+}
+
 // GetChecks gets all checks for a customer
 func (q *checkStore) GetChecks(user *schema.User) (checks []*schema.Check, err error) {
 	dbcs := []dbCheck{}
@@ -194,21 +271,63 @@ func (q *checkStore) GetChecks(user *schema.User) (checks []*schema.Check, err e
 		check := c.Check
 		check.Target = c.Target
 		checks[i] = check
+	}
 
-		err = sqlx.Select(q, &check.Assertions, `SELECT key, COALESCE(value, '') AS value, COALESCE(operand, '') AS operand, relationship FROM assertions WHERE check_id=$1 AND customer_id=$2`, check.Id, check.CustomerId)
-		if err != nil {
-			return nil, err
+	if err := getChecksDetails(q, checks); err != nil {
+		return nil, err
+	}
+
+	return checks, nil
+}
+
+// getChecksDetails fills in the assertions, specs and notifications of
+// checks, in one query each per maxInValues checks.
+func getChecksDetails(q sqlx.Ext, checks []*schema.Check) error {
+	if len(checks) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(checks))
+	byId := make(map[string]*schema.Check, len(checks))
+	for i, check := range checks {
+		ids[i] = check.Id
+		byId[check.Id] = check
+	}
+
+	assertions := []struct {
+		CheckId    string `db:"check_id"`
+		CustomerId string `db:"customer_id"`
+		schema.Assertion
+	}{}
+	if err := selectIn(q, &assertions, `SELECT check_id, customer_id, key, COALESCE(value, '') AS value, COALESCE(operand, '') AS operand, relationship FROM assertions WHERE check_id IN (?)`, ids); err != nil {
+		return err
+	}
+
+	for i := range assertions {
+		a := &assertions[i]
+		if check, ok := byId[a.CheckId]; ok && check.CustomerId == a.CustomerId {
+			check.Assertions = append(check.Assertions, &a.Assertion)
 		}
+	}
 
-		var specStr string
-		err = sqlx.Get(q, &specStr, "SELECT check_spec FROM checks WHERE id=$1 AND customer_id=$2", check.Id, check.CustomerId)
-		if err != nil {
-			return nil, err
+	specs := []struct {
+		Id         string `db:"id"`
+		CustomerId string `db:"customer_id"`
+		Spec       string `db:"check_spec"`
+	}{}
+	if err := selectIn(q, &specs, "SELECT id, customer_id, check_spec FROM checks WHERE id IN (?)", ids); err != nil {
+		return err
+	}
+
+	for _, spec := range specs {
+		check, ok := byId[spec.Id]
+		if !ok || check.CustomerId != spec.CustomerId {
+			continue
 		}
 
-		typedCheck, err := schema.UnmarshalCrappyCheckSpecAnyJSON([]byte(specStr))
+		typedCheck, err := schema.UnmarshalCrappyCheckSpecAnyJSON([]byte(spec.Spec))
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		switch t := typedCheck.(type) {
@@ -217,45 +336,95 @@ func (q *checkStore) GetChecks(user *schema.User) (checks []*schema.Check, err e
 		case *schema.CloudWatchCheck:
 			check.Spec = &schema.Check_CloudwatchCheck{CloudwatchCheck: t}
 		default:
-			return nil, fmt.Errorf("Unsupported check spec type: %v", check.CheckSpec)
+			return fmt.Errorf("Unsupported check spec type: %v", check.CheckSpec)
 		}
 	}
-	return checks, nil
+
+	notifications := []struct {
+		CheckId    string `db:"check_id"`
+		CustomerId string `db:"customer_id"`
+		schema.Notification
+	}{}
+	if err := selectIn(q, &notifications, "SELECT check_id, customer_id, type::text AS type, value FROM notifications WHERE check_id IN (?) ORDER BY id", ids); err != nil {
+		return err
+	}
+
+	for _, check := range checks {
+		check.Notifications = []*schema.Notification{}
+	}
+
+	for i := range notifications {
+		n := &notifications[i]
+		if check, ok := byId[n.CheckId]; ok && check.CustomerId == n.CustomerId {
+			check.Notifications = append(check.Notifications, &n.Notification)
+		}
+	}
+
+	return nil
 }
 
-// GetCheck gets a single check for a customer
-func (q *checkStore) GetCheck(user *schema.User, checkId string) (check *schema.Check, err error) {
-	bullshit := &dbCheck{}
-	err = q.QueryRowx("SELECT id, COALESCE(interval, 30) AS interval, checks.customer_id, name, execution_group_id, min_failing_count, min_failing_time, COALESCE(target_name, '') AS target_name, target_type, target_id, COALESCE(state_name, 'INVALID') AS state_name FROM checks LEFT OUTER JOIN check_states ON (checks.id = check_states.check_id) WHERE id=$1 AND checks.customer_id=$2 AND deleted=false", checkId, user.CustomerId).StructScan(bullshit)
-	if err != nil {
-		return nil, err
+// maxInValues is the most values selectIn binds in one query, well under
+// Postgres's limit of 65535 parameters.
+var maxInValues = 5000
+
+// selectIn is sqlx.Select for a query with an IN (?) clause, whose values are
+// its []string argument. They are queried maxInValues at a time, and dest, a
+// pointer to a slice, gets the rows of every query.
+func selectIn(q sqlx.Ext, dest interface{}, query string, args ...interface{}) error {
+	in := -1
+	for i, arg := range args {
+		if _, ok := arg.([]string); ok {
+			in = i
+			break
+		}
 	}
 
-	check = bullshit.Check
-	check.Target = bullshit.Target
+	if in < 0 || len(args[in].([]string)) <= maxInValues {
+		return selectInBatch(q, dest, query, args...)
+	}
+
+	values := args[in].([]string)
+	rows := reflect.ValueOf(dest).Elem()
+	for start := 0; start < len(values); start += maxInValues {
+		end := start + maxInValues
+		if end > len(values) {
+			end = len(values)
+		}
+
+		batchArgs := append([]interface{}{}, args...)
+		batchArgs[in] = values[start:end]
+
+		batch := reflect.New(rows.Type())
+		if err := selectInBatch(q, batch.Interface(), query, batchArgs...); err != nil {
+			return err
+		}
+		rows.Set(reflect.AppendSlice(rows, batch.Elem()))
+	}
+
+	return nil
+}
 
-	var specStr string
-	err = sqlx.Get(q, &specStr, "SELECT check_spec FROM checks WHERE id=$1 AND customer_id=$2", checkId, user.CustomerId)
+func selectInBatch(q sqlx.Ext, dest interface{}, query string, args ...interface{}) error {
+	query, args, err := sqlx.In(query, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	typedCheck, err := schema.UnmarshalCrappyCheckSpecAnyJSON([]byte(specStr))
+	return sqlx.Select(q, dest, q.Rebind(query), args...)
+}
+
+// GetCheck gets a single check for a customer
+func (q *checkStore) GetCheck(user *schema.User, checkId string) (check *schema.Check, err error) {
+	bullshit := &dbCheck{}
+	err = q.QueryRowx("SELECT id, COALESCE(interval, 30) AS interval, checks.customer_id, name, execution_group_id, min_failing_count, min_failing_time, COALESCE(target_name, '') AS target_name, target_type, target_id, COALESCE(state_name, 'INVALID') AS state_name FROM checks LEFT OUTER JOIN check_states ON (checks.id = check_states.check_id) WHERE id=$1 AND checks.customer_id=$2 AND deleted=false", checkId, user.CustomerId).StructScan(bullshit)
 	if err != nil {
 		return nil, err
 	}
 
-	switch t := typedCheck.(type) {
-	case *schema.HttpCheck:
-		check.Spec = &schema.Check_HttpCheck{HttpCheck: t}
-	case *schema.CloudWatchCheck:
-		check.Spec = &schema.Check_CloudwatchCheck{CloudwatchCheck: t}
-	default:
-		return nil, fmt.Errorf("Unsupported check spec type: %v", check.CheckSpec)
-	}
+	check = bullshit.Check
+	check.Target = bullshit.Target
 
-	err = sqlx.Select(q, &check.Assertions, `SELECT key, COALESCE(value, '') AS value, COALESCE(operand, '') AS operand, relationship FROM assertions WHERE check_id=$1 AND customer_id=$2`, check.Id, check.CustomerId)
-	if err != nil {
+	if err := getChecksDetails(q, []*schema.Check{check}); err != nil {
 		return nil, err
 	}
 
@@ -121,6 +121,44 @@ func TestCheckCount(t *testing.T) {
 	})
 }
 
+func TestGetCheckStates(t *testing.T) {
+	assert := assert.New(t)
+
+	withCheckFixtures(func(cs CheckStore) {
+		check := testutil.Checks["1"]
+		err := cs.PutState(&checks.State{
+			CheckId:       check.Id,
+			CustomerId:    check.CustomerId,
+			Id:            checks.StateFailWait,
+			State:         checks.StateFailWait.String(),
+			TimeEntered:   time.Now(),
+			LastUpdated:   time.Now(),
+			FailingCount:  1,
+			ResponseCount: 3,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		states, err := cs.GetCheckStates(check.CustomerId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(states, 1)
+		assert.Equal(check.Id, states[0].CheckId)
+		assert.Equal(checks.StateFailWait, states[0].Id)
+		assert.EqualValues(1, states[0].FailingCount)
+
+		states, err = cs.GetCheckStates("11111111-1111-1111-1111-111111111222")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(states, 0)
+	})
+}
+
 func TestGetCheck(t *testing.T) {
 	assert := assert.New(t)
 
@@ -151,15 +189,101 @@ func TestGetChecks(t *testing.T) {
 		for _, c := range checks {
 			assert.NotNil(c.Spec)
 		}
+
+		// details are got in batches
+		defer func(n int) { maxInValues = n }(maxInValues)
+		maxInValues = 1
+
+		batched, err := cs.GetChecks(&schema.User{
+			CustomerId: "11111111-1111-1111-1111-111111111111",
+		})
+		assert.NoError(err)
+		assert.Equal(checks, batched)
+	})
+}
+
+func TestGetRedactionRules(t *testing.T) {
+	assert := assert.New(t)
+
+	withCheckFixtures(func(cs CheckStore) {
+		customerId := "11111111-1111-1111-1111-111111111111"
+		q := cs.(*checkStore)
+		sqlx.MustExec(q, "DELETE FROM redaction_rules")
+		sqlx.MustExec(q, `INSERT INTO redaction_rules (customer_id, check_id, headers, body_patterns, max_body_size) VALUES
+			($1, NULL, '["Authorization"]', '[]', 0),
+			($1, 'check-id-1', '[]', '["secret=\\w+"]', 64),
+			($1, 'check-id-2', '[]', '[]', 32),
+			('22222222-2222-2222-2222-222222222222', NULL, '["Cookie"]', '[]', 0)`, customerId)
+
+		rules, err := cs.GetRedactionRules(customerId, "check-id-1")
+		assert.NoError(err)
+		assert.Len(rules, 2)
+		for _, r := range rules {
+			if r.CheckId == "" {
+				assert.Equal([]string{"Authorization"}, r.Headers)
+			} else {
+				assert.Equal("check-id-1", r.CheckId)
+				assert.Equal([]string{`secret=\w+`}, r.BodyPatterns)
+				assert.Equal(64, r.MaxBodySize)
+			}
+		}
+
+		rules, err = cs.GetCustomerRedactionRules(customerId)
+		assert.NoError(err)
+		assert.Len(rules, 3)
+		for _, r := range rules {
+			assert.Equal(customerId, r.CustomerId)
+		}
 	})
 }
 
+// BenchmarkGetChecks gets a customer's checks from a database seeded with
+// 5,000 of them, each with two assertions and a notification.
+func BenchmarkGetChecks(b *testing.B) {
+	db, err := sqlx.Open("postgres", viper.GetString("postgres_conn"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	customerId := "22222222-2222-2222-2222-222222222222"
+	spec := `{"value": {"path": "/", "port": 443, "verb": "GET", "protocol": "https"}, "type_url": "HttpCheck"}`
+
+	sqlx.MustExec(tx, `INSERT INTO checks (id, min_failing_count, min_failing_time, customer_id, execution_group_id, name, target_type, target_id, target_name, check_spec)
+		SELECT 'bench-check-' || i, 1, 90, $1, $1, 'bench check ' || i, 'sg', 'sg-bench', 'bench', $2 FROM generate_series(1, 5000) AS i`, customerId, spec)
+	sqlx.MustExec(tx, `INSERT INTO assertions (check_id, customer_id, key, value, relationship, operand)
+		SELECT 'bench-check-' || i, $1, key, '', 'equal', '200' FROM generate_series(1, 5000) AS i, (VALUES ('code'), ('header')) AS keys (key)`, customerId)
+	sqlx.MustExec(tx, `INSERT INTO notifications (check_id, customer_id, user_id, type, value)
+		SELECT 'bench-check-' || i, $1, 1, 'email', 'bench@opsee.co' FROM generate_series(1, 5000) AS i`, customerId)
+
+	cs := &checkStore{tx}
+	user := &schema.User{CustomerId: customerId}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		checks, err := cs.GetChecks(user)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if len(checks) != 5000 {
+			b.Fatalf("got %d checks", len(checks))
+		}
+	}
+}
+
 func withCheckFixtures(testFun func(CheckStore)) {
 	db, err := sqlx.Open("postgres", viper.GetString("postgres_conn"))
 	if err != nil {
 		panic(err)
 	}
 
+	db.MustExec("delete from check_state_transitions")
 	db.MustExec("delete from checks")
 	db.MustExec("delete from check_states")
 	db.MustExec("delete from check_state_memos")
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DeadLetter is a check result message that could not be processed after
+// the maximum number of delivery attempts.
+type DeadLetter struct {
+	Id         int64     `json:"id" db:"id"`
+	CustomerId string    `json:"customer_id" db:"customer_id"`
+	CheckId    string    `json:"check_id" db:"check_id"`
+	Body       []byte    `json:"body" db:"body"`
+	Error      string    `json:"error" db:"error"`
+	Attempts   int       `json:"attempts" db:"attempts"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+type deadLetterStore struct {
+	sqlx.Ext
+}
+
+func NewDeadLetterStore(q sqlx.Ext) DeadLetterStore {
+	return &deadLetterStore{q}
+}
+
+// Put stores a dead letter, filling in its id and creation time.
+func (q *deadLetterStore) Put(letter *DeadLetter) error {
+	return q.QueryRowx(
+		"INSERT INTO dead_letters (customer_id, check_id, body, error, attempts) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		letter.CustomerId, letter.CheckId, letter.Body, letter.Error, letter.Attempts,
+	).Scan(&letter.Id, &letter.CreatedAt)
+}
+
+// Get returns a single dead letter by id.
+func (q *deadLetterStore) Get(id int64) (*DeadLetter, error) {
+	letter := &DeadLetter{}
+	err := sqlx.Get(q, letter, "SELECT * FROM dead_letters WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return letter, nil
+}
+
+// List returns a page of dead letters, newest first. Pages start at 1.
+func (q *deadLetterStore) List(page, perPage int) ([]*DeadLetter, ListMeta, error) {
+	var (
+		letters  []*DeadLetter
+		listMeta ListMeta
+		total    uint64
+	)
+
+	if page < 1 {
+		return nil, listMeta, fmt.Errorf("page must start at 1")
+	}
+
+	if perPage > 1000 {
+		return nil, listMeta, fmt.Errorf("perPage is limited to 1000")
+	}
+
+	if err := sqlx.Get(q, &total, "SELECT count(id) FROM dead_letters"); err != nil {
+		return nil, listMeta, err
+	}
+
+	listMeta = ListMeta{
+		Page:    page,
+		PerPage: perPage,
+		Total:   total,
+	}
+
+	err := sqlx.Select(q, &letters, "SELECT * FROM dead_letters ORDER BY id DESC LIMIT $1 OFFSET $2", perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, listMeta, err
+	}
+
+	return letters, listMeta, nil
+}
+
+// Delete removes a dead letter, e.g. after it has been re-driven.
+func (q *deadLetterStore) Delete(id int64) error {
+	_, err := q.Exec("DELETE FROM dead_letters WHERE id = $1", id)
+	return err
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// NotificationGovernor is a team's notification rate state: how many alerts
+// it has had in the current window, and whether alerts are being held for
+// digests.
+type NotificationGovernor struct {
+	CustomerId  string    `db:"customer_id"`
+	WindowStart time.Time `db:"window_start"`
+	WindowCount int       `db:"window_count"`
+	Digesting   bool      `db:"digesting"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+// DigestEntry is an alert held back for a team's next digest.
+type DigestEntry struct {
+	Id           int64     `db:"id"`
+	CustomerId   string    `db:"customer_id"`
+	TransitionId int64     `db:"transition_id"`
+	CheckId      string    `db:"check_id"`
+	CheckName    string    `db:"check_name"`
+	Passing      bool      `db:"passing"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+type governorStore struct {
+	sqlx.Ext
+}
+
+// NewGovernorStore returns a GovernorStore, which keeps the state of the
+// per-team notification governor.
+func NewGovernorStore(q sqlx.Ext) GovernorStore {
+	return &governorStore{q}
+}
+
+// LockGovernor returns a team's governor state, creating it if needed, and
+// locks it until the transaction ends. It must be called in a transaction.
+func (q *governorStore) LockGovernor(customerId string) (*NotificationGovernor, error) {
+	if _, err := q.Exec("INSERT INTO notification_governors (customer_id) VALUES ($1) ON CONFLICT (customer_id) DO NOTHING", customerId); err != nil {
+		return nil, err
+	}
+
+	governor := &NotificationGovernor{}
+	err := sqlx.Get(q, governor, "SELECT * FROM notification_governors WHERE customer_id = $1 FOR UPDATE", customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return governor, nil
+}
+
+func (q *governorStore) UpdateGovernor(governor *NotificationGovernor) error {
+	_, err := q.Exec(
+		"UPDATE notification_governors SET window_start = $2, window_count = $3, digesting = $4 WHERE customer_id = $1",
+		governor.CustomerId, governor.WindowStart, governor.WindowCount, governor.Digesting,
+	)
+	return err
+}
+
+// GetAdmission returns whether a transition's alert has already been
+// admitted by the governor, because it has a digest entry or deliveries, and
+// if so, whether it was held for a digest.
+func (q *governorStore) GetAdmission(customerId string, transitionId int64) (bool, bool, error) {
+	var held, sent bool
+	err := q.QueryRowx(
+		`SELECT EXISTS (SELECT 1 FROM notification_digest_entries WHERE customer_id = $1 AND transition_id = $2),
+		 EXISTS (SELECT 1 FROM notification_deliveries WHERE customer_id = $1 AND transition_id = $2)`,
+		customerId, transitionId,
+	).Scan(&held, &sent)
+	return held || sent, held, err
+}
+
+func (q *governorStore) PutDigestEntry(entry *DigestEntry) error {
+	return q.QueryRowx(
+		`INSERT INTO notification_digest_entries (customer_id, transition_id, check_id, check_name, passing) VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id, created_at`,
+		entry.CustomerId, entry.TransitionId, entry.CheckId, entry.CheckName, entry.Passing,
+	).Scan(&entry.Id, &entry.CreatedAt)
+}
+
+// GetDigestEntries returns a team's pending digest entries, oldest first.
+func (q *governorStore) GetDigestEntries(customerId string) ([]*DigestEntry, error) {
+	var entries []*DigestEntry
+	err := sqlx.Select(q, &entries, "SELECT * FROM notification_digest_entries WHERE customer_id = $1 ORDER BY id", customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// DeleteDigestEntries deletes a team's digest entries with ids, once they
+// have been sent in a digest.
+func (q *governorStore) DeleteDigestEntries(customerId string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In("DELETE FROM notification_digest_entries WHERE customer_id = ? AND id IN (?)", customerId, ids)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(q.Rebind(query), args...)
+	return err
+}
+
+// GetDigestCustomers returns the teams with pending digest entries.
+func (q *governorStore) GetDigestCustomers() ([]string, error) {
+	var customerIds []string
+	err := sqlx.Select(q, &customerIds, "SELECT DISTINCT customer_id FROM notification_digest_entries")
+	if err != nil {
+		return nil, err
+	}
+
+	return customerIds, nil
+}
+
+// IsCriticalCheck returns true if the team has marked the check critical,
+// so that its alerts are always sent individually.
+func (q *governorStore) IsCriticalCheck(customerId, checkId string) (bool, error) {
+	var critical bool
+	err := q.QueryRowx("SELECT EXISTS (SELECT 1 FROM notification_critical_checks WHERE customer_id = $1 AND check_id = $2)", customerId, checkId).Scan(&critical)
+	return critical, err
+}
+
+func (q *governorStore) GetCriticalChecks(customerId string) ([]string, error) {
+	checkIds := []string{}
+	err := sqlx.Select(q, &checkIds, "SELECT check_id FROM notification_critical_checks WHERE customer_id = $1 ORDER BY check_id", customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkIds, nil
+}
+
+// PutCriticalCheck marks a check critical, or not.
+func (q *governorStore) PutCriticalCheck(customerId, checkId string, critical bool) error {
+	var err error
+	if critical {
+		_, err = q.Exec("INSERT INTO notification_critical_checks (customer_id, check_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", customerId, checkId)
+	} else {
+		_, err = q.Exec("DELETE FROM notification_critical_checks WHERE customer_id = $1 AND check_id = $2", customerId, checkId)
+	}
+
+	return err
+}
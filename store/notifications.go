@@ -0,0 +1,229 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/opsee/basic/schema"
+)
+
+const (
+	DeliveryPending     = "pending"
+	DeliveryDelivered   = "delivered"
+	DeliveryFailed      = "failed"
+	DeliveryUnsupported = "unsupported"
+	DeliverySuppressed  = "suppressed"
+)
+
+// NotificationDelivery is a single alert sent, or to be sent, to a single
+// notification target. Deliveries are kept as a log once they are finished.
+type NotificationDelivery struct {
+	Id            int64     `json:"id" db:"id"`
+	TransitionId  int64     `json:"transition_id" db:"transition_id"`
+	DigestId      int64     `json:"digest_id" db:"digest_id"`
+	CheckId       string    `json:"check_id" db:"check_id"`
+	CustomerId    string    `json:"customer_id" db:"customer_id"`
+	Type          string    `json:"type" db:"type"`
+	Value         string    `json:"value" db:"value"`
+	Payload       []byte    `json:"payload" db:"payload"`
+	Status        string    `json:"status" db:"status"`
+	Attempts      int       `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     string    `json:"last_error" db:"last_error"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// NotificationRoute sends the alerts of a customer's checks whose labels
+// match Selector to a notification.
+type NotificationRoute struct {
+	Id         int64     `json:"id" db:"id"`
+	CustomerId string    `json:"customer_id" db:"customer_id"`
+	Selector   string    `json:"selector" db:"selector"`
+	Type       string    `json:"type" db:"type"`
+	Value      string    `json:"value" db:"value"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type notificationStore struct {
+	sqlx.Ext
+}
+
+func NewNotificationStore(q sqlx.Ext) NotificationStore {
+	return &notificationStore{q}
+}
+
+func getCheckNotifications(q sqlx.Queryer, customerId, checkId string) ([]*schema.Notification, error) {
+	notifications := []*schema.Notification{}
+	err := sqlx.Select(q, &notifications, "SELECT type::text AS type, value FROM notifications WHERE customer_id = $1 AND check_id = $2 ORDER BY id", customerId, checkId)
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// GetCheckNotifications returns the notifications configured for a check.
+func (q *notificationStore) GetCheckNotifications(customerId, checkId string) ([]*schema.Notification, error) {
+	return getCheckNotifications(q, customerId, checkId)
+}
+
+// GetDefaultNotifications returns the customer's default notifications, used
+// for checks that don't have any of their own.
+func (q *notificationStore) GetDefaultNotifications(customerId string) ([]*schema.Notification, error) {
+	notifications := []*schema.Notification{}
+	err := sqlx.Select(q, &notifications, "SELECT type::text AS type, value FROM default_notifications WHERE customer_id = $1 ORDER BY id", customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// GetNotificationRoutes returns a customer's notification routes, oldest
+// first.
+func (q *notificationStore) GetNotificationRoutes(customerId string) ([]*NotificationRoute, error) {
+	routes := []*NotificationRoute{}
+	err := sqlx.Select(q, &routes, "SELECT id, customer_id, selector, type::text AS type, value, created_at, updated_at FROM notification_routes WHERE customer_id = $1 ORDER BY id", customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return routes, nil
+}
+
+// PutNotificationRoute creates a route, filling in its id, or updates it if
+// it has one.
+func (q *notificationStore) PutNotificationRoute(route *NotificationRoute) error {
+	if route.Id == 0 {
+		return sqlx.Get(q, &route.Id, "INSERT INTO notification_routes (customer_id, selector, type, value) VALUES ($1, $2, $3, $4) RETURNING id", route.CustomerId, route.Selector, route.Type, route.Value)
+	}
+
+	return sqlx.Get(q, &route.Id, "UPDATE notification_routes SET selector = $3, type = $4, value = $5 WHERE id = $1 AND customer_id = $2 RETURNING id", route.Id, route.CustomerId, route.Selector, route.Type, route.Value)
+}
+
+func (q *notificationStore) DeleteNotificationRoute(customerId string, id int64) error {
+	_, err := q.Exec("DELETE FROM notification_routes WHERE id = $1 AND customer_id = $2", id, customerId)
+	return err
+}
+
+// PutCheckNotifications replaces the notifications for a check. It should be
+// called in a transaction.
+func (q *notificationStore) PutCheckNotifications(user *schema.User, checkId string, notifications []*schema.Notification) error {
+	if _, err := q.Exec("DELETE FROM notifications WHERE customer_id = $1 AND check_id = $2", user.CustomerId, checkId); err != nil {
+		return err
+	}
+
+	for _, n := range notifications {
+		_, err := q.Exec("INSERT INTO notifications (check_id, customer_id, user_id, type, value) VALUES ($1, $2, $3, $4, $5)", checkId, user.CustomerId, user.Id, n.Type, n.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PutDelivery records a pending delivery, filling in its id. A delivery that
+// already exists for the same transition or digest and target is left alone.
+func (q *notificationStore) PutDelivery(delivery *NotificationDelivery) error {
+	rows, err := q.Queryx(
+		`INSERT INTO notification_deliveries (transition_id, digest_id, check_id, customer_id, type, value, payload) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT (transition_id, digest_id, type, value) DO NOTHING RETURNING id`,
+		delivery.TransitionId, delivery.DigestId, delivery.CheckId, delivery.CustomerId, delivery.Type, delivery.Value, delivery.Payload,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&delivery.Id); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ClaimDeliveries locks up to limit pending deliveries that are due. It must
+// be called in a transaction.
+func (q *notificationStore) ClaimDeliveries(limit int) ([]*NotificationDelivery, error) {
+	var deliveries []*NotificationDelivery
+	err := sqlx.Select(q, &deliveries, "SELECT * FROM notification_deliveries WHERE status = $1 AND next_attempt_at <= now() ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED", DeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt.
+func (q *notificationStore) UpdateDelivery(delivery *NotificationDelivery) error {
+	_, err := q.Exec(
+		"UPDATE notification_deliveries SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5 WHERE id = $1",
+		delivery.Id, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError,
+	)
+	return err
+}
+
+// GetDeliveries returns the delivery log for a check, newest first. If
+// notificationType is not empty, only deliveries of that type are returned.
+func (q *notificationStore) GetDeliveries(customerId, checkId, notificationType string, limit int) ([]*NotificationDelivery, error) {
+	var deliveries []*NotificationDelivery
+	err := sqlx.Select(q, &deliveries, "SELECT * FROM notification_deliveries WHERE customer_id = $1 AND check_id = $2 AND ($3 = '' OR type = $3) ORDER BY id DESC LIMIT $4", customerId, checkId, notificationType, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// GetWebhookSecret returns the secret webhooks for a customer are signed
+// with, creating it if the customer doesn't have one yet.
+func (q *notificationStore) GetWebhookSecret(customerId string) (string, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.Exec("INSERT INTO webhook_secrets (customer_id, secret) VALUES ($1, $2) ON CONFLICT (customer_id) DO NOTHING", customerId, secret)
+	if err != nil {
+		return "", err
+	}
+
+	err = sqlx.Get(q, &secret, "SELECT secret FROM webhook_secrets WHERE customer_id = $1", customerId)
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// RotateWebhookSecret replaces a customer's webhook secret and returns the
+// new one.
+func (q *notificationStore) RotateWebhookSecret(customerId string) (string, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.Exec("INSERT INTO webhook_secrets (customer_id, secret) VALUES ($1, $2) ON CONFLICT (customer_id) DO UPDATE SET secret = EXCLUDED.secret", customerId, secret)
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// OutboxEntry is a side effect of a committed transaction, e.g. writing a
+// transition snapshot, that is waiting to be delivered by an outbox relay.
+type OutboxEntry struct {
+	Id            int64     `json:"id" db:"id"`
+	Kind          string    `json:"kind" db:"kind"`
+	Payload       []byte    `json:"payload" db:"payload"`
+	Attempts      int       `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     string    `json:"last_error" db:"last_error"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+type outboxStore struct {
+	sqlx.Ext
+}
+
+// NewOutboxStore returns an OutboxStore. Entries should be put with the
+// same transaction as the change that produced them. Claims are leases, so
+// entries are delivered without holding a transaction open.
+func NewOutboxStore(q sqlx.Ext) OutboxStore {
+	return &outboxStore{q}
+}
+
+func (q *outboxStore) Put(kind string, payload []byte) error {
+	_, err := q.Exec("INSERT INTO outbox (kind, payload) VALUES ($1, $2)", kind, payload)
+	return err
+}
+
+// Claim leases up to limit entries that are due for delivery, oldest first,
+// by counting an attempt and putting their next attempt off until the lease
+// ends. Entries being claimed by another relay are skipped. A claimed entry
+// should be deleted once it's delivered, or rescheduled.
+func (q *outboxStore) Claim(limit int, lease time.Duration) ([]*OutboxEntry, error) {
+	var entries []*OutboxEntry
+	err := sqlx.Select(q, &entries,
+		`UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $2
+		 WHERE id IN (SELECT id FROM outbox WHERE next_attempt_at <= now() ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED)
+		 RETURNING *`,
+		limit, time.Now().Add(lease),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(outboxSort(entries))
+	return entries, nil
+}
+
+func (q *outboxStore) Delete(id int64) error {
+	_, err := q.Exec("DELETE FROM outbox WHERE id = $1", id)
+	return err
+}
+
+// Reschedule records a failed delivery attempt, or releases a claimed entry.
+func (q *outboxStore) Reschedule(id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := q.Exec("UPDATE outbox SET attempts = $2, next_attempt_at = $3, last_error = $4 WHERE id = $1", id, attempts, nextAttemptAt, lastError)
+	return err
+}
+
+type outboxSort []*OutboxEntry
+
+func (s outboxSort) Len() int           { return len(s) }
+func (s outboxSort) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s outboxSort) Less(i, j int) bool { return s[i].Id < s[j].Id }
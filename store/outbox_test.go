@@ -0,0 +1,48 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxClaim(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := sqlx.Open("postgres", viper.GetString("postgres_conn"))
+	if err != nil {
+		panic(err)
+	}
+	db.MustExec("DELETE FROM outbox")
+
+	outboxStore := NewOutboxStore(db)
+	for i := 0; i < 3; i++ {
+		assert.NoError(outboxStore.Put("snapshot", []byte(`{}`)))
+	}
+
+	// a claim is committed on its own, counting an attempt
+	entries, err := outboxStore.Claim(2, time.Hour)
+	assert.NoError(err)
+	assert.Len(entries, 2)
+	assert.True(entries[0].Id < entries[1].Id)
+	assert.Equal(1, entries[0].Attempts)
+	assert.True(entries[0].NextAttemptAt.After(time.Now().Add(time.Minute)))
+
+	// leased entries aren't claimed again until they're rescheduled
+	rest, err := outboxStore.Claim(10, time.Hour)
+	assert.NoError(err)
+	assert.Len(rest, 1)
+
+	assert.NoError(outboxStore.Delete(entries[0].Id))
+	assert.NoError(outboxStore.Reschedule(entries[1].Id, entries[1].Attempts, time.Now(), "down"))
+
+	retried, err := outboxStore.Claim(10, time.Hour)
+	assert.NoError(err)
+	assert.Len(retried, 1)
+	assert.Equal(entries[1].Id, retried[0].Id)
+	assert.Equal(2, retried[0].Attempts)
+	assert.Equal("down", retried[0].LastError)
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/opsee/cats/preferences"
+)
+
+type preferenceStore struct {
+	sqlx.Ext
+}
+
+// NewPreferenceStore returns a PreferenceStore, which keeps each user's
+// notification preferences under notification_preferences in their userdata.
+func NewPreferenceStore(q sqlx.Ext) PreferenceStore {
+	return &preferenceStore{q}
+}
+
+func (q *preferenceStore) getPreferences(query string, args ...interface{}) (*preferences.Preferences, error) {
+	var data []byte
+	err := q.QueryRowx(query, args...).Scan(&data)
+	if err == sql.ErrNoRows || (err == nil && data == nil) {
+		return preferences.Default(), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := preferences.Default()
+	if err := json.Unmarshal(data, prefs); err != nil {
+		return nil, err
+	}
+
+	return prefs, nil
+}
+
+// GetPreferences returns a user's notification preferences, or the defaults
+// if they haven't set any.
+func (q *preferenceStore) GetPreferences(userId int) (*preferences.Preferences, error) {
+	return q.getPreferences("SELECT data->'notification_preferences' FROM userdata WHERE user_id = $1", userId)
+}
+
+// GetPreferencesByEmail returns the notification preferences of the user
+// with an email address, or the defaults if there is no such user.
+func (q *preferenceStore) GetPreferencesByEmail(email string) (*preferences.Preferences, error) {
+	return q.getPreferences(
+		`SELECT d.data->'notification_preferences' FROM userdata d JOIN users u ON u.id = d.user_id
+		 WHERE lower(u.email) = lower($1) ORDER BY u.id LIMIT 1`,
+		email,
+	)
+}
+
+// PutPreferences replaces a user's notification preferences. It returns
+// sql.ErrNoRows if the user has no userdata.
+func (q *preferenceStore) PutPreferences(userId int, prefs *preferences.Preferences) error {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+
+	res, err := q.Exec("UPDATE userdata SET data = jsonb_set(data, '{notification_preferences}', $2::jsonb) WHERE user_id = $1", userId, data)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
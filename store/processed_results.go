@@ -0,0 +1,53 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type processedResultStore struct {
+	sqlx.Ext
+}
+
+// NewProcessedResultStore returns a ProcessedResultStore, which records the
+// idempotency keys of check results that have been processed.
+func NewProcessedResultStore(q sqlx.Ext) ProcessedResultStore {
+	return &processedResultStore{q}
+}
+
+// Claim records key as processed until ttl from now. It returns false if the
+// key has already been processed and has not yet expired. Claim should be
+// called in the transaction that processes the result, so that the key is
+// released if processing fails; a concurrent Claim of the same key blocks
+// until that transaction finishes.
+func (q *processedResultStore) Claim(key string, ttl time.Duration) (bool, error) {
+	var claimed string
+	err := q.QueryRowx(
+		`INSERT INTO processed_results (key, expires_at) VALUES ($1, now() + $2 * interval '1 second')
+		 ON CONFLICT (key) DO UPDATE SET expires_at = EXCLUDED.expires_at WHERE processed_results.expires_at < now()
+		 RETURNING key`,
+		key, ttl.Seconds(),
+	).Scan(&claimed)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Purge deletes expired keys and returns how many were deleted.
+func (q *processedResultStore) Purge() (int64, error) {
+	res, err := q.Exec("DELETE FROM processed_results WHERE expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
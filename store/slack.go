@@ -0,0 +1,32 @@
+package store
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+type slackStore struct {
+	sqlx.Ext
+}
+
+// NewSlackStore returns a SlackStore, which reads the Slack installations
+// saved from each team's OAuth response.
+func NewSlackStore(q sqlx.Ext) SlackStore {
+	return &slackStore{q}
+}
+
+// GetIncomingWebhookURLs returns the incoming webhook URL of every Slack
+// installation of a customer. Installations without an incoming webhook
+// are skipped.
+func (q *slackStore) GetIncomingWebhookURLs(customerId string) ([]string, error) {
+	var urls []string
+	err := sqlx.Select(q, &urls,
+		`SELECT DISTINCT data->'incoming_webhook'->>'url' FROM slack_oauth_responses
+		 WHERE customer_id = $1 AND data->'incoming_webhook'->>'url' IS NOT NULL`,
+		customerId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
@@ -5,21 +5,46 @@ import (
 
 	"github.com/opsee/basic/schema"
 	"github.com/opsee/cats/checks"
+	"github.com/opsee/cats/checks/labels"
+	"github.com/opsee/cats/checks/templates"
+	"github.com/opsee/cats/preferences"
 )
 
 type CheckStore interface {
+	WithTX(txfun func(CheckStore, NotificationStore) error) error
 	GetAndLockState(customerId, checkId string) (*checks.State, error)
 	UpdateState(state *checks.State) error
 	PutState(state *checks.State) error
 	PutMemo(memo *checks.ResultMemo) error
 	GetMemo(checkId, bastionId string) (*checks.ResultMemo, error)
 	CreateStateTransitionLogEntry(checkId, customerId string, fromState, toState checks.StateId) (*checks.StateTransitionLogEntry, error)
+	CreateStateTransitionLogEntryAt(checkId, customerId string, fromState, toState checks.StateId, createdAt time.Time) (*checks.StateTransitionLogEntry, error)
 	GetLiveBastions(customerId, checkId string) ([]string, error)
 	GetCheckStateTransitionLogEntries(checkId, customerId string, from, to time.Time) ([]*checks.StateTransitionLogEntry, error)
 	GetCheckStateTransitionLogEntry(checkId, customerId string, transitionId int64) (*checks.StateTransitionLogEntry, error)
 	GetCheck(user *schema.User, checkId string) (*schema.Check, error)
+	GetCheckSpec(customerId, checkId string) (interface{}, error)
 	GetChecks(user *schema.User) ([]*schema.Check, error)
+	CreateCheck(check *schema.Check) error
+	UpdateCheck(check *schema.Check) error
+	DeleteCheck(customerId, checkId string) error
+	GetTakenCheckIds(ids []string) ([]string, error)
+	GetCheckLabels(customerId string, checkIds []string) (map[string]labels.Labels, error)
+	PutCheckLabels(customerId, checkId string, l labels.Labels) error
+	UpdateCheckLabels(customerId string, checkIds []string, set labels.Labels, remove []string) error
+	GetCheckIds(customerId string, selector labels.Selector) ([]string, error)
+	GetCheckTemplates(customerId string) ([]*templates.Template, error)
+	GetCheckTemplate(customerId, templateId string) (*templates.Template, error)
+	PutCheckTemplate(t *templates.Template) error
+	DeleteCheckTemplate(customerId, templateId string) error
+	GetTemplateCheckIds(customerId, templateId string) ([]string, error)
+	GetDerivedCheckIds(customerId string) ([]string, error)
+	SetCheckTemplate(customerId, templateId string, checkIds []string) error
+	ListChecks(customerId string, query *CheckListQuery) (*CheckList, error)
 	GetCheckCount(customerId string) (int32, error)
+	GetCheckStates(customerId string) ([]*checks.State, error)
+	GetRedactionRules(customerId, checkId string) ([]*checks.RedactionRule, error)
+	GetCustomerRedactionRules(customerId string) ([]*checks.RedactionRule, error)
 }
 
 type TeamStore interface {
@@ -35,6 +60,63 @@ type TeamStore interface {
 	List(page, perPage int) ([]*schema.Team, ListMeta, error)
 }
 
+type DeadLetterStore interface {
+	Put(letter *DeadLetter) error
+	Get(id int64) (*DeadLetter, error)
+	List(page, perPage int) ([]*DeadLetter, ListMeta, error)
+	Delete(id int64) error
+}
+
+type OutboxStore interface {
+	Put(kind string, payload []byte) error
+	Claim(limit int, lease time.Duration) ([]*OutboxEntry, error)
+	Delete(id int64) error
+	Reschedule(id int64, attempts int, nextAttemptAt time.Time, lastError string) error
+}
+
+type ProcessedResultStore interface {
+	Claim(key string, ttl time.Duration) (bool, error)
+	Purge() (int64, error)
+}
+
+type NotificationStore interface {
+	GetCheckNotifications(customerId, checkId string) ([]*schema.Notification, error)
+	GetDefaultNotifications(customerId string) ([]*schema.Notification, error)
+	PutCheckNotifications(user *schema.User, checkId string, notifications []*schema.Notification) error
+	GetNotificationRoutes(customerId string) ([]*NotificationRoute, error)
+	PutNotificationRoute(route *NotificationRoute) error
+	DeleteNotificationRoute(customerId string, id int64) error
+	PutDelivery(delivery *NotificationDelivery) error
+	ClaimDeliveries(limit int) ([]*NotificationDelivery, error)
+	UpdateDelivery(delivery *NotificationDelivery) error
+	GetDeliveries(customerId, checkId, notificationType string, limit int) ([]*NotificationDelivery, error)
+	GetWebhookSecret(customerId string) (string, error)
+	RotateWebhookSecret(customerId string) (string, error)
+}
+
+type GovernorStore interface {
+	LockGovernor(customerId string) (*NotificationGovernor, error)
+	UpdateGovernor(governor *NotificationGovernor) error
+	GetAdmission(customerId string, transitionId int64) (admitted, held bool, err error)
+	PutDigestEntry(entry *DigestEntry) error
+	GetDigestEntries(customerId string) ([]*DigestEntry, error)
+	DeleteDigestEntries(customerId string, ids []int64) error
+	GetDigestCustomers() ([]string, error)
+	IsCriticalCheck(customerId, checkId string) (bool, error)
+	GetCriticalChecks(customerId string) ([]string, error)
+	PutCriticalCheck(customerId, checkId string, critical bool) error
+}
+
+type PreferenceStore interface {
+	GetPreferences(userId int) (*preferences.Preferences, error)
+	GetPreferencesByEmail(email string) (*preferences.Preferences, error)
+	PutPreferences(userId int, prefs *preferences.Preferences) error
+}
+
+type SlackStore interface {
+	GetIncomingWebhookURLs(customerId string) ([]string, error)
+}
+
 type ListMeta struct {
 	Page    int
 	PerPage int
@@ -2,7 +2,9 @@ package subscriptions
 
 import (
 	"github.com/opsee/basic/schema"
+	"github.com/opsee/cats/jobs/slack"
 	"github.com/opsee/cats/mailer"
+	"github.com/opsee/cats/preferences"
 	log "github.com/opsee/logrus"
 	"github.com/stripe/stripe-go"
 	"github.com/stripe/stripe-go/sub"
@@ -24,6 +26,7 @@ func HandleEvent(team *schema.Team, event *stripe.Event) error {
 		}
 
 		mailBillingUsers(team, "warning-minus-three", map[string]interface{}{})
+		notifySlack(team, "billing-trial-ending", map[string]interface{}{})
 
 	case "invoice.payment_failed":
 		// only send this to non-free plan people
@@ -36,12 +39,14 @@ func HandleEvent(team *schema.Team, event *stripe.Event) error {
 		// so we'll send them a special email
 		if team.SubscriptionStatus == string(sub.Trialing) {
 			mailBillingUsers(team, "trial-expired", map[string]interface{}{})
+			notifySlack(team, "billing-trial-expired", map[string]interface{}{})
 			team.SubscriptionStatus = string(sub.PastDue)
 			return nil
 		}
 
 		// this is just a regular payment failure
-		switch event.GetObjValue("attempt_count") {
+		attemptCount := event.GetObjValue("attempt_count")
+		switch attemptCount {
 		case "1":
 			mailBillingUsers(team, "warning-zero", map[string]interface{}{})
 		case "2":
@@ -50,6 +55,10 @@ func HandleEvent(team *schema.Team, event *stripe.Event) error {
 			mailBillingUsers(team, "warning-seven", map[string]interface{}{})
 		}
 
+		notifySlack(team, "billing-payment-failed", map[string]interface{}{
+			"attempt_count": attemptCount,
+		})
+
 		return nil
 
 	case "invoice.payment_succeeded":
@@ -65,15 +74,28 @@ func HandleEvent(team *schema.Team, event *stripe.Event) error {
 func mailBillingUsers(team *schema.Team, template string, vars map[string]interface{}) {
 	withBillingUsers(team, func(u *schema.User) {
 		logger := log.WithFields(log.Fields{"template": template, "email": u.Email})
-		_, err := mailer.Send(u.Email, u.Name, template, vars)
+		_, err := mailer.SendCategory(preferences.Billing, u.Email, u.Name, template, vars)
+		if err == mailer.ErrSuppressed {
+			return
+		}
+
 		if err != nil {
 			logger.WithError(err).Error("couldn't send email to mandrill")
+			return
 		}
 
 		logger.Info("sent email")
 	})
 }
 
+// notifySlack queues a Slack notification to the team's Slack, if it has one.
+func notifySlack(team *schema.Team, template string, vars map[string]interface{}) {
+	vars["team_name"] = team.Name
+	if err := slack.Emit(team.Id, template, vars); err != nil {
+		log.WithError(err).WithField("template", template).Error("couldn't queue slack notification")
+	}
+}
+
 func withBillingUsers(team *schema.Team, billFunc func(*schema.User)) {
 	for _, u := range team.Users {
 		if u.HasPermission("admin") || u.HasPermission("billing") {